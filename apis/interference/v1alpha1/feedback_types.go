@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// The interference manager feeds the active InterferenceEvents back in two forms: an annotation on each affected
+// node, and optionally an annotation on each interfered pod. Both are removed once the events are deleted. They
+// carry no timestamps, so that they are only updated when the interference changes rather than on every evaluation.
+//
+// The annotations are the feedback interface of Koordetector. Koordinator does not consume them yet: the NodeSLO
+// spec is rendered by koord-manager, and koordlet has no input for interference, so suppressing or evicting the
+// aggressors is left to schedulers, descheduler plugins or operators reading the annotations.
+
+const (
+	// AnnotationPodInterference is the annotation of interfered pods, whose value is the PodInterference in JSON.
+	AnnotationPodInterference = "interference.koordinator.sh/interference"
+	// AnnotationNodeInterference is the annotation of affected nodes, whose value is the NodeInterference in JSON.
+	AnnotationNodeInterference = "interference.koordinator.sh/node-interference"
+)
+
+// PodInterference summarizes the InterferenceEvents of a pod.
+type PodInterference struct {
+	// Severity is the highest severity of the containers.
+	Severity InterferenceSeverity `json:"severity"`
+	// Containers are the interfered containers, ordered by name.
+	Containers []ContainerInterference `json:"containers"`
+}
+
+// ContainerInterference is the interference of a container detected by a rule.
+type ContainerInterference struct {
+	// ContainerName is empty for pod level metrics.
+	ContainerName string               `json:"containerName,omitempty"`
+	RuleName      string               `json:"ruleName"`
+	Metric        MetricName           `json:"metric"`
+	Severity      InterferenceSeverity `json:"severity"`
+}
+
+// NodeInterference summarizes the InterferenceEvents of pods on a node.
+type NodeInterference struct {
+	// Severity is the highest severity of the victims.
+	Severity InterferenceSeverity `json:"severity"`
+	// Victims are the interfered pods, ordered by severity.
+	Victims []InterferenceVictim `json:"victims"`
+	// Aggressors are the pods suspected by any of the victims, ordered by the number of victims suspecting them.
+	// +optional
+	Aggressors []AggressorPod `json:"aggressors,omitempty"`
+}
+
+// InterferenceVictim is an interfered pod on the node.
+type InterferenceVictim struct {
+	Namespace string               `json:"namespace"`
+	Name      string               `json:"name"`
+	UID       types.UID            `json:"uid"`
+	Severity  InterferenceSeverity `json:"severity"`
+}
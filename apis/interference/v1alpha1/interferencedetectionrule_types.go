@@ -17,6 +17,9 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -30,11 +33,147 @@ import (
 
 // InterferenceDetectionRuleSpec defines the desired state of InterferenceDetectionRule
 type InterferenceDetectionRuleSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Metric is the performance metric the rule evaluates, e.g. koordlet_container_cpi.
+	Metric MetricSource `json:"metric"`
+
+	// WorkloadSelector determines on which workloads the rule takes effect.
+	// An empty selector selects all workloads in the cluster.
+	// +optional
+	WorkloadSelector *WorkloadSelector `json:"workloadSelector,omitempty"`
+
+	// Algorithm is the detection algorithm and its arguments used to judge whether a workload is interfered.
+	Algorithm DetectionAlgorithm `json:"algorithm"`
+
+	// EvaluationWindow is the time range of samples used to calculate a workload's normal performance.
+	// +kubebuilder:default="24h"
+	// +optional
+	EvaluationWindow *metav1.Duration `json:"evaluationWindow,omitempty"`
+
+	// EvaluationInterval is how often the rule is evaluated, the poll interval of the interference manager by
+	// default, which is 1m unless it is configured.
+	// +optional
+	EvaluationInterval *metav1.Duration `json:"evaluationInterval,omitempty"`
+
+	// MinSampleCount is the minimum number of samples a workload needs within the EvaluationWindow before the rule
+	// takes effect on it.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=10
+	// +optional
+	MinSampleCount *int64 `json:"minSampleCount,omitempty"`
+
+	// EventTTL is how long an InterferenceEvent fired by the rule is kept after the interference was last detected.
+	// +kubebuilder:default="1h"
+	// +optional
+	EventTTL *metav1.Duration `json:"eventTTL,omitempty"`
+}
+
+// MetricName is the name of a performance metric, which follows the Prometheus metric naming convention.
+// +kubebuilder:validation:MinLength=1
+// +kubebuilder:validation:Pattern=`^[a-zA-Z_:][a-zA-Z0-9_:]*$`
+type MetricName string
+
+const (
+	// MetricContainerCPI is the container level CPI collected by koordlet.
+	MetricContainerCPI MetricName = "koordlet_container_cpi"
+	// MetricPodCPI is the pod level CPI collected by koordlet.
+	MetricPodCPI MetricName = "koordlet_pod_cpi"
+	// MetricContainerCPUScheduleLatency is the container level CPU schedule latency collected by koordetector.
+	MetricContainerCPUScheduleLatency MetricName = "koordetector_container_cpu_schedule_latency_seconds"
+	// MetricPodCPUUsage is the pod level cpu usage in cores reported in NodeMetrics.
+	MetricPodCPUUsage MetricName = "nodemetric_pod_cpu_usage"
+	// MetricPodMemoryUsage is the pod level memory usage in bytes reported in NodeMetrics.
+	MetricPodMemoryUsage MetricName = "nodemetric_pod_memory_usage"
+)
+
+// MetricSource describes which metric the rule evaluates.
+type MetricSource struct {
+	// Name is the name of the metric.
+	Name MetricName `json:"name"`
+}
+
+// WorkloadSelector selects workloads in the namespace of the rule by pod labels and owner kinds.
+// All non-empty conditions must be satisfied at the same time.
+type WorkloadSelector struct {
+	// PodSelector selects the pods of workloads by labels, nil means all pods.
+	// +optional
+	PodSelector *metav1.LabelSelector `json:"podSelector,omitempty"`
+
+	// OwnerKinds restricts the kinds of workloads which pods belong to, e.g. Deployment, StatefulSet.
+	// Empty means all kinds.
+	// +optional
+	OwnerKinds []string `json:"ownerKinds,omitempty"`
+}
+
+// DetectionAlgorithmType is the type of interference detection algorithm.
+// +kubebuilder:validation:Enum=StaticThreshold;MeanStdDev;Percentile
+type DetectionAlgorithmType string
+
+const (
+	// StaticThresholdAlgorithm regards a workload as interfered when the metric exceeds a fixed threshold.
+	StaticThresholdAlgorithm DetectionAlgorithmType = "StaticThreshold"
+	// MeanStdDevAlgorithm regards a workload as interfered when the metric exceeds mean + k * stddev of its baseline.
+	MeanStdDevAlgorithm DetectionAlgorithmType = "MeanStdDev"
+	// PercentileAlgorithm regards a workload as interfered when the metric exceeds a percentile of its baseline.
+	PercentileAlgorithm DetectionAlgorithmType = "Percentile"
+)
+
+// DetectionAlgorithm describes the algorithm and its arguments. Only the arguments matching Type are used, see
+// Validate for the arguments required.
+type DetectionAlgorithm struct {
+	// Type is the type of the algorithm.
+	Type DetectionAlgorithmType `json:"type"`
+
+	// StaticThreshold holds the arguments of StaticThreshold algorithm, which is required when Type is StaticThreshold.
+	// +optional
+	StaticThreshold *StaticThresholdArgs `json:"staticThreshold,omitempty"`
+
+	// MeanStdDev holds the arguments of MeanStdDev algorithm.
+	// +optional
+	MeanStdDev *MeanStdDevArgs `json:"meanStdDev,omitempty"`
 
-	// Foo is an example field of InterferenceDetectionRule. Edit interferencedetectionrule_types.go to remove/update
-	Foo string `json:"foo,omitempty"`
+	// Percentile holds the arguments of Percentile algorithm.
+	// +optional
+	Percentile *PercentileArgs `json:"percentile,omitempty"`
+}
+
+// Validate checks that the arguments required by the algorithm are set, which can not be expressed by the CRD
+// schema: StaticThreshold requires StaticThreshold, while the arguments of other algorithms have defaults.
+func (a *DetectionAlgorithm) Validate() error {
+	switch a.Type {
+	case StaticThresholdAlgorithm:
+		if a.StaticThreshold == nil {
+			return fmt.Errorf("staticThreshold is required for algorithm %v", a.Type)
+		}
+	case MeanStdDevAlgorithm, PercentileAlgorithm:
+	default:
+		return fmt.Errorf("algorithm %v is not supported", a.Type)
+	}
+	return nil
+}
+
+type StaticThresholdArgs struct {
+	// Threshold is the upper bound of the metric value, e.g. "1.5" for CPI.
+	Threshold resource.Quantity `json:"threshold"`
+}
+
+type MeanStdDevArgs struct {
+	// StdDevFactor is the k in mean + k * stddev.
+	// +kubebuilder:default="3"
+	// +optional
+	StdDevFactor *resource.Quantity `json:"stdDevFactor,omitempty"`
+}
+
+type PercentileArgs struct {
+	// Percentile of the baseline used as the upper bound, in the range of [1, 99].
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=99
+	// +kubebuilder:default=99
+	// +optional
+	Percentile *int32 `json:"percentile,omitempty"`
+
+	// Tolerance is the ratio the metric is allowed to exceed the percentile value, e.g. "0.1" means 10%.
+	// +optional
+	Tolerance *resource.Quantity `json:"tolerance,omitempty"`
 }
 
 // InterferenceDetectionRuleStatus is where the interference manager calculates a workload's normal performance
@@ -46,12 +185,89 @@ type InterferenceDetectionRuleSpec struct {
 
 // InterferenceDetectionRuleStatus defines the observed state of InterferenceDetectionRule
 type InterferenceDetectionRuleStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ObservedGeneration is the most recent generation observed by the interference manager.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Baselines are the normal performance of each workload selected by the rule, ordered by workloads and
+	// containers. At most MaxBaselines are kept.
+	// +optional
+	Baselines []WorkloadBaseline `json:"baselines,omitempty"`
+
+	// Conditions describe the current state of the rule, e.g. whether the metric is available.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
+// WorkloadReference identifies a workload, which is the top level owner of pods, e.g. a Deployment.
+type WorkloadReference struct {
+	// APIVersion of the workload.
+	APIVersion string `json:"apiVersion"`
+	// Kind of the workload.
+	Kind string `json:"kind"`
+	// Namespace of the workload.
+	Namespace string `json:"namespace"`
+	// Name of the workload.
+	Name string `json:"name"`
+}
+
+// WorkloadBaseline is the normal performance of a workload on a metric, which is calculated from samples
+// between WindowStart and WindowEnd.
+type WorkloadBaseline struct {
+	// Owner is the workload this baseline belongs to.
+	Owner WorkloadReference `json:"owner"`
+	// ContainerName is the container of the workload, only set for container level metrics.
+	// +optional
+	ContainerName string `json:"containerName,omitempty"`
+	// Metric is the name of the metric.
+	Metric MetricName `json:"metric"`
+
+	// Mean is the mean value of samples.
+	Mean resource.Quantity `json:"mean"`
+	// StdDev is the standard deviation of samples.
+	StdDev resource.Quantity `json:"stdDev"`
+	// P50 is the 50th percentile of samples.
+	// +optional
+	P50 *resource.Quantity `json:"p50,omitempty"`
+	// P90 is the 90th percentile of samples.
+	// +optional
+	P90 *resource.Quantity `json:"p90,omitempty"`
+	// P99 is the 99th percentile of samples.
+	// +optional
+	P99 *resource.Quantity `json:"p99,omitempty"`
+	// SampleCount is the number of samples the baseline is calculated from.
+	SampleCount int64 `json:"sampleCount"`
+
+	// WindowStart is the timestamp of the earliest sample.
+	WindowStart metav1.Time `json:"windowStart"`
+	// WindowEnd is the timestamp of the latest sample.
+	WindowEnd metav1.Time `json:"windowEnd"`
+	// LastUpdateTime is the last time the baseline was updated.
+	LastUpdateTime metav1.Time `json:"lastUpdateTime"`
+}
+
+const (
+	// MaxBaselines is the maximum number of baselines in the status of a rule, which keeps the rule far below the
+	// etcd object size limit no matter how many workloads are selected. The baselines omitted are still used in
+	// detection.
+	MaxBaselines = 500
+)
+
+const (
+	// RuleConditionMetricAvailable indicates whether the metric of the rule can be queried from the metric provider.
+	RuleConditionMetricAvailable string = "MetricAvailable"
+	// RuleConditionBaselineReady indicates whether the baselines of selected workloads have been calculated.
+	RuleConditionBaselineReady string = "BaselineReady"
+)
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Metric",type=string,JSONPath=`.spec.metric.name`
+//+kubebuilder:printcolumn:name="Algorithm",type=string,JSONPath=`.spec.algorithm.type`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="BaselineReady")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // InterferenceDetectionRule is the Schema for the interferencedetectionrules API
 type InterferenceDetectionRule struct {
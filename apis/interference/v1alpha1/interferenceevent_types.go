@@ -0,0 +1,150 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// InterferenceEvent CRD records that a pod is being interfered, which is written by the interference manager when
+// the latest sample of the pod exceeds the threshold of an InterferenceDetectionRule. Consecutive detections of the
+// same pod and container update the same event, and the event is deleted after ExpireTime once the interference
+// stops.
+
+// InterferenceSeverity is how severely a pod is interfered, judged by the ratio of the observed value to the
+// threshold.
+// +kubebuilder:validation:Enum=Low;Medium;High
+type InterferenceSeverity string
+
+const (
+	// InterferenceSeverityLow means the observed value exceeds the threshold by less than 50%.
+	InterferenceSeverityLow InterferenceSeverity = "Low"
+	// InterferenceSeverityMedium means the observed value exceeds the threshold by 50% to 100%.
+	InterferenceSeverityMedium InterferenceSeverity = "Medium"
+	// InterferenceSeverityHigh means the observed value is at least twice the threshold.
+	InterferenceSeverityHigh InterferenceSeverity = "High"
+)
+
+// InterferenceEventSpec defines the desired state of InterferenceEvent
+type InterferenceEventSpec struct {
+	// RuleName is the name of the InterferenceDetectionRule in the same namespace that fires the event.
+	RuleName string `json:"ruleName"`
+
+	// Target is the interfered pod.
+	Target InterferenceTarget `json:"target"`
+
+	// Metric is the name of the metric exceeding the threshold.
+	Metric MetricName `json:"metric"`
+	// ObservedValue is the latest value of the metric.
+	ObservedValue resource.Quantity `json:"observedValue"`
+	// BaselineMean is the mean value of the baseline of the target's workload.
+	// +optional
+	BaselineMean *resource.Quantity `json:"baselineMean,omitempty"`
+	// Threshold is the upper bound of the metric calculated by the algorithm of the rule.
+	Threshold resource.Quantity `json:"threshold"`
+	// Severity is how severely the target is interfered.
+	Severity InterferenceSeverity `json:"severity"`
+
+	// Aggressors are the pods of any namespace on the same node suspected to interfere the target, ordered by
+	// suspicion.
+	// +kubebuilder:validation:MaxItems=5
+	// +optional
+	Aggressors []AggressorPod `json:"aggressors,omitempty"`
+
+	// FirstDetectedTime is the time the interference was first detected.
+	FirstDetectedTime metav1.Time `json:"firstDetectedTime"`
+	// LastDetectedTime is the time the interference was last detected.
+	LastDetectedTime metav1.Time `json:"lastDetectedTime"`
+	// Count is the number of evaluations that detected the interference.
+	// +kubebuilder:validation:Minimum=1
+	Count int32 `json:"count"`
+	// ExpireTime is the time after which the event is deleted, it is postponed whenever the interference is
+	// detected again.
+	ExpireTime metav1.Time `json:"expireTime"`
+}
+
+// InterferenceTarget identifies the interfered pod and container.
+type InterferenceTarget struct {
+	// Namespace of the pod.
+	Namespace string `json:"namespace"`
+	// Name of the pod.
+	Name string `json:"name"`
+	// UID of the pod.
+	UID types.UID `json:"uid"`
+	// ContainerName is the interfered container, empty for pod level metrics.
+	// +optional
+	ContainerName string `json:"containerName,omitempty"`
+	// NodeName is the node the pod runs on.
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+	// Owner is the workload of the pod.
+	Owner WorkloadReference `json:"owner"`
+}
+
+// AggressorPod is a pod suspected to interfere the target.
+type AggressorPod struct {
+	// Namespace of the pod.
+	Namespace string `json:"namespace"`
+	// Name of the pod.
+	Name string `json:"name"`
+	// UID of the pod.
+	UID types.UID `json:"uid"`
+	// QOSClass of the pod, pods of lower QoS classes are more suspected.
+	// +optional
+	QOSClass string `json:"qosClass,omitempty"`
+	// CPURequest is the sum of the CPU requests of the pod's containers.
+	// +optional
+	CPURequest *resource.Quantity `json:"cpuRequest,omitempty"`
+}
+
+// InterferenceEventStatus defines the observed state of InterferenceEvent
+type InterferenceEventStatus struct {
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Pod",type=string,JSONPath=`.spec.target.name`
+//+kubebuilder:printcolumn:name="Metric",type=string,JSONPath=`.spec.metric`
+//+kubebuilder:printcolumn:name="Severity",type=string,JSONPath=`.spec.severity`
+//+kubebuilder:printcolumn:name="Observed",type=string,JSONPath=`.spec.observedValue`
+//+kubebuilder:printcolumn:name="Threshold",type=string,JSONPath=`.spec.threshold`
+//+kubebuilder:printcolumn:name="Count",type=integer,JSONPath=`.spec.count`
+//+kubebuilder:printcolumn:name="Last",type=date,JSONPath=`.spec.lastDetectedTime`
+
+// InterferenceEvent is the Schema for the interferenceevents API
+type InterferenceEvent struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   InterferenceEventSpec   `json:"spec,omitempty"`
+	Status InterferenceEventStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// InterferenceEventList contains a list of InterferenceEvent
+type InterferenceEventList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []InterferenceEvent `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&InterferenceEvent{}, &InterferenceEventList{})
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -28,23 +29,117 @@ import (
 // memory which is not safe enough. By using this CRD these metrics can be restored to initialize the model after
 // restart.
 
+const (
+	// CheckpointVersion is the version of the checkpoint format.
+	CheckpointVersion = "v1"
+
+	// MaxCheckpointContainers is the maximum number of container histograms in a checkpoint.
+	MaxCheckpointContainers = 32
+	// MaxCheckpointBuckets is the maximum number of non-empty buckets in a histogram checkpoint. Along with
+	// MaxCheckpointContainers it keeps a checkpoint far below the etcd object size limit, since samples of all pods
+	// of a workload are aggregated into the same histograms regardless of the number of replicas.
+	MaxCheckpointBuckets = 512
+	// MaxCheckpointWeight is the maximum bucket weight after the weights are normalized.
+	MaxCheckpointWeight uint32 = 10000
+)
+
 // InterferenceMetricCheckpointSpec defines the desired state of InterferenceMetricCheckpoint
 type InterferenceMetricCheckpointSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Version is the version of the checkpoint format, checkpoints of unknown versions are ignored on restore.
+	Version string `json:"version"`
+
+	// RuleName is the name of the InterferenceDetectionRule in the same namespace that the checkpoint belongs to.
+	RuleName string `json:"ruleName"`
+
+	// Owner is the workload that the checkpoint belongs to.
+	Owner WorkloadReference `json:"owner"`
+
+	// Metric is the name of the metric aggregated in histograms.
+	Metric MetricName `json:"metric"`
+
+	// Histograms are the aggregated histograms of each container of the workload. For pod level metrics there is
+	// only one histogram without container name.
+	// +kubebuilder:validation:MaxItems=32
+	// +optional
+	Histograms []ContainerHistogramCheckpoint `json:"histograms,omitempty"`
+
+	// LastUpdateTime is the time the checkpoint was written.
+	// +optional
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// ContainerHistogramCheckpoint is the checkpoint of the histogram of a container.
+type ContainerHistogramCheckpoint struct {
+	// ContainerName is the name of the container, empty for pod level metrics.
+	// +optional
+	ContainerName string `json:"containerName,omitempty"`
+
+	// Histogram is the serialized histogram.
+	Histogram HistogramCheckpoint `json:"histogram"`
+
+	// FirstSampleTime is the timestamp of the first sample aggregated.
+	// +optional
+	FirstSampleTime metav1.Time `json:"firstSampleTime,omitempty"`
+	// LastSampleTime is the timestamp of the last sample aggregated.
+	// +optional
+	LastSampleTime metav1.Time `json:"lastSampleTime,omitempty"`
+	// TotalSamplesCount is the number of samples aggregated.
+	// +optional
+	TotalSamplesCount int64 `json:"totalSamplesCount,omitempty"`
+}
+
+// HistogramCheckpoint is the serialized form of a decaying exponential histogram.
+type HistogramCheckpoint struct {
+	// Options describe the bucket boundaries of the histogram.
+	Options HistogramOptions `json:"options"`
+
+	// ReferenceTimestamp is the time that bucket weights are decayed relative to.
+	// +optional
+	ReferenceTimestamp metav1.Time `json:"referenceTimestamp,omitempty"`
+
+	// BucketWeights are the non-empty buckets of the histogram, weights are normalized so that the maximum one
+	// equals to MaxCheckpointWeight.
+	// +kubebuilder:validation:MaxItems=512
+	// +optional
+	BucketWeights []BucketWeight `json:"bucketWeights,omitempty"`
+
+	// TotalWeight is the sum of bucket weights before normalization.
+	TotalWeight resource.Quantity `json:"totalWeight"`
+}
+
+// HistogramOptions describe exponential buckets, where the n-th bucket (n >= 1) starts at
+// FirstBucketSize * (1 + Ratio + Ratio^2 + ... + Ratio^(n-1)) until MaxValue.
+type HistogramOptions struct {
+	// MaxValue is the upper bound of the last bucket.
+	MaxValue resource.Quantity `json:"maxValue"`
+	// FirstBucketSize is the size of the first bucket.
+	FirstBucketSize resource.Quantity `json:"firstBucketSize"`
+	// Ratio is the ratio between the sizes of two consecutive buckets.
+	Ratio resource.Quantity `json:"ratio"`
+	// HalfLife is the time after which the weight of a sample is halved.
+	HalfLife metav1.Duration `json:"halfLife"`
+}
 
-	// Foo is an example field of InterferenceMetricCheckpoint. Edit interferencemetriccheckpoint_types.go to remove/update
-	Foo string `json:"foo,omitempty"`
+// BucketWeight is the weight of a bucket in the histogram.
+type BucketWeight struct {
+	// Index of the bucket.
+	// +kubebuilder:validation:Minimum=0
+	Index int32 `json:"index"`
+	// Weight of the bucket.
+	Weight uint32 `json:"weight"`
 }
 
 // InterferenceMetricCheckpointStatus defines the observed state of InterferenceMetricCheckpoint
 type InterferenceMetricCheckpointStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Rule",type=string,JSONPath=`.spec.ruleName`
+//+kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.spec.owner.kind`
+//+kubebuilder:printcolumn:name="Workload",type=string,JSONPath=`.spec.owner.name`
+//+kubebuilder:printcolumn:name="Metric",type=string,JSONPath=`.spec.metric`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // InterferenceMetricCheckpoint is the Schema for the interferencemetriccheckpoints API
 type InterferenceMetricCheckpoint struct {
@@ -22,16 +22,157 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AggressorPod) DeepCopyInto(out *AggressorPod) {
+	*out = *in
+	if in.CPURequest != nil {
+		in, out := &in.CPURequest, &out.CPURequest
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AggressorPod.
+func (in *AggressorPod) DeepCopy() *AggressorPod {
+	if in == nil {
+		return nil
+	}
+	out := new(AggressorPod)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BucketWeight) DeepCopyInto(out *BucketWeight) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BucketWeight.
+func (in *BucketWeight) DeepCopy() *BucketWeight {
+	if in == nil {
+		return nil
+	}
+	out := new(BucketWeight)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerHistogramCheckpoint) DeepCopyInto(out *ContainerHistogramCheckpoint) {
+	*out = *in
+	in.Histogram.DeepCopyInto(&out.Histogram)
+	in.FirstSampleTime.DeepCopyInto(&out.FirstSampleTime)
+	in.LastSampleTime.DeepCopyInto(&out.LastSampleTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerHistogramCheckpoint.
+func (in *ContainerHistogramCheckpoint) DeepCopy() *ContainerHistogramCheckpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerHistogramCheckpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerInterference) DeepCopyInto(out *ContainerInterference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerInterference.
+func (in *ContainerInterference) DeepCopy() *ContainerInterference {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerInterference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DetectionAlgorithm) DeepCopyInto(out *DetectionAlgorithm) {
+	*out = *in
+	if in.StaticThreshold != nil {
+		in, out := &in.StaticThreshold, &out.StaticThreshold
+		*out = new(StaticThresholdArgs)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MeanStdDev != nil {
+		in, out := &in.MeanStdDev, &out.MeanStdDev
+		*out = new(MeanStdDevArgs)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Percentile != nil {
+		in, out := &in.Percentile, &out.Percentile
+		*out = new(PercentileArgs)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DetectionAlgorithm.
+func (in *DetectionAlgorithm) DeepCopy() *DetectionAlgorithm {
+	if in == nil {
+		return nil
+	}
+	out := new(DetectionAlgorithm)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HistogramCheckpoint) DeepCopyInto(out *HistogramCheckpoint) {
+	*out = *in
+	in.Options.DeepCopyInto(&out.Options)
+	in.ReferenceTimestamp.DeepCopyInto(&out.ReferenceTimestamp)
+	if in.BucketWeights != nil {
+		in, out := &in.BucketWeights, &out.BucketWeights
+		*out = make([]BucketWeight, len(*in))
+		copy(*out, *in)
+	}
+	out.TotalWeight = in.TotalWeight.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HistogramCheckpoint.
+func (in *HistogramCheckpoint) DeepCopy() *HistogramCheckpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(HistogramCheckpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HistogramOptions) DeepCopyInto(out *HistogramOptions) {
+	*out = *in
+	out.MaxValue = in.MaxValue.DeepCopy()
+	out.FirstBucketSize = in.FirstBucketSize.DeepCopy()
+	out.Ratio = in.Ratio.DeepCopy()
+	out.HalfLife = in.HalfLife
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HistogramOptions.
+func (in *HistogramOptions) DeepCopy() *HistogramOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(HistogramOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InterferenceDetectionRule) DeepCopyInto(out *InterferenceDetectionRule) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InterferenceDetectionRule.
@@ -87,6 +228,33 @@ func (in *InterferenceDetectionRuleList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InterferenceDetectionRuleSpec) DeepCopyInto(out *InterferenceDetectionRuleSpec) {
 	*out = *in
+	out.Metric = in.Metric
+	if in.WorkloadSelector != nil {
+		in, out := &in.WorkloadSelector, &out.WorkloadSelector
+		*out = new(WorkloadSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Algorithm.DeepCopyInto(&out.Algorithm)
+	if in.EvaluationWindow != nil {
+		in, out := &in.EvaluationWindow, &out.EvaluationWindow
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.EvaluationInterval != nil {
+		in, out := &in.EvaluationInterval, &out.EvaluationInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MinSampleCount != nil {
+		in, out := &in.MinSampleCount, &out.MinSampleCount
+		*out = new(int64)
+		**out = **in
+	}
+	if in.EventTTL != nil {
+		in, out := &in.EventTTL, &out.EventTTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InterferenceDetectionRuleSpec.
@@ -102,6 +270,20 @@ func (in *InterferenceDetectionRuleSpec) DeepCopy() *InterferenceDetectionRuleSp
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InterferenceDetectionRuleStatus) DeepCopyInto(out *InterferenceDetectionRuleStatus) {
 	*out = *in
+	if in.Baselines != nil {
+		in, out := &in.Baselines, &out.Baselines
+		*out = make([]WorkloadBaseline, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InterferenceDetectionRuleStatus.
@@ -114,12 +296,119 @@ func (in *InterferenceDetectionRuleStatus) DeepCopy() *InterferenceDetectionRule
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InterferenceEvent) DeepCopyInto(out *InterferenceEvent) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InterferenceEvent.
+func (in *InterferenceEvent) DeepCopy() *InterferenceEvent {
+	if in == nil {
+		return nil
+	}
+	out := new(InterferenceEvent)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *InterferenceEvent) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InterferenceEventList) DeepCopyInto(out *InterferenceEventList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]InterferenceEvent, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InterferenceEventList.
+func (in *InterferenceEventList) DeepCopy() *InterferenceEventList {
+	if in == nil {
+		return nil
+	}
+	out := new(InterferenceEventList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *InterferenceEventList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InterferenceEventSpec) DeepCopyInto(out *InterferenceEventSpec) {
+	*out = *in
+	out.Target = in.Target
+	out.ObservedValue = in.ObservedValue.DeepCopy()
+	if in.BaselineMean != nil {
+		in, out := &in.BaselineMean, &out.BaselineMean
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	out.Threshold = in.Threshold.DeepCopy()
+	if in.Aggressors != nil {
+		in, out := &in.Aggressors, &out.Aggressors
+		*out = make([]AggressorPod, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.FirstDetectedTime.DeepCopyInto(&out.FirstDetectedTime)
+	in.LastDetectedTime.DeepCopyInto(&out.LastDetectedTime)
+	in.ExpireTime.DeepCopyInto(&out.ExpireTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InterferenceEventSpec.
+func (in *InterferenceEventSpec) DeepCopy() *InterferenceEventSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(InterferenceEventSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InterferenceEventStatus) DeepCopyInto(out *InterferenceEventStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InterferenceEventStatus.
+func (in *InterferenceEventStatus) DeepCopy() *InterferenceEventStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(InterferenceEventStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InterferenceMetricCheckpoint) DeepCopyInto(out *InterferenceMetricCheckpoint) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	out.Status = in.Status
 }
 
@@ -176,6 +465,15 @@ func (in *InterferenceMetricCheckpointList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InterferenceMetricCheckpointSpec) DeepCopyInto(out *InterferenceMetricCheckpointSpec) {
 	*out = *in
+	out.Owner = in.Owner
+	if in.Histograms != nil {
+		in, out := &in.Histograms, &out.Histograms
+		*out = make([]ContainerHistogramCheckpoint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InterferenceMetricCheckpointSpec.
@@ -202,3 +500,233 @@ func (in *InterferenceMetricCheckpointStatus) DeepCopy() *InterferenceMetricChec
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InterferenceTarget) DeepCopyInto(out *InterferenceTarget) {
+	*out = *in
+	out.Owner = in.Owner
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InterferenceTarget.
+func (in *InterferenceTarget) DeepCopy() *InterferenceTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(InterferenceTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InterferenceVictim) DeepCopyInto(out *InterferenceVictim) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InterferenceVictim.
+func (in *InterferenceVictim) DeepCopy() *InterferenceVictim {
+	if in == nil {
+		return nil
+	}
+	out := new(InterferenceVictim)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MeanStdDevArgs) DeepCopyInto(out *MeanStdDevArgs) {
+	*out = *in
+	if in.StdDevFactor != nil {
+		in, out := &in.StdDevFactor, &out.StdDevFactor
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MeanStdDevArgs.
+func (in *MeanStdDevArgs) DeepCopy() *MeanStdDevArgs {
+	if in == nil {
+		return nil
+	}
+	out := new(MeanStdDevArgs)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricSource) DeepCopyInto(out *MetricSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricSource.
+func (in *MetricSource) DeepCopy() *MetricSource {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeInterference) DeepCopyInto(out *NodeInterference) {
+	*out = *in
+	if in.Victims != nil {
+		in, out := &in.Victims, &out.Victims
+		*out = make([]InterferenceVictim, len(*in))
+		copy(*out, *in)
+	}
+	if in.Aggressors != nil {
+		in, out := &in.Aggressors, &out.Aggressors
+		*out = make([]AggressorPod, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeInterference.
+func (in *NodeInterference) DeepCopy() *NodeInterference {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeInterference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PercentileArgs) DeepCopyInto(out *PercentileArgs) {
+	*out = *in
+	if in.Percentile != nil {
+		in, out := &in.Percentile, &out.Percentile
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Tolerance != nil {
+		in, out := &in.Tolerance, &out.Tolerance
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PercentileArgs.
+func (in *PercentileArgs) DeepCopy() *PercentileArgs {
+	if in == nil {
+		return nil
+	}
+	out := new(PercentileArgs)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodInterference) DeepCopyInto(out *PodInterference) {
+	*out = *in
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]ContainerInterference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodInterference.
+func (in *PodInterference) DeepCopy() *PodInterference {
+	if in == nil {
+		return nil
+	}
+	out := new(PodInterference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StaticThresholdArgs) DeepCopyInto(out *StaticThresholdArgs) {
+	*out = *in
+	out.Threshold = in.Threshold.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StaticThresholdArgs.
+func (in *StaticThresholdArgs) DeepCopy() *StaticThresholdArgs {
+	if in == nil {
+		return nil
+	}
+	out := new(StaticThresholdArgs)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkloadBaseline) DeepCopyInto(out *WorkloadBaseline) {
+	*out = *in
+	out.Owner = in.Owner
+	out.Mean = in.Mean.DeepCopy()
+	out.StdDev = in.StdDev.DeepCopy()
+	if in.P50 != nil {
+		in, out := &in.P50, &out.P50
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.P90 != nil {
+		in, out := &in.P90, &out.P90
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.P99 != nil {
+		in, out := &in.P99, &out.P99
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	in.WindowStart.DeepCopyInto(&out.WindowStart)
+	in.WindowEnd.DeepCopyInto(&out.WindowEnd)
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadBaseline.
+func (in *WorkloadBaseline) DeepCopy() *WorkloadBaseline {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkloadBaseline)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkloadReference) DeepCopyInto(out *WorkloadReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadReference.
+func (in *WorkloadReference) DeepCopy() *WorkloadReference {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkloadReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkloadSelector) DeepCopyInto(out *WorkloadSelector) {
+	*out = *in
+	if in.PodSelector != nil {
+		in, out := &in.PodSelector, &out.PodSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OwnerKinds != nil {
+		in, out := &in.OwnerKinds, &out.OwnerKinds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadSelector.
+func (in *WorkloadSelector) DeepCopy() *WorkloadSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkloadSelector)
+	in.DeepCopyInto(out)
+	return out
+}
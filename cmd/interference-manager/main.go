@@ -18,8 +18,11 @@ package main
 
 import (
 	"flag"
+	"net/http"
 	"os"
+	"time"
 
+	slov1alpha1 "github.com/koordinator-sh/koordinator/apis/slo/v1alpha1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -27,9 +30,12 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+	"github.com/koordinator-sh/koordetector/cmd/interference-manager/options"
 	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/controllers"
+	metricprovider "github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider"
 )
 
 var (
@@ -41,6 +47,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(interferencev1alpha1.AddToScheme(scheme))
+	utilruntime.Must(slov1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -48,11 +55,21 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var checkpointInterval time.Duration
+	var podAnnotationFeedback, nodeAnnotationFeedback bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&checkpointInterval, "checkpoint-interval", 10*time.Minute,
+		"The interval at which the aggregated metrics are written into InterferenceMetricCheckpoints.")
+	flag.BoolVar(&podAnnotationFeedback, "pod-annotation-feedback", false,
+		"Annotate the interfered pods with their InterferenceEvents, which patches the pods of every affected node.")
+	flag.BoolVar(&nodeAnnotationFeedback, "node-annotation-feedback", true,
+		"Annotate the nodes with the InterferenceEvents of their pods.")
+	providerOptions := options.NewOptions()
+	providerOptions.AddFlags(flag.CommandLine)
 	opts := zap.Options{
 		Development: true,
 	}
@@ -61,6 +78,15 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if err := providerOptions.Complete(); err != nil {
+		setupLog.Error(err, "unable to load config")
+		os.Exit(1)
+	}
+	if err := providerOptions.Validate(); err != nil {
+		setupLog.Error(err, "invalid config")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -85,20 +111,61 @@ func main() {
 		os.Exit(1)
 	}
 
+	ctx := ctrl.SetupSignalHandler()
+	providerConfig := &providerOptions.Config.MetricProvider
+	metricProvider, err := metricprovider.NewMetricsProvider(
+		options.ToProviderConfig(providerConfig, mgr.GetConfig(), mgr.GetCache()))
+	if err != nil {
+		setupLog.Error(err, "unable to create metric provider", "provider", providerConfig.Type)
+		os.Exit(1)
+	}
+	// providers serving from their own endpoints run with the manager
+	if runnable, ok := metricProvider.(manager.Runnable); ok {
+		if err = mgr.Add(runnable); err != nil {
+			setupLog.Error(err, "unable to add metric provider", "provider", providerConfig.Type)
+			os.Exit(1)
+		}
+	}
+	setupLog.Info("created metric provider", "provider", providerConfig.Type)
+
+	sampleStore := controllers.NewSampleStore()
 	if err = (&controllers.InterferenceMetricCheckpointReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		Store:              sampleStore,
+		CheckpointInterval: checkpointInterval,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "InterferenceMetricCheckpoint")
 		os.Exit(1)
 	}
 	if err = (&controllers.InterferenceDetectionRuleReconciler{
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Store:          sampleStore,
+		MetricProvider: metricProvider,
+		PollInterval:   providerConfig.PollInterval.Duration,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "InterferenceDetectionRule")
+		os.Exit(1)
+	}
+	if err = (&controllers.InterferenceEventReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "InterferenceDetectionRule")
+		setupLog.Error(err, "unable to create controller", "controller", "InterferenceEvent")
 		os.Exit(1)
 	}
+	if podAnnotationFeedback || nodeAnnotationFeedback {
+		if err = (&controllers.InterferenceFeedbackReconciler{
+			Client:         mgr.GetClient(),
+			Scheme:         mgr.GetScheme(),
+			PodAnnotation:  podAnnotationFeedback,
+			NodeAnnotation: nodeAnnotationFeedback,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "InterferenceFeedback")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -109,9 +176,17 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if checker, ok := metricProvider.(metricprovider.HealthChecker); ok {
+		if err := mgr.AddReadyzCheck("metric-provider", func(req *http.Request) error {
+			return checker.CheckHealth(req.Context())
+		}); err != nil {
+			setupLog.Error(err, "unable to set up metric provider check")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
@@ -0,0 +1,320 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/config"
+)
+
+const (
+	// ConfigAPIVersion is the version of the config file, which changes when fields are changed incompatibly.
+	ConfigAPIVersion = "config.interference.koordinator.sh/v1alpha1"
+	ConfigKind       = "InterferenceManagerConfiguration"
+
+	defaultProviderType       = common.PrometheusProvider
+	defaultPrometheusAddress  = "http://localhost:9090"
+	defaultQueryTimeout       = 10 * time.Second
+	defaultPollInterval       = time.Minute
+	defaultServiceProxyScheme = "http"
+)
+
+// InterferenceManagerConfiguration is the config file of the interference manager.
+type InterferenceManagerConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	MetricProvider MetricProviderConfiguration `json:"metricProvider"`
+}
+
+// MetricProviderConfiguration configures the provider where the interference manager gets metrics.
+type MetricProviderConfiguration struct {
+	// Type is the type of the provider, prometheus_provider by default.
+	Type common.ProviderType `json:"type,omitempty"`
+	// QueryTimeout is the timeout of queries, 10s by default.
+	QueryTimeout metav1.Duration `json:"queryTimeout,omitempty"`
+	// PollInterval is the interval to query metrics for rules without an evaluation interval, 1m by default.
+	PollInterval metav1.Duration `json:"pollInterval,omitempty"`
+
+	Prometheus    PrometheusConfiguration    `json:"prometheus,omitempty"`
+	CustomMetrics CustomMetricsConfiguration `json:"customMetrics,omitempty"`
+	NodeMetric    NodeMetricConfiguration    `json:"nodeMetric,omitempty"`
+	Ingestion     IngestionConfiguration     `json:"ingestion,omitempty"`
+}
+
+type PrometheusConfiguration struct {
+	// Address is the url of Prometheus, http://localhost:9090 by default.
+	Address string `json:"address,omitempty"`
+	// CacheTTL is how long the results of identical queries are shared, 15s by default and disabled if negative.
+	CacheTTL metav1.Duration `json:"cacheTTL,omitempty"`
+	// Lookback is how long a series is returned after its last sample, the lookback of Prometheus (5m) is used
+	// if it is not set.
+	Lookback        metav1.Duration            `json:"lookback,omitempty"`
+	BearerTokenFile string                     `json:"bearerTokenFile,omitempty"`
+	BasicAuth       *BasicAuthConfiguration    `json:"basicAuth,omitempty"`
+	TLS             TLSConfiguration           `json:"tls,omitempty"`
+	TenantID        string                     `json:"tenantID,omitempty"`
+	Headers         map[string]string          `json:"headers,omitempty"`
+	ServiceProxy    *ServiceProxyConfiguration `json:"serviceProxy,omitempty"`
+}
+
+type BasicAuthConfiguration struct {
+	Username     string `json:"username"`
+	PasswordFile string `json:"passwordFile"`
+}
+
+type TLSConfiguration struct {
+	CAFile             string `json:"caFile,omitempty"`
+	CertFile           string `json:"certFile,omitempty"`
+	KeyFile            string `json:"keyFile,omitempty"`
+	ServerName         string `json:"serverName,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+// ServiceProxyConfiguration accesses Prometheus by the service proxy of the apiserver.
+type ServiceProxyConfiguration struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Scheme is http or https, http by default.
+	Scheme string `json:"scheme,omitempty"`
+	Port   string `json:"port,omitempty"`
+}
+
+type CustomMetricsConfiguration struct {
+	// API is custom or external, external by default.
+	API config.MetricsAPI `json:"api,omitempty"`
+	// Namespace is queried by the metrics without the pod_namespace filter, "default" by default.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type NodeMetricConfiguration struct {
+	MaxStaleness metav1.Duration `json:"maxStaleness,omitempty"`
+}
+
+type IngestionConfiguration struct {
+	BindAddress     string          `json:"bindAddress,omitempty"`
+	CertFile        string          `json:"certFile,omitempty"`
+	KeyFile         string          `json:"keyFile,omitempty"`
+	AllowedUsers    []string        `json:"allowedUsers,omitempty"`
+	Audiences       []string        `json:"audiences,omitempty"`
+	QueueSize       int             `json:"queueSize,omitempty"`
+	MaxBatchSamples int             `json:"maxBatchSamples,omitempty"`
+	MaxSeries       int             `json:"maxSeries,omitempty"`
+	Retention       metav1.Duration `json:"retention,omitempty"`
+	Staleness       metav1.Duration `json:"staleness,omitempty"`
+}
+
+// LoadConfigFile decodes the config file strictly into @cfg, so that misspelled fields are reported.
+func LoadConfigFile(path string, cfg *InterferenceManagerConfiguration) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return fmt.Errorf("failed to decode config file %v: %v", path, err)
+	}
+	if cfg.APIVersion != ConfigAPIVersion || cfg.Kind != ConfigKind {
+		return fmt.Errorf("config file %v is %v %v, expected %v %v", path, cfg.APIVersion, cfg.Kind,
+			ConfigAPIVersion, ConfigKind)
+	}
+	return nil
+}
+
+// SetDefaults sets the defaults of the unset fields.
+func SetDefaults(cfg *InterferenceManagerConfiguration) {
+	cfg.APIVersion, cfg.Kind = ConfigAPIVersion, ConfigKind
+	provider := &cfg.MetricProvider
+	if provider.Type == "" {
+		provider.Type = defaultProviderType
+	}
+	if provider.QueryTimeout.Duration == 0 {
+		provider.QueryTimeout.Duration = defaultQueryTimeout
+	}
+	if provider.PollInterval.Duration == 0 {
+		provider.PollInterval.Duration = defaultPollInterval
+	}
+	if provider.Prometheus.Address == "" && provider.Prometheus.ServiceProxy == nil {
+		provider.Prometheus.Address = defaultPrometheusAddress
+	}
+	if proxy := provider.Prometheus.ServiceProxy; proxy != nil && proxy.Scheme == "" {
+		proxy.Scheme = defaultServiceProxyScheme
+	}
+	if provider.CustomMetrics.API == "" {
+		provider.CustomMetrics.API = config.ExternalMetricsAPI
+	}
+}
+
+// Validate validates the defaulted config, only the options of the selected provider are validated.
+func Validate(cfg *InterferenceManagerConfiguration) field.ErrorList {
+	var errs field.ErrorList
+	path := field.NewPath("metricProvider")
+	provider := &cfg.MetricProvider
+	if provider.QueryTimeout.Duration <= 0 {
+		errs = append(errs, field.Invalid(path.Child("queryTimeout"), provider.QueryTimeout.Duration, "must be positive"))
+	}
+	if provider.PollInterval.Duration <= 0 {
+		errs = append(errs, field.Invalid(path.Child("pollInterval"), provider.PollInterval.Duration, "must be positive"))
+	}
+
+	switch provider.Type {
+	case common.PrometheusProvider:
+		errs = append(errs, validatePrometheus(&provider.Prometheus, path.Child("prometheus"))...)
+	case common.CustomMetricsProvider:
+		switch provider.CustomMetrics.API {
+		case config.CustomMetricsAPI, config.ExternalMetricsAPI:
+		default:
+			errs = append(errs, field.NotSupported(path.Child("customMetrics", "api"), provider.CustomMetrics.API,
+				[]string{string(config.CustomMetricsAPI), string(config.ExternalMetricsAPI)}))
+		}
+	case common.NodeMetricProvider:
+		if provider.NodeMetric.MaxStaleness.Duration < 0 {
+			errs = append(errs, field.Invalid(path.Child("nodeMetric", "maxStaleness"),
+				provider.NodeMetric.MaxStaleness.Duration, "must not be negative"))
+		}
+	case common.IngestionProvider:
+		ingestionPath := path.Child("ingestion")
+		if provider.Ingestion.BindAddress == "" {
+			errs = append(errs, field.Required(ingestionPath.Child("bindAddress"), ""))
+		}
+		// clients send their bearer tokens, which must not be sent in plain HTTP
+		if provider.Ingestion.CertFile == "" {
+			errs = append(errs, field.Required(ingestionPath.Child("certFile"), ""))
+		}
+		if provider.Ingestion.KeyFile == "" {
+			errs = append(errs, field.Required(ingestionPath.Child("keyFile"), ""))
+		}
+		if len(provider.Ingestion.AllowedUsers) == 0 {
+			errs = append(errs, field.Required(ingestionPath.Child("allowedUsers"), ""))
+		}
+	default:
+		errs = append(errs, field.NotSupported(path.Child("type"), provider.Type, []string{
+			string(common.PrometheusProvider), string(common.CustomMetricsProvider),
+			string(common.NodeMetricProvider), string(common.IngestionProvider),
+		}))
+	}
+	return errs
+}
+
+func validatePrometheus(prom *PrometheusConfiguration, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if prom.Lookback.Duration < 0 || prom.Lookback.Duration%time.Millisecond != 0 {
+		errs = append(errs, field.Invalid(path.Child("lookback"), prom.Lookback.Duration,
+			"must not be negative and must be in milliseconds"))
+	}
+	if proxy := prom.ServiceProxy; proxy != nil {
+		proxyPath := path.Child("serviceProxy")
+		if proxy.Namespace == "" {
+			errs = append(errs, field.Required(proxyPath.Child("namespace"), ""))
+		}
+		if proxy.Name == "" {
+			errs = append(errs, field.Required(proxyPath.Child("name"), ""))
+		}
+		if proxy.Scheme != "http" && proxy.Scheme != "https" {
+			errs = append(errs, field.NotSupported(proxyPath.Child("scheme"), proxy.Scheme, []string{"http", "https"}))
+		}
+		return errs
+	}
+
+	if u, err := url.Parse(prom.Address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, field.Invalid(path.Child("address"), prom.Address, "must be an http or https url"))
+	}
+	if prom.BasicAuth != nil {
+		if prom.BearerTokenFile != "" {
+			errs = append(errs, field.Forbidden(path.Child("basicAuth"), "can not be set with bearerTokenFile"))
+		}
+		if prom.BasicAuth.Username == "" {
+			errs = append(errs, field.Required(path.Child("basicAuth", "username"), ""))
+		}
+	}
+	if (prom.TLS.CertFile == "") != (prom.TLS.KeyFile == "") {
+		errs = append(errs, field.Invalid(path.Child("tls", "certFile"), prom.TLS.CertFile, "must be set with keyFile"))
+	}
+	return errs
+}
+
+// ToProviderConfig converts the config of the metric provider to construct it with
+// metric_provider.NewMetricsProvider. Providers watching objects read them by @reader.
+func ToProviderConfig(cfg *MetricProviderConfiguration, restConfig *rest.Config, reader client.Reader) config.MetricProviderConfig {
+	providerConfig := config.MetricProviderConfig{
+		ProviderType: cfg.Type,
+		PromConf: config.PrometheusProviderConfig{
+			Address:         cfg.Prometheus.Address,
+			QueryTimeout:    cfg.QueryTimeout.Duration,
+			CacheTTL:        cfg.Prometheus.CacheTTL.Duration,
+			Lookback:        cfg.Prometheus.Lookback.Duration,
+			BearerTokenFile: cfg.Prometheus.BearerTokenFile,
+			TLS: config.TLSConfig{
+				CAFile:             cfg.Prometheus.TLS.CAFile,
+				CertFile:           cfg.Prometheus.TLS.CertFile,
+				KeyFile:            cfg.Prometheus.TLS.KeyFile,
+				ServerName:         cfg.Prometheus.TLS.ServerName,
+				InsecureSkipVerify: cfg.Prometheus.TLS.InsecureSkipVerify,
+			},
+			TenantID: cfg.Prometheus.TenantID,
+			Headers:  cfg.Prometheus.Headers,
+		},
+		CustomMetricsConf: config.CustomMetricsProviderConfig{
+			RestConfig:   restConfig,
+			API:          cfg.CustomMetrics.API,
+			Namespace:    cfg.CustomMetrics.Namespace,
+			QueryTimeout: cfg.QueryTimeout.Duration,
+		},
+		NodeMetricConf: config.NodeMetricProviderConfig{
+			Reader:       reader,
+			MaxStaleness: cfg.NodeMetric.MaxStaleness.Duration,
+		},
+		IngestionConf: config.IngestionProviderConfig{
+			RestConfig:      restConfig,
+			BindAddress:     cfg.Ingestion.BindAddress,
+			CertFile:        cfg.Ingestion.CertFile,
+			KeyFile:         cfg.Ingestion.KeyFile,
+			AllowedUsers:    cfg.Ingestion.AllowedUsers,
+			Audiences:       cfg.Ingestion.Audiences,
+			QueueSize:       cfg.Ingestion.QueueSize,
+			MaxBatchSamples: cfg.Ingestion.MaxBatchSamples,
+			MaxSeries:       cfg.Ingestion.MaxSeries,
+			Retention:       cfg.Ingestion.Retention.Duration,
+			Staleness:       cfg.Ingestion.Staleness.Duration,
+		},
+	}
+	if basicAuth := cfg.Prometheus.BasicAuth; basicAuth != nil {
+		providerConfig.PromConf.BasicAuth = &config.BasicAuthConfig{
+			Username:     basicAuth.Username,
+			PasswordFile: basicAuth.PasswordFile,
+		}
+	}
+	if proxy := cfg.Prometheus.ServiceProxy; proxy != nil {
+		providerConfig.PromConf.ServiceProxy = &config.ServiceProxyConfig{
+			RestConfig: restConfig,
+			Namespace:  proxy.Namespace,
+			Name:       proxy.Name,
+			Scheme:     proxy.Scheme,
+			Port:       proxy.Port,
+		}
+	}
+	return providerConfig
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+)
+
+// Options are the options of the metric provider, which are loaded from the config file and overridden by the
+// flags set explicitly.
+type Options struct {
+	ConfigFile string
+	Config     InterferenceManagerConfiguration
+
+	flagSet *flag.FlagSet
+}
+
+func NewOptions() *Options {
+	return &Options{}
+}
+
+// AddFlags binds the flags to the fields of the config.
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	o.flagSet = fs
+	provider := &o.Config.MetricProvider
+	fs.StringVar(&o.ConfigFile, "config", "",
+		fmt.Sprintf("The %v file of %v. Flags set explicitly take precedence over it.", ConfigKind, ConfigAPIVersion))
+	fs.Var((*providerTypeValue)(&provider.Type), "metric-provider",
+		"The type of the metric provider, one of prometheus_provider, custom_metrics_provider, node_metric_provider "+
+			"and ingestion_provider. Default to prometheus_provider.")
+	fs.DurationVar(&provider.QueryTimeout.Duration, "metric-query-timeout", 0,
+		"The timeout of metric queries. Default to 10s.")
+	fs.DurationVar(&provider.PollInterval.Duration, "metric-poll-interval", 0,
+		"The interval to query metrics for rules without an evaluation interval. Default to 1m.")
+
+	fs.StringVar(&provider.Prometheus.Address, "prometheus-address", "",
+		"The address of Prometheus. Default to http://localhost:9090.")
+	fs.DurationVar(&provider.Prometheus.CacheTTL.Duration, "prometheus-cache-ttl", 0,
+		"How long the results of identical queries to Prometheus are shared. Default to 15s, disabled if negative.")
+	fs.DurationVar(&provider.Prometheus.Lookback.Duration, "prometheus-lookback", 0,
+		"How long a series is returned after its last sample. Default to the lookback of Prometheus, which is 5m.")
+	fs.StringVar(&provider.Prometheus.BearerTokenFile, "prometheus-bearer-token-file", "",
+		"The file of the bearer token to access Prometheus.")
+	fs.StringVar(&provider.Prometheus.TLS.CAFile, "prometheus-ca-file", "", "The CA file to verify Prometheus.")
+	fs.StringVar(&provider.Prometheus.TLS.CertFile, "prometheus-cert-file", "",
+		"The client certificate file to access Prometheus.")
+	fs.StringVar(&provider.Prometheus.TLS.KeyFile, "prometheus-key-file", "",
+		"The client key file to access Prometheus.")
+	fs.StringVar(&provider.Prometheus.TenantID, "prometheus-tenant-id", "",
+		"The tenant sent as the X-Scope-OrgID header to multi-tenant Prometheus like Thanos and Cortex.")
+
+	fs.StringVar(&provider.Ingestion.BindAddress, "ingestion-bind-address", "",
+		"The address the endpoint receiving samples pushed by koordetector binds to, used by ingestion_provider.")
+	fs.StringVar(&provider.Ingestion.CertFile, "ingestion-cert-file", "", "The TLS certificate of the ingestion endpoint.")
+	fs.StringVar(&provider.Ingestion.KeyFile, "ingestion-key-file", "", "The TLS key of the ingestion endpoint.")
+	fs.Var((*stringSliceValue)(&provider.Ingestion.AllowedUsers), "ingestion-allowed-users",
+		"Comma-separated users allowed to push samples, e.g. system:serviceaccount:koordinator-system:koordetector, "+
+			"which is required by ingestion_provider.")
+	fs.Var((*stringSliceValue)(&provider.Ingestion.Audiences), "ingestion-audiences",
+		"Comma-separated audiences the tokens pushing samples are issued for, interference-manager by default.")
+}
+
+// Complete loads the config file after the flags are parsed, applies the flags set explicitly again over it, and
+// sets the defaults.
+func (o *Options) Complete() error {
+	if o.ConfigFile != "" {
+		setFlags := map[string]string{}
+		o.flagSet.Visit(func(f *flag.Flag) {
+			setFlags[f.Name] = f.Value.String()
+		})
+		if err := LoadConfigFile(o.ConfigFile, &o.Config); err != nil {
+			return err
+		}
+		for name, value := range setFlags {
+			if err := o.flagSet.Set(name, value); err != nil {
+				return fmt.Errorf("failed to apply flag %v: %v", name, err)
+			}
+		}
+	}
+	SetDefaults(&o.Config)
+	return nil
+}
+
+// Validate validates the completed options.
+func (o *Options) Validate() error {
+	return Validate(&o.Config).ToAggregate()
+}
+
+type providerTypeValue common.ProviderType
+
+func (v *providerTypeValue) String() string {
+	return string(*v)
+}
+
+func (v *providerTypeValue) Set(s string) error {
+	*v = providerTypeValue(s)
+	return nil
+}
+
+type stringSliceValue []string
+
+func (v *stringSliceValue) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *stringSliceValue) Set(s string) error {
+	*v = nil
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*v = append(*v, item)
+		}
+	}
+	return nil
+}
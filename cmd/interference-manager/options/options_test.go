@@ -0,0 +1,179 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/config"
+)
+
+const testConfigFile = `apiVersion: config.interference.koordinator.sh/v1alpha1
+kind: InterferenceManagerConfiguration
+metricProvider:
+  type: prometheus_provider
+  queryTimeout: 30s
+  prometheus:
+    address: https://thanos-query.monitoring:9090
+    bearerTokenFile: /var/run/secrets/prometheus/token
+    tenantID: team-a
+    tls:
+      caFile: /etc/prometheus/ca.crt
+`
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func parseOptions(t *testing.T, args ...string) (*Options, error) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := NewOptions()
+	o.AddFlags(fs)
+	assert.NoError(t, fs.Parse(args))
+	if err := o.Complete(); err != nil {
+		return o, err
+	}
+	return o, o.Validate()
+}
+
+func TestOptionsDefaults(t *testing.T) {
+	o, err := parseOptions(t)
+	assert.NoError(t, err)
+	provider := o.Config.MetricProvider
+	assert.Equal(t, common.PrometheusProvider, provider.Type)
+	assert.Equal(t, defaultPrometheusAddress, provider.Prometheus.Address)
+	assert.Equal(t, defaultQueryTimeout, provider.QueryTimeout.Duration)
+	assert.Equal(t, defaultPollInterval, provider.PollInterval.Duration)
+}
+
+func TestOptionsConfigFile(t *testing.T) {
+	path := writeConfigFile(t, testConfigFile)
+	o, err := parseOptions(t, "--config="+path, "--prometheus-tenant-id=team-b", "--metric-poll-interval=30s")
+	assert.NoError(t, err)
+
+	providerConfig := ToProviderConfig(&o.Config.MetricProvider, nil, nil)
+	assert.Equal(t, common.PrometheusProvider, providerConfig.ProviderType)
+	assert.Equal(t, config.PrometheusProviderConfig{
+		Address:         "https://thanos-query.monitoring:9090",
+		QueryTimeout:    30 * time.Second,
+		BearerTokenFile: "/var/run/secrets/prometheus/token",
+		TLS:             config.TLSConfig{CAFile: "/etc/prometheus/ca.crt"},
+		// flags take precedence over the config file
+		TenantID: "team-b",
+	}, providerConfig.PromConf)
+	assert.Equal(t, 30*time.Second, o.Config.MetricProvider.PollInterval.Duration)
+}
+
+func TestOptionsInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		args   []string
+	}{
+		{
+			name: "unknown field",
+			config: `apiVersion: config.interference.koordinator.sh/v1alpha1
+kind: InterferenceManagerConfiguration
+metricProvider:
+  prometheus:
+    adress: http://prometheus:9090
+`,
+		},
+		{
+			name: "unknown version",
+			config: `apiVersion: config.interference.koordinator.sh/v1beta1
+kind: InterferenceManagerConfiguration
+`,
+		},
+		{
+			name: "bearer token with basic auth",
+			config: `apiVersion: config.interference.koordinator.sh/v1alpha1
+kind: InterferenceManagerConfiguration
+metricProvider:
+  prometheus:
+    bearerTokenFile: /token
+    basicAuth:
+      username: admin
+      passwordFile: /password
+`,
+		},
+		{
+			name: "service proxy without name",
+			config: `apiVersion: config.interference.koordinator.sh/v1alpha1
+kind: InterferenceManagerConfiguration
+metricProvider:
+  prometheus:
+    serviceProxy:
+      namespace: monitoring
+`,
+		},
+		{
+			name: "invalid address",
+			args: []string{"--prometheus-address=prometheus:9090"},
+		},
+		{
+			name: "unknown provider",
+			args: []string{"--metric-provider=influxdb"},
+		},
+		{
+			name: "ingestion without bind address",
+			args: []string{"--metric-provider=ingestion_provider"},
+		},
+		{
+			name: "ingestion without tls",
+			args: []string{"--metric-provider=ingestion_provider", "--ingestion-bind-address=:9091",
+				"--ingestion-allowed-users=system:serviceaccount:koordinator-system:koordetector"},
+		},
+		{
+			name: "ingestion without allowed users",
+			args: []string{"--metric-provider=ingestion_provider", "--ingestion-bind-address=:9091",
+				"--ingestion-cert-file=/tls.crt", "--ingestion-key-file=/tls.key"},
+		},
+		{
+			name: "negative lookback",
+			args: []string{"--prometheus-lookback=-1m"},
+		},
+		{
+			name: "negative timeout",
+			args: []string{"--metric-query-timeout=-1s"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.config != "" {
+				args = append(args, "--config="+writeConfigFile(t, tt.config))
+			}
+			_, err := parseOptions(t, args...)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestManifestConfigFile(t *testing.T) {
+	_, err := parseOptions(t, "--config=../../../config/manager/interference_manager_config.yaml")
+	assert.NoError(t, err)
+}
@@ -76,7 +76,8 @@ func main() {
 	go func() {
 		klog.Infof("Starting prometheus server on %v", *options.ServerAddr)
 		http.Handle("/metrics", promhttp.Handler())
-		// http.HandleFunc("/healthz", d.HealthzHandler())
+		http.HandleFunc("/healthz", d.HealthzHandler())
+		http.HandleFunc("/readyz", d.ReadyzHandler())
 		klog.Fatalf("Prometheus monitoring failed: %v", http.ListenAndServe(*options.ServerAddr, nil))
 	}()
 
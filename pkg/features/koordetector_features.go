@@ -25,9 +25,16 @@ func init() {
 	runtime.Must(DefaultMutableKoordetectorFeatureGate.Add(defaultKoordetectorFeatureGates))
 }
 
+const (
+	// CPUScheduleLatencyCollector enables collecting the CPU schedule latency of containers by eBPF.
+	CPUScheduleLatencyCollector featuregate.Feature = "CPUScheduleLatencyCollector"
+)
+
 var (
 	DefaultMutableKoordetectorFeatureGate featuregate.MutableFeatureGate = featuregate.NewFeatureGate()
 	DefaultKoordetectorFeatureGate        featuregate.FeatureGate        = DefaultMutableKoordetectorFeatureGate
 
-	defaultKoordetectorFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{}
+	defaultKoordetectorFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
+		CPUScheduleLatencyCollector: {Default: false, PreRelease: featuregate.Alpha},
+	}
 )
@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+)
+
+// AggregationKey identifies the samples of a container of a workload on a metric.
+type AggregationKey struct {
+	Owner         interferencev1alpha1.WorkloadReference
+	ContainerName string
+	Metric        interferencev1alpha1.MetricName
+}
+
+// AggregationsState holds the aggregations of all containers.
+type AggregationsState map[AggregationKey]*ContainerAggregation
+
+// ContainerAggregation aggregates the samples of all instances of a container in a workload into a decaying
+// histogram.
+type ContainerAggregation struct {
+	Histogram         Histogram
+	FirstSampleTime   time.Time
+	LastSampleTime    time.Time
+	TotalSamplesCount int64
+}
+
+// NewContainerAggregation returns an empty aggregation where the weight of a sample is halved every @halfLife.
+func NewContainerAggregation(options *ExponentialHistogramOptions, halfLife time.Duration) *ContainerAggregation {
+	return &ContainerAggregation{
+		Histogram: NewDecayingHistogram(options, halfLife),
+	}
+}
+
+// AddSample adds a sample with the unit weight.
+func (a *ContainerAggregation) AddSample(value float64, timestamp time.Time) {
+	a.Histogram.AddSample(value, 1.0, timestamp)
+	if a.FirstSampleTime.IsZero() || timestamp.Before(a.FirstSampleTime) {
+		a.FirstSampleTime = timestamp
+	}
+	if timestamp.After(a.LastSampleTime) {
+		a.LastSampleTime = timestamp
+	}
+	a.TotalSamplesCount++
+}
+
+// Merge adds the samples of another aggregation, which must have the same histogram options and half-life.
+func (a *ContainerAggregation) Merge(other *ContainerAggregation) {
+	if other.TotalSamplesCount == 0 {
+		return
+	}
+	a.Histogram.Merge(other.Histogram)
+	if a.FirstSampleTime.IsZero() || other.FirstSampleTime.Before(a.FirstSampleTime) {
+		a.FirstSampleTime = other.FirstSampleTime
+	}
+	if other.LastSampleTime.After(a.LastSampleTime) {
+		a.LastSampleTime = other.LastSampleTime
+	}
+	a.TotalSamplesCount += other.TotalSamplesCount
+}
+
+// SaveToCheckpoint serializes the aggregation of the container.
+func (a *ContainerAggregation) SaveToCheckpoint(containerName string) (*interferencev1alpha1.ContainerHistogramCheckpoint, error) {
+	histogram, err := a.Histogram.SaveToCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	return &interferencev1alpha1.ContainerHistogramCheckpoint{
+		ContainerName:     containerName,
+		Histogram:         *histogram,
+		FirstSampleTime:   metav1.NewTime(a.FirstSampleTime),
+		LastSampleTime:    metav1.NewTime(a.LastSampleTime),
+		TotalSamplesCount: a.TotalSamplesCount,
+	}, nil
+}
+
+// NewContainerAggregationFromCheckpoint restores the aggregation of a container, with the histogram options and
+// half-life of the checkpoint.
+func NewContainerAggregationFromCheckpoint(checkpoint *interferencev1alpha1.ContainerHistogramCheckpoint) (*ContainerAggregation, error) {
+	histogram, err := NewDecayingHistogramFromCheckpoint(&checkpoint.Histogram)
+	if err != nil {
+		return nil, err
+	}
+	return &ContainerAggregation{
+		Histogram:         histogram,
+		FirstSampleTime:   checkpoint.FirstSampleTime.Time,
+		LastSampleTime:    checkpoint.LastSampleTime.Time,
+		TotalSamplesCount: checkpoint.TotalSamplesCount,
+	}, nil
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+)
+
+// SaveToCheckpoint normalizes the bucket weights into [1, MaxCheckpointWeight], the total weight is kept separately
+// so that the absolute weights can be restored.
+func (h *histogram) SaveToCheckpoint() (*interferencev1alpha1.HistogramCheckpoint, error) {
+	checkpoint := &interferencev1alpha1.HistogramCheckpoint{
+		Options:     h.options.toCheckpoint(),
+		TotalWeight: *resource.NewMilliQuantity(int64(math.Round(h.totalWeight*1000)), resource.DecimalSI),
+	}
+	if h.IsEmpty() {
+		return checkpoint, nil
+	}
+
+	maxWeight := 0.0
+	for bucket := h.minBucket; bucket <= h.maxBucket; bucket++ {
+		maxWeight = math.Max(maxWeight, h.bucketWeight[bucket])
+	}
+	ratio := float64(interferencev1alpha1.MaxCheckpointWeight) / maxWeight
+	for bucket := h.minBucket; bucket <= h.maxBucket; bucket++ {
+		weight := uint32(math.Round(h.bucketWeight[bucket] * ratio))
+		if weight == 0 {
+			continue
+		}
+		checkpoint.BucketWeights = append(checkpoint.BucketWeights, interferencev1alpha1.BucketWeight{
+			Index:  int32(bucket),
+			Weight: weight,
+		})
+	}
+	if len(checkpoint.BucketWeights) > interferencev1alpha1.MaxCheckpointBuckets {
+		return nil, fmt.Errorf("too many non-empty buckets %d, at most %d can be checkpointed",
+			len(checkpoint.BucketWeights), interferencev1alpha1.MaxCheckpointBuckets)
+	}
+	return checkpoint, nil
+}
+
+// loadFromCheckpoint replaces the weights of the histogram with the checkpoint, which must have the same options.
+func (h *histogram) loadFromCheckpoint(checkpoint *interferencev1alpha1.HistogramCheckpoint) error {
+	sumWeight := 0.0
+	for _, b := range checkpoint.BucketWeights {
+		if b.Index < 0 || int(b.Index) >= h.options.NumBuckets() {
+			return fmt.Errorf("bucket index %d out of range [0..%d]", b.Index, h.options.NumBuckets()-1)
+		}
+		sumWeight += float64(b.Weight)
+	}
+
+	for bucket := range h.bucketWeight {
+		h.bucketWeight[bucket] = 0.0
+	}
+	h.totalWeight = 0.0
+	h.minBucket = h.options.NumBuckets() - 1
+	h.maxBucket = 0
+	if sumWeight == 0.0 {
+		return nil
+	}
+	ratio := checkpoint.TotalWeight.AsApproximateFloat64() / sumWeight
+	for _, b := range checkpoint.BucketWeights {
+		bucket := int(b.Index)
+		h.bucketWeight[bucket] += float64(b.Weight) * ratio
+		h.totalWeight += float64(b.Weight) * ratio
+		if bucket < h.minBucket {
+			h.minBucket = bucket
+		}
+		if bucket > h.maxBucket {
+			h.maxBucket = bucket
+		}
+	}
+	h.updateMinAndMaxBucket()
+	return nil
+}
+
+// NewDecayingHistogramFromCheckpoint restores a decaying histogram with the options, half-life and weights of the
+// checkpoint.
+func NewDecayingHistogramFromCheckpoint(checkpoint *interferencev1alpha1.HistogramCheckpoint) (Histogram, error) {
+	options, err := newExponentialHistogramOptionsFromCheckpoint(&checkpoint.Options)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint.Options.HalfLife.Duration <= 0 {
+		return nil, fmt.Errorf("invalid half-life %v", checkpoint.Options.HalfLife.Duration)
+	}
+	h := &decayingHistogram{
+		histogram:          *newHistogram(options),
+		halfLife:           checkpoint.Options.HalfLife.Duration,
+		referenceTimestamp: checkpoint.ReferenceTimestamp.Time,
+	}
+	if err := h.histogram.loadFromCheckpoint(checkpoint); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func newExponentialHistogramOptionsFromCheckpoint(options *interferencev1alpha1.HistogramOptions) (*ExponentialHistogramOptions, error) {
+	o, err := NewExponentialHistogramOptions(options.MaxValue.AsApproximateFloat64(),
+		options.FirstBucketSize.AsApproximateFloat64(), options.Ratio.AsApproximateFloat64(), DefaultEpsilon)
+	if err != nil {
+		return nil, err
+	}
+	if o.NumBuckets() > interferencev1alpha1.MaxCheckpointBuckets {
+		return nil, fmt.Errorf("too many buckets %d, at most %d are allowed", o.NumBuckets(),
+			interferencev1alpha1.MaxCheckpointBuckets)
+	}
+	return o, nil
+}
+
+func (o *ExponentialHistogramOptions) toCheckpoint() interferencev1alpha1.HistogramOptions {
+	return interferencev1alpha1.HistogramOptions{
+		MaxValue:        floatToQuantity(o.maxValue),
+		FirstBucketSize: floatToQuantity(o.firstBucketSize),
+		Ratio:           floatToQuantity(o.ratio),
+	}
+}
+
+// floatToQuantity converts a float into a quantity with its shortest decimal representation, so that options
+// like 1.05 are kept exactly.
+func floatToQuantity(value float64) resource.Quantity {
+	q, err := resource.ParseQuantity(strconv.FormatFloat(value, 'f', -1, 64))
+	if err != nil {
+		return *resource.NewMilliQuantity(int64(math.Round(value*1000)), resource.DecimalSI)
+	}
+	return q
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"math"
+	"time"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+)
+
+const (
+	// maxDecayExponent bounds the decay factor of samples to 2^maxDecayExponent relative to the reference
+	// timestamp, the reference timestamp is shifted forward when it is exceeded to avoid overflow.
+	maxDecayExponent = 20
+)
+
+// decayingHistogram is a histogram where the weight of a sample decays exponentially with its age, the weight
+// is halved every halfLife. It is implemented by increasing the weights of newer samples instead of decreasing
+// older ones, relative to a reference timestamp.
+type decayingHistogram struct {
+	histogram
+	halfLife           time.Duration
+	referenceTimestamp time.Time
+}
+
+// NewDecayingHistogram returns a histogram where the weight of a sample is halved every @halfLife.
+func NewDecayingHistogram(options *ExponentialHistogramOptions, halfLife time.Duration) Histogram {
+	return &decayingHistogram{
+		histogram: *newHistogram(options),
+		halfLife:  halfLife,
+	}
+}
+
+func (h *decayingHistogram) AddSample(value float64, weight float64, timestamp time.Time) {
+	h.histogram.AddSample(value, weight*h.decayFactor(timestamp), timestamp)
+}
+
+func (h *decayingHistogram) Merge(other Histogram) {
+	o, ok := other.(*decayingHistogram)
+	if !ok {
+		panic("can not merge a decaying histogram with a non-decaying one")
+	}
+	if h.halfLife != o.halfLife {
+		panic("can not merge decaying histograms with different half-life")
+	}
+	if o.IsEmpty() {
+		return
+	}
+	if h.IsEmpty() || o.referenceTimestamp.After(h.referenceTimestamp) {
+		h.shiftReferenceTimestamp(o.referenceTimestamp)
+	}
+	// scale a copy of the other histogram to the same reference timestamp
+	scaled := *newHistogram(o.options)
+	scaled.Merge(&o.histogram)
+	scaled.scale(math.Exp2(float64(o.referenceTimestamp.Sub(h.referenceTimestamp)) / float64(h.halfLife)))
+	h.histogram.Merge(&scaled)
+}
+
+func (h *decayingHistogram) SaveToCheckpoint() (*interferencev1alpha1.HistogramCheckpoint, error) {
+	checkpoint, err := h.histogram.SaveToCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	checkpoint.Options.HalfLife.Duration = h.halfLife
+	checkpoint.ReferenceTimestamp.Time = h.referenceTimestamp
+	return checkpoint, nil
+}
+
+// decayFactor returns the factor of a sample at @timestamp relative to the reference timestamp.
+func (h *decayingHistogram) decayFactor(timestamp time.Time) float64 {
+	maxAllowedTimestamp := h.referenceTimestamp.Add(h.halfLife * maxDecayExponent)
+	if timestamp.After(maxAllowedTimestamp) {
+		h.shiftReferenceTimestamp(timestamp)
+	}
+	return math.Exp2(float64(timestamp.Sub(h.referenceTimestamp)) / float64(h.halfLife))
+}
+
+// shiftReferenceTimestamp moves the reference timestamp forward to a multiple of halfLife near @timestamp and
+// scales down the weights accordingly.
+func (h *decayingHistogram) shiftReferenceTimestamp(timestamp time.Time) {
+	newReferenceTimestamp := timestamp.Round(h.halfLife)
+	exponent := math.Round(float64(h.referenceTimestamp.Sub(newReferenceTimestamp)) / float64(h.halfLife))
+	h.histogram.scale(math.Exp2(exponent))
+	h.referenceTimestamp = newReferenceTimestamp
+}
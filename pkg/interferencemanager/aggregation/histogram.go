@@ -0,0 +1,199 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+)
+
+// Histogram represents an approximate distribution of some variable.
+type Histogram interface {
+	// Percentile returns an approximation of the given percentile of the distribution, @percentile is in [0, 1].
+	// It returns the end of the bucket which the percentile falls in, or 0.0 if the histogram is empty.
+	Percentile(percentile float64) float64
+	// Mean returns an approximation of the mean of the distribution, or 0.0 if the histogram is empty.
+	Mean() float64
+	// StdDev returns an approximation of the standard deviation of the distribution, or 0.0 if the histogram is
+	// empty.
+	StdDev() float64
+	// AddSample adds a sample with the given weight.
+	AddSample(value float64, weight float64, timestamp time.Time)
+	// Merge adds all samples of another histogram, which must have the same options.
+	Merge(other Histogram)
+	// IsEmpty returns whether the histogram has no samples.
+	IsEmpty() bool
+	// TotalWeight returns the sum of weights of all buckets.
+	TotalWeight() float64
+	// SaveToCheckpoint returns a compact representation of the histogram which can be persisted.
+	SaveToCheckpoint() (*interferencev1alpha1.HistogramCheckpoint, error)
+}
+
+// histogram is a histogram with fixed buckets.
+type histogram struct {
+	options *ExponentialHistogramOptions
+	// bucketWeight is the weight of each bucket.
+	bucketWeight []float64
+	// totalWeight is the sum of bucketWeight.
+	totalWeight float64
+	// minBucket and maxBucket are the range of non-empty buckets, minBucket > maxBucket when it is empty.
+	minBucket int
+	maxBucket int
+}
+
+// NewHistogram returns a histogram with the given buckets.
+func NewHistogram(options *ExponentialHistogramOptions) Histogram {
+	return newHistogram(options)
+}
+
+func newHistogram(options *ExponentialHistogramOptions) *histogram {
+	return &histogram{
+		options:      options,
+		bucketWeight: make([]float64, options.NumBuckets()),
+		totalWeight:  0.0,
+		minBucket:    options.NumBuckets() - 1,
+		maxBucket:    0,
+	}
+}
+
+func (h *histogram) AddSample(value float64, weight float64, timestamp time.Time) {
+	if weight < 0.0 {
+		panic("sample weight must be non-negative")
+	}
+	bucket := h.options.FindBucket(value)
+	h.bucketWeight[bucket] += weight
+	h.totalWeight += weight
+	if bucket < h.minBucket && h.bucketWeight[bucket] >= h.options.Epsilon() {
+		h.minBucket = bucket
+	}
+	if bucket > h.maxBucket && h.bucketWeight[bucket] >= h.options.Epsilon() {
+		h.maxBucket = bucket
+	}
+}
+
+func (h *histogram) Percentile(percentile float64) float64 {
+	if h.IsEmpty() {
+		return 0.0
+	}
+	partialSum := 0.0
+	threshold := percentile * h.totalWeight
+	bucket := h.minBucket
+	for ; bucket < h.maxBucket; bucket++ {
+		partialSum += h.bucketWeight[bucket]
+		if partialSum >= threshold {
+			break
+		}
+	}
+	if bucket < h.options.NumBuckets()-1 {
+		// return the end of the bucket
+		return h.options.GetBucketStart(bucket + 1)
+	}
+	return h.options.GetBucketStart(bucket)
+}
+
+func (h *histogram) Mean() float64 {
+	if h.IsEmpty() {
+		return 0.0
+	}
+	sum := 0.0
+	for bucket := h.minBucket; bucket <= h.maxBucket; bucket++ {
+		sum += h.bucketWeight[bucket] * h.bucketMiddle(bucket)
+	}
+	return sum / h.totalWeight
+}
+
+func (h *histogram) StdDev() float64 {
+	if h.IsEmpty() {
+		return 0.0
+	}
+	mean := h.Mean()
+	variance := 0.0
+	for bucket := h.minBucket; bucket <= h.maxBucket; bucket++ {
+		d := h.bucketMiddle(bucket) - mean
+		variance += h.bucketWeight[bucket] * d * d
+	}
+	return math.Sqrt(variance / h.totalWeight)
+}
+
+// bucketMiddle returns the middle value of the bucket, which stands for all samples in the bucket.
+func (h *histogram) bucketMiddle(bucket int) float64 {
+	if bucket >= h.options.NumBuckets()-1 {
+		return h.options.GetBucketStart(bucket)
+	}
+	return (h.options.GetBucketStart(bucket) + h.options.GetBucketStart(bucket+1)) / 2
+}
+
+func (h *histogram) Merge(other Histogram) {
+	o := toHistogram(other)
+	if !h.options.Equals(o.options) {
+		panic("can not merge histograms with different options")
+	}
+	for bucket := o.minBucket; bucket <= o.maxBucket; bucket++ {
+		h.bucketWeight[bucket] += o.bucketWeight[bucket]
+	}
+	h.totalWeight += o.totalWeight
+	if o.minBucket < h.minBucket {
+		h.minBucket = o.minBucket
+	}
+	if o.maxBucket > h.maxBucket {
+		h.maxBucket = o.maxBucket
+	}
+}
+
+func (h *histogram) IsEmpty() bool {
+	return h.bucketWeight[h.minBucket] < h.options.Epsilon()
+}
+
+func (h *histogram) TotalWeight() float64 {
+	return h.totalWeight
+}
+
+// scale multiplies the weights of all buckets by @factor.
+func (h *histogram) scale(factor float64) {
+	if factor < 0.0 {
+		panic("scale factor must be non-negative")
+	}
+	for bucket := h.minBucket; bucket <= h.maxBucket; bucket++ {
+		h.bucketWeight[bucket] *= factor
+	}
+	h.totalWeight *= factor
+	h.updateMinAndMaxBucket()
+}
+
+// updateMinAndMaxBucket shrinks the range of non-empty buckets after weights are decreased.
+func (h *histogram) updateMinAndMaxBucket() {
+	lastBucket := h.options.NumBuckets() - 1
+	for h.bucketWeight[h.minBucket] < h.options.Epsilon() && h.minBucket < lastBucket {
+		h.minBucket++
+	}
+	for h.bucketWeight[h.maxBucket] < h.options.Epsilon() && h.maxBucket > 0 {
+		h.maxBucket--
+	}
+}
+
+func toHistogram(h Histogram) *histogram {
+	switch t := h.(type) {
+	case *histogram:
+		return t
+	case *decayingHistogram:
+		return &t.histogram
+	}
+	panic(fmt.Sprintf("unknown histogram type %T", h))
+}
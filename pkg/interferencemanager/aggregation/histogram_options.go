@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"fmt"
+	"math"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+)
+
+const (
+	// DefaultMaxValue is the upper bound of the default histogram buckets.
+	DefaultMaxValue = 1000.0
+	// DefaultFirstBucketSize is the size of the first bucket of the default histogram buckets, which makes it
+	// precise enough for latencies in seconds.
+	DefaultFirstBucketSize = 1e-6
+	// DefaultRatio is the ratio between two consecutive default histogram buckets, the relative error of
+	// percentiles is within 5%.
+	DefaultRatio = 1.05
+	// DefaultEpsilon is the minimal weight of a non-empty bucket.
+	DefaultEpsilon = 1e-4
+
+	// MemoryMaxValue is the upper bound of the histogram buckets of memory in bytes, which is 4 TiB.
+	MemoryMaxValue = float64(1 << 42)
+	// MemoryFirstBucketSize is the size of the first bucket of memory in bytes, which is 1 MiB.
+	MemoryFirstBucketSize = float64(1 << 20)
+)
+
+// ExponentialHistogramOptions describe exponential buckets, where the n-th bucket (n >= 1) starts at
+// firstBucketSize * (1 + ratio + ratio^2 + ... + ratio^(n-1)) until maxValue.
+type ExponentialHistogramOptions struct {
+	maxValue        float64
+	firstBucketSize float64
+	ratio           float64
+	numBuckets      int
+	epsilon         float64
+}
+
+// NewExponentialHistogramOptions returns options of exponential buckets covering [0, maxValue].
+func NewExponentialHistogramOptions(maxValue, firstBucketSize, ratio, epsilon float64) (*ExponentialHistogramOptions, error) {
+	if maxValue <= 0 || firstBucketSize <= 0 || ratio <= 1 || epsilon <= 0 {
+		return nil, fmt.Errorf("maxValue, firstBucketSize and epsilon must be > 0.0, ratio must be > 1.0, got %v, %v, %v, %v",
+			maxValue, firstBucketSize, ratio, epsilon)
+	}
+	numBuckets := int(math.Ceil(math.Log(maxValue*(ratio-1)/firstBucketSize+1)/math.Log(ratio))) + 1
+	return &ExponentialHistogramOptions{
+		maxValue:        maxValue,
+		firstBucketSize: firstBucketSize,
+		ratio:           ratio,
+		numBuckets:      numBuckets,
+		epsilon:         epsilon,
+	}, nil
+}
+
+// NewDefaultHistogramOptions returns the exponential buckets which cover both CPI and latencies in seconds.
+func NewDefaultHistogramOptions() *ExponentialHistogramOptions {
+	options, _ := NewExponentialHistogramOptions(DefaultMaxValue, DefaultFirstBucketSize, DefaultRatio, DefaultEpsilon)
+	return options
+}
+
+// NewHistogramOptionsOf returns the histogram buckets of the metric, the default buckets cover neither memory
+// nor other values in bytes.
+func NewHistogramOptionsOf(metric interferencev1alpha1.MetricName) *ExponentialHistogramOptions {
+	switch metric {
+	case interferencev1alpha1.MetricPodMemoryUsage:
+		options, _ := NewExponentialHistogramOptions(MemoryMaxValue, MemoryFirstBucketSize, DefaultRatio, DefaultEpsilon)
+		return options
+	}
+	return NewDefaultHistogramOptions()
+}
+
+// NumBuckets returns the number of buckets.
+func (o *ExponentialHistogramOptions) NumBuckets() int {
+	return o.numBuckets
+}
+
+// FindBucket returns the index of the bucket the value falls in, values out of range fall in the first or the
+// last bucket.
+func (o *ExponentialHistogramOptions) FindBucket(value float64) int {
+	if value < o.firstBucketSize {
+		return 0
+	}
+	bucket := int(math.Log(value*(o.ratio-1)/o.firstBucketSize+1) / math.Log(o.ratio))
+	if bucket >= o.numBuckets {
+		return o.numBuckets - 1
+	}
+	return bucket
+}
+
+// GetBucketStart returns the lower bound of the bucket.
+func (o *ExponentialHistogramOptions) GetBucketStart(bucket int) float64 {
+	if bucket < 0 || bucket >= o.numBuckets {
+		panic(fmt.Sprintf("index %d out of range [0..%d]", bucket, o.numBuckets-1))
+	}
+	if bucket == 0 {
+		return 0
+	}
+	return o.firstBucketSize * (math.Pow(o.ratio, float64(bucket)) - 1) / (o.ratio - 1)
+}
+
+// Epsilon returns the minimal weight of a non-empty bucket.
+func (o *ExponentialHistogramOptions) Epsilon() float64 {
+	return o.epsilon
+}
+
+// Equals returns whether the two options describe the same buckets.
+func (o *ExponentialHistogramOptions) Equals(other *ExponentialHistogramOptions) bool {
+	return floatEquals(o.maxValue, other.maxValue) && floatEquals(o.firstBucketSize, other.firstBucketSize) &&
+		floatEquals(o.ratio, other.ratio)
+}
+
+// floatEquals compares floats with a relative tolerance, since options may be converted from quantities.
+func floatEquals(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(math.Abs(a), math.Abs(b))
+}
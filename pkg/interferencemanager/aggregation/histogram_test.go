@@ -0,0 +1,160 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+)
+
+func TestExponentialHistogramOptions(t *testing.T) {
+	_, err := NewExponentialHistogramOptions(100, 1, 1, DefaultEpsilon)
+	assert.Error(t, err)
+
+	o, err := NewExponentialHistogramOptions(100, 10, 2, DefaultEpsilon)
+	assert.NoError(t, err)
+	// buckets start at 0, 10, 30, 70, 150
+	assert.Equal(t, 5, o.NumBuckets())
+	assert.Equal(t, 0, o.FindBucket(-1))
+	assert.Equal(t, 0, o.FindBucket(9.99))
+	assert.Equal(t, 1, o.FindBucket(10))
+	assert.Equal(t, 2, o.FindBucket(69.99))
+	assert.Equal(t, 3, o.FindBucket(70))
+	assert.Equal(t, 4, o.FindBucket(1e6))
+	assert.Equal(t, 70.0, o.GetBucketStart(3))
+
+	// the default options checkpoint within the bucket limit
+	assert.LessOrEqual(t, NewDefaultHistogramOptions().NumBuckets(), 512)
+	memory := NewHistogramOptionsOf(interferencev1alpha1.MetricPodMemoryUsage)
+	assert.LessOrEqual(t, memory.NumBuckets(), 512)
+	assert.Less(t, memory.FindBucket(1<<40), memory.NumBuckets()-1)
+	assert.True(t, NewHistogramOptionsOf(interferencev1alpha1.MetricContainerCPI).Equals(NewDefaultHistogramOptions()))
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram(NewDefaultHistogramOptions())
+	assert.True(t, h.IsEmpty())
+	assert.Equal(t, 0.0, h.Percentile(0.5))
+	assert.Equal(t, 0.0, h.Mean())
+
+	now := time.Now()
+	for i := 1; i <= 100; i++ {
+		h.AddSample(float64(i), 1, now)
+	}
+	assert.False(t, h.IsEmpty())
+	assert.Equal(t, 100.0, h.TotalWeight())
+	assert.InEpsilon(t, 50.5, h.Mean(), 0.05)
+	assert.InEpsilon(t, 28.87, h.StdDev(), 0.05)
+	assert.InEpsilon(t, 50, h.Percentile(0.5), 0.05)
+	assert.InEpsilon(t, 90, h.Percentile(0.9), 0.05)
+	assert.InEpsilon(t, 100, h.Percentile(1), 0.05)
+
+	other := NewHistogram(NewDefaultHistogramOptions())
+	other.AddSample(1000, 100, now)
+	h.Merge(other)
+	assert.Equal(t, 200.0, h.TotalWeight())
+	assert.InEpsilon(t, 1000, h.Percentile(0.99), 0.05)
+}
+
+func TestDecayingHistogram(t *testing.T) {
+	halfLife := time.Hour
+	now := time.Now()
+	h := NewDecayingHistogram(NewDefaultHistogramOptions(), halfLife)
+	// the weight of the old sample is a quarter of the new one
+	h.AddSample(1, 1, now.Add(-2*halfLife))
+	h.AddSample(2, 1, now)
+	assert.InEpsilon(t, 1.8, h.Mean(), 0.05)
+	assert.InEpsilon(t, 2, h.Percentile(0.5), 0.05)
+
+	// samples far in the future shift the reference timestamp without overflow
+	later := now.Add(100 * halfLife)
+	h.AddSample(3, 1, later)
+	assert.InEpsilon(t, 3, h.Percentile(0.01), 0.05)
+	assert.False(t, h.IsEmpty())
+
+	// merging aligns the reference timestamps
+	a := NewDecayingHistogram(NewDefaultHistogramOptions(), halfLife)
+	a.AddSample(1, 1, now)
+	b := NewDecayingHistogram(NewDefaultHistogramOptions(), halfLife)
+	b.AddSample(2, 1, now.Add(halfLife))
+	a.Merge(b)
+	assert.InEpsilon(t, 5.0/3, a.Mean(), 0.05)
+}
+
+func TestHistogramCheckpoint(t *testing.T) {
+	halfLife := 24 * time.Hour
+	now := time.Now()
+	h := NewDecayingHistogram(NewDefaultHistogramOptions(), halfLife)
+	for i := 0; i < 1000; i++ {
+		h.AddSample(float64(i%10)+0.5, 1, now.Add(time.Duration(i)*time.Minute))
+	}
+
+	checkpoint, err := h.SaveToCheckpoint()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, checkpoint.Options.Ratio.Cmp(resource.MustParse("1.05")))
+	assert.Equal(t, 0, checkpoint.Options.FirstBucketSize.Cmp(resource.MustParse("1u")))
+	assert.Equal(t, halfLife, checkpoint.Options.HalfLife.Duration)
+	assert.Len(t, checkpoint.BucketWeights, 10)
+	for _, b := range checkpoint.BucketWeights {
+		assert.LessOrEqual(t, b.Weight, uint32(10000))
+	}
+
+	restored, err := NewDecayingHistogramFromCheckpoint(checkpoint)
+	assert.NoError(t, err)
+	assert.InEpsilon(t, h.TotalWeight(), restored.TotalWeight(), 0.001)
+	assert.InEpsilon(t, h.Mean(), restored.Mean(), 0.001)
+	assert.Equal(t, h.Percentile(0.9), restored.Percentile(0.9))
+
+	// restored histograms keep decaying with the same reference timestamp
+	h.AddSample(100, 1, now.Add(1000*time.Minute))
+	restored.AddSample(100, 1, now.Add(1000*time.Minute))
+	assert.InEpsilon(t, h.Mean(), restored.Mean(), 0.001)
+
+	checkpoint.Options.HalfLife.Duration = 0
+	_, err = NewDecayingHistogramFromCheckpoint(checkpoint)
+	assert.Error(t, err)
+}
+
+func TestContainerAggregation(t *testing.T) {
+	now := time.Now()
+	a := NewContainerAggregation(NewDefaultHistogramOptions(), time.Hour)
+	a.AddSample(1, now)
+	a.AddSample(3, now.Add(-time.Minute))
+	assert.Equal(t, int64(2), a.TotalSamplesCount)
+	assert.Equal(t, now.Add(-time.Minute), a.FirstSampleTime)
+	assert.Equal(t, now, a.LastSampleTime)
+
+	b := NewContainerAggregation(NewDefaultHistogramOptions(), time.Hour)
+	b.AddSample(2, now.Add(time.Minute))
+	a.Merge(b)
+	assert.Equal(t, int64(3), a.TotalSamplesCount)
+	assert.Equal(t, now.Add(time.Minute), a.LastSampleTime)
+
+	checkpoint, err := a.SaveToCheckpoint("main")
+	assert.NoError(t, err)
+	assert.Equal(t, "main", checkpoint.ContainerName)
+	restored, err := NewContainerAggregationFromCheckpoint(checkpoint)
+	assert.NoError(t, err)
+	assert.Equal(t, a.TotalSamplesCount, restored.TotalSamplesCount)
+	assert.True(t, a.FirstSampleTime.Equal(restored.FirstSampleTime))
+	assert.InEpsilon(t, a.Histogram.Mean(), restored.Histogram.Mean(), 0.001)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"math"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/aggregation"
+)
+
+// baselineOf calculates the baseline of the aggregated samples of a container.
+func baselineOf(key aggregation.AggregationKey, a *aggregation.ContainerAggregation, now time.Time) *interferencev1alpha1.WorkloadBaseline {
+	return &interferencev1alpha1.WorkloadBaseline{
+		Owner:          key.Owner,
+		ContainerName:  key.ContainerName,
+		Metric:         key.Metric,
+		Mean:           newQuantity(a.Histogram.Mean()),
+		StdDev:         newQuantity(a.Histogram.StdDev()),
+		P50:            newQuantityPtr(a.Histogram.Percentile(0.5)),
+		P90:            newQuantityPtr(a.Histogram.Percentile(0.9)),
+		P99:            newQuantityPtr(a.Histogram.Percentile(0.99)),
+		SampleCount:    a.TotalSamplesCount,
+		WindowStart:    metav1.NewTime(a.FirstSampleTime),
+		WindowEnd:      metav1.NewTime(a.LastSampleTime),
+		LastUpdateTime: metav1.NewTime(now),
+	}
+}
+
+// keepUnchangedBaselines replaces the new baselines which are the same as the old ones with the old ones, so that
+// LastUpdateTime only changes when the baseline is updated, and the status is not updated for nothing.
+func keepUnchangedBaselines(oldBaselines, newBaselines []interferencev1alpha1.WorkloadBaseline) []interferencev1alpha1.WorkloadBaseline {
+	olds := make(map[aggregation.AggregationKey]*interferencev1alpha1.WorkloadBaseline, len(oldBaselines))
+	for i := range oldBaselines {
+		b := &oldBaselines[i]
+		olds[aggregation.AggregationKey{Owner: b.Owner, ContainerName: b.ContainerName, Metric: b.Metric}] = b
+	}
+	for i := range newBaselines {
+		b := &newBaselines[i]
+		old, ok := olds[aggregation.AggregationKey{Owner: b.Owner, ContainerName: b.ContainerName, Metric: b.Metric}]
+		if ok && baselineEqual(old, b) {
+			newBaselines[i] = *old
+		}
+	}
+	return newBaselines
+}
+
+// baselineEqual compares the values of the baselines regardless of LastUpdateTime. Times are compared in seconds
+// since they are serialized in RFC 3339.
+func baselineEqual(a, b *interferencev1alpha1.WorkloadBaseline) bool {
+	return a.Mean.Cmp(b.Mean) == 0 && a.StdDev.Cmp(b.StdDev) == 0 &&
+		quantityPtrEqual(a.P50, b.P50) && quantityPtrEqual(a.P90, b.P90) && quantityPtrEqual(a.P99, b.P99) &&
+		a.SampleCount == b.SampleCount &&
+		a.WindowStart.Unix() == b.WindowStart.Unix() && a.WindowEnd.Unix() == b.WindowEnd.Unix()
+}
+
+func quantityPtrEqual(a, b *resource.Quantity) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(*b) == 0
+}
+
+// maxNanoValue is the maximum value which can be kept in nano precision by an int64.
+const maxNanoValue = math.MaxInt64 / 1e9
+
+// newQuantity converts a float metric value into a quantity with nano precision, or rounded to an integer if the
+// value is too large for nano precision, e.g. memory in bytes. Values out of the range of int64 are clamped.
+func newQuantity(value float64) resource.Quantity {
+	if math.Abs(value) < maxNanoValue {
+		return *resource.NewScaledQuantity(int64(math.Round(value*1e9)), resource.Nano)
+	}
+	maxValue := math.Nextafter(math.MaxInt64, 0)
+	value = math.Max(math.Min(math.Round(value), maxValue), -maxValue)
+	return *resource.NewQuantity(int64(value), resource.DecimalSI)
+}
+
+func newQuantityPtr(value float64) *resource.Quantity {
+	q := newQuantity(value)
+	return &q
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"math"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/aggregation"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+)
+
+const (
+	defaultStdDevFactor = 3.0
+	defaultPercentile   = 99
+
+	// mediumSeverityRatio and highSeverityRatio are the ratios of the observed value to the threshold from which
+	// the interference is medium and high.
+	mediumSeverityRatio = 1.5
+	highSeverityRatio   = 2.0
+)
+
+// verdict is the judgement that the latest sample of a container exceeds the threshold of its workload.
+type verdict struct {
+	podUID        types.UID
+	podNamespace  string
+	podName       string
+	containerName string
+	nodeName      string
+	owner         interferencev1alpha1.WorkloadReference
+
+	value     float64
+	threshold float64
+	// mean is the mean of the baseline, which is nil if the workload has no baseline yet.
+	mean *float64
+}
+
+// detect judges the latest samples of selected workloads by the algorithm of the rule, it must be called before the
+// samples are added so that they are not judged against baselines including themselves, and samples already added
+// are skipped. The baseline of a workload is required unless the algorithm is StaticThreshold, and it must have at
+// least @minSampleCount samples.
+func (s *SampleStore) detect(ruleName types.NamespacedName, metricName interferencev1alpha1.MetricName,
+	podWorkloads map[string]interferencev1alpha1.WorkloadReference, metrics []*common.Metric,
+	algorithm *interferencev1alpha1.DetectionAlgorithm, minSampleCount int64) []verdict {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	samples := s.getOrCreateRule(ruleName)
+
+	var verdicts []verdict
+	for _, metric := range metrics {
+		owner, ok := podWorkloads[metric.Labels[common.PodUID]]
+		if !ok {
+			continue
+		}
+		series := seriesKey{podUID: metric.Labels[common.PodUID], containerName: metric.Labels[common.ContainerName]}
+		if samples.isAdded(series, metric.Timestamp) {
+			continue
+		}
+		key := aggregation.AggregationKey{
+			Owner:         owner,
+			ContainerName: metric.Labels[common.ContainerName],
+			Metric:        metricName,
+		}
+		a, ok := samples.aggregations[key]
+		if ok && a.TotalSamplesCount < minSampleCount {
+			a, ok = nil, false
+		}
+		if !ok && algorithm.Type != interferencev1alpha1.StaticThresholdAlgorithm {
+			continue
+		}
+		threshold, ok := thresholdOf(algorithm, a)
+		if !ok || metric.Value <= threshold {
+			continue
+		}
+		v := verdict{
+			podUID:        types.UID(metric.Labels[common.PodUID]),
+			podNamespace:  metric.Labels[common.PodNamespace],
+			podName:       metric.Labels[common.PodName],
+			containerName: key.ContainerName,
+			nodeName:      metric.Labels[common.Node],
+			owner:         owner,
+			value:         metric.Value,
+			threshold:     threshold,
+		}
+		if a != nil {
+			mean := a.Histogram.Mean()
+			v.mean = &mean
+		}
+		verdicts = append(verdicts, v)
+	}
+	return verdicts
+}
+
+// thresholdOf calculates the upper bound of the metric by the algorithm from the aggregated samples of the
+// workload, which may be nil for StaticThreshold.
+func thresholdOf(algorithm *interferencev1alpha1.DetectionAlgorithm, a *aggregation.ContainerAggregation) (float64, bool) {
+	switch algorithm.Type {
+	case interferencev1alpha1.StaticThresholdAlgorithm:
+		if algorithm.StaticThreshold == nil {
+			return 0, false
+		}
+		return algorithm.StaticThreshold.Threshold.AsApproximateFloat64(), true
+	case interferencev1alpha1.MeanStdDevAlgorithm:
+		if a == nil || a.Histogram.IsEmpty() {
+			return 0, false
+		}
+		factor := defaultStdDevFactor
+		if args := algorithm.MeanStdDev; args != nil && args.StdDevFactor != nil {
+			factor = args.StdDevFactor.AsApproximateFloat64()
+		}
+		return a.Histogram.Mean() + factor*a.Histogram.StdDev(), true
+	case interferencev1alpha1.PercentileAlgorithm:
+		if a == nil || a.Histogram.IsEmpty() {
+			return 0, false
+		}
+		percentile, tolerance := int32(defaultPercentile), 0.0
+		if args := algorithm.Percentile; args != nil {
+			if args.Percentile != nil {
+				percentile = *args.Percentile
+			}
+			if args.Tolerance != nil {
+				tolerance = args.Tolerance.AsApproximateFloat64()
+			}
+		}
+		return a.Histogram.Percentile(float64(percentile)/100) * (1 + tolerance), true
+	}
+	return 0, false
+}
+
+// severityOf judges the severity by the ratio of the observed value to the threshold.
+func severityOf(value, threshold float64) interferencev1alpha1.InterferenceSeverity {
+	ratio := math.Inf(1)
+	if threshold > 0 {
+		ratio = value / threshold
+	}
+	switch {
+	case ratio >= highSeverityRatio:
+		return interferencev1alpha1.InterferenceSeverityHigh
+	case ratio >= mediumSeverityRatio:
+		return interferencev1alpha1.InterferenceSeverityMedium
+	}
+	return interferencev1alpha1.InterferenceSeverityLow
+}
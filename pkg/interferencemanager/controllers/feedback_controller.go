@@ -0,0 +1,292 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+)
+
+// InterferenceFeedbackReconciler feeds the InterferenceEvents of each node back as annotations of the interfered
+// pods and of the node. Requests are keyed by node names.
+type InterferenceFeedbackReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// PodAnnotation enables annotating the interfered pods, which patches the pods of every affected node.
+	PodAnnotation bool
+	// NodeAnnotation enables annotating the affected nodes.
+	NodeAnnotation bool
+}
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=patch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;patch
+
+func (r *InterferenceFeedbackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	nodeName := req.Name
+	eventList := &interferencev1alpha1.InterferenceEventList{}
+	if err := r.List(ctx, eventList, client.MatchingFields{eventNodeNameField: nodeName}); err != nil {
+		return ctrl.Result{}, err
+	}
+	events := make([]*interferencev1alpha1.InterferenceEvent, 0, len(eventList.Items))
+	for i := range eventList.Items {
+		events = append(events, &eventList.Items[i])
+	}
+
+	if r.PodAnnotation {
+		if err := r.annotatePods(ctx, nodeName, events); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if r.NodeAnnotation {
+		if err := r.annotateNode(ctx, nodeName, events); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// annotatePods sets the annotation of the pods interfered on the node, and removes it from the pods no longer
+// interfered.
+func (r *InterferenceFeedbackReconciler) annotatePods(ctx context.Context, nodeName string,
+	events []*interferencev1alpha1.InterferenceEvent) error {
+	logger := log.FromContext(ctx)
+	podInterferences := podInterferencesOf(events)
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, client.MatchingFields{podNodeNameField: nodeName}); err != nil {
+		return err
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		var value string
+		if interference, ok := podInterferences[pod.UID]; ok {
+			data, err := json.Marshal(interference)
+			if err != nil {
+				return err
+			}
+			value = string(data)
+		}
+		if pod.Annotations[interferencev1alpha1.AnnotationPodInterference] == value {
+			continue
+		}
+		patch := client.MergeFrom(pod.DeepCopy())
+		if value == "" {
+			delete(pod.Annotations, interferencev1alpha1.AnnotationPodInterference)
+		} else {
+			if pod.Annotations == nil {
+				pod.Annotations = map[string]string{}
+			}
+			pod.Annotations[interferencev1alpha1.AnnotationPodInterference] = value
+		}
+		if err := r.Patch(ctx, pod, patch); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		logger.V(4).Info("updated interference of pod", "pod", pod.Namespace+"/"+pod.Name, "interference", value)
+	}
+	return nil
+}
+
+// annotateNode sets the annotation of the node, or removes it if no pod on the node is interfered.
+func (r *InterferenceFeedbackReconciler) annotateNode(ctx context.Context, nodeName string,
+	events []*interferencev1alpha1.InterferenceEvent) error {
+	node := &corev1.Node{}
+	if err := r.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	var value string
+	if len(events) > 0 {
+		data, err := json.Marshal(nodeInterferenceOf(events))
+		if err != nil {
+			return err
+		}
+		value = string(data)
+	}
+	if node.Annotations[interferencev1alpha1.AnnotationNodeInterference] == value {
+		return nil
+	}
+
+	patch := client.MergeFrom(node.DeepCopy())
+	if value == "" {
+		delete(node.Annotations, interferencev1alpha1.AnnotationNodeInterference)
+	} else {
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[interferencev1alpha1.AnnotationNodeInterference] = value
+	}
+	if err := r.Patch(ctx, node, patch); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	log.FromContext(ctx).V(4).Info("updated interference of node", "node", nodeName, "interference", value)
+	return nil
+}
+
+// podInterferencesOf summarizes the events by the interfered pods.
+func podInterferencesOf(events []*interferencev1alpha1.InterferenceEvent) map[types.UID]*interferencev1alpha1.PodInterference {
+	interferences := map[types.UID]*interferencev1alpha1.PodInterference{}
+	for _, event := range events {
+		interference, ok := interferences[event.Spec.Target.UID]
+		if !ok {
+			interference = &interferencev1alpha1.PodInterference{}
+			interferences[event.Spec.Target.UID] = interference
+		}
+		interference.Containers = append(interference.Containers, interferencev1alpha1.ContainerInterference{
+			ContainerName: event.Spec.Target.ContainerName,
+			RuleName:      event.Spec.RuleName,
+			Metric:        event.Spec.Metric,
+			Severity:      event.Spec.Severity,
+		})
+		interference.Severity = maxSeverity(interference.Severity, event.Spec.Severity)
+	}
+	for _, interference := range interferences {
+		containers := interference.Containers
+		sort.Slice(containers, func(i, j int) bool {
+			if containers[i].ContainerName != containers[j].ContainerName {
+				return containers[i].ContainerName < containers[j].ContainerName
+			}
+			return containers[i].RuleName < containers[j].RuleName
+		})
+	}
+	return interferences
+}
+
+// nodeInterferenceOf summarizes the events of pods on a node.
+func nodeInterferenceOf(events []*interferencev1alpha1.InterferenceEvent) *interferencev1alpha1.NodeInterference {
+	interference := &interferencev1alpha1.NodeInterference{}
+	victims := map[types.UID]*interferencev1alpha1.InterferenceVictim{}
+	type suspect struct {
+		aggressor *interferencev1alpha1.AggressorPod
+		victims   map[types.UID]struct{}
+	}
+	suspects := map[types.UID]*suspect{}
+	for _, event := range events {
+		target := &event.Spec.Target
+		interference.Severity = maxSeverity(interference.Severity, event.Spec.Severity)
+		victim, ok := victims[target.UID]
+		if !ok {
+			victim = &interferencev1alpha1.InterferenceVictim{
+				Namespace: target.Namespace,
+				Name:      target.Name,
+				UID:       target.UID,
+			}
+			victims[target.UID] = victim
+		}
+		victim.Severity = maxSeverity(victim.Severity, event.Spec.Severity)
+		for i := range event.Spec.Aggressors {
+			aggressor := &event.Spec.Aggressors[i]
+			s, ok := suspects[aggressor.UID]
+			if !ok {
+				s = &suspect{aggressor: aggressor, victims: map[types.UID]struct{}{}}
+				suspects[aggressor.UID] = s
+			}
+			s.victims[target.UID] = struct{}{}
+		}
+	}
+
+	for _, victim := range victims {
+		interference.Victims = append(interference.Victims, *victim)
+	}
+	sort.Slice(interference.Victims, func(i, j int) bool {
+		vi, vj := &interference.Victims[i], &interference.Victims[j]
+		if ri, rj := severityRank(vi.Severity), severityRank(vj.Severity); ri != rj {
+			return ri > rj
+		}
+		if vi.Namespace != vj.Namespace {
+			return vi.Namespace < vj.Namespace
+		}
+		return vi.Name < vj.Name
+	})
+	sorted := make([]*suspect, 0, len(suspects))
+	for _, s := range suspects {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i].victims) != len(sorted[j].victims) {
+			return len(sorted[i].victims) > len(sorted[j].victims)
+		}
+		if sorted[i].aggressor.Namespace != sorted[j].aggressor.Namespace {
+			return sorted[i].aggressor.Namespace < sorted[j].aggressor.Namespace
+		}
+		return sorted[i].aggressor.Name < sorted[j].aggressor.Name
+	})
+	for _, s := range sorted {
+		interference.Aggressors = append(interference.Aggressors, *s.aggressor)
+	}
+	return interference
+}
+
+func severityRank(severity interferencev1alpha1.InterferenceSeverity) int {
+	switch severity {
+	case interferencev1alpha1.InterferenceSeverityLow:
+		return 1
+	case interferencev1alpha1.InterferenceSeverityMedium:
+		return 2
+	case interferencev1alpha1.InterferenceSeverityHigh:
+		return 3
+	}
+	return 0
+}
+
+func maxSeverity(a, b interferencev1alpha1.InterferenceSeverity) interferencev1alpha1.InterferenceSeverity {
+	if severityRank(b) > severityRank(a) {
+		return b
+	}
+	return a
+}
+
+// SetupWithManager sets up the controller with the Manager. Events are mapped to the nodes of their targets, and
+// the annotations of nodes are watched to restore the feedback if it is changed by others.
+func (r *InterferenceFeedbackReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := indexField(mgr, &interferencev1alpha1.InterferenceEvent{}, eventNodeNameField, indexEventNodeName); err != nil {
+		return err
+	}
+	if err := indexField(mgr, &corev1.Pod{}, podNodeNameField, indexPodNodeName); err != nil {
+		return err
+	}
+	c, err := controller.New("interference-feedback", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+	if r.NodeAnnotation {
+		if err := c.Watch(&source.Kind{Type: &corev1.Node{}}, &handler.EnqueueRequestForObject{},
+			predicate.AnnotationChangedPredicate{}); err != nil {
+			return err
+		}
+	}
+	return c.Watch(&source.Kind{Type: &interferencev1alpha1.InterferenceEvent{}},
+		handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []ctrl.Request {
+			event, ok := obj.(*interferencev1alpha1.InterferenceEvent)
+			if !ok || event.Spec.Target.NodeName == "" {
+				return nil
+			}
+			return []ctrl.Request{{NamespacedName: types.NamespacedName{Name: event.Spec.Target.NodeName}}}
+		}))
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+)
+
+func newTestEvent(name string, target *corev1.Pod, containerName string, severity interferencev1alpha1.InterferenceSeverity,
+	aggressors ...*corev1.Pod) *interferencev1alpha1.InterferenceEvent {
+	event := &interferencev1alpha1.InterferenceEvent{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: interferencev1alpha1.InterferenceEventSpec{
+			RuleName: "test-rule",
+			Target: interferencev1alpha1.InterferenceTarget{
+				Namespace:     target.Namespace,
+				Name:          target.Name,
+				UID:           target.UID,
+				ContainerName: containerName,
+				NodeName:      target.Spec.NodeName,
+			},
+			Metric:   interferencev1alpha1.MetricContainerCPI,
+			Severity: severity,
+		},
+	}
+	for _, pod := range aggressors {
+		event.Spec.Aggressors = append(event.Spec.Aggressors, interferencev1alpha1.AggressorPod{
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			UID:       pod.UID,
+			QOSClass:  string(pod.Status.QOSClass),
+		})
+	}
+	return event
+}
+
+func TestInterferenceFeedbackReconcile(t *testing.T) {
+	web1 := newTestNodePod("web-1", "uid-1", "node-1", corev1.PodQOSGuaranteed, "1")
+	web2 := newTestNodePod("web-2", "uid-2", "node-1", corev1.PodQOSBurstable, "1")
+	batch1 := newTestNodePod("batch-1", "uid-3", "node-1", corev1.PodQOSBestEffort, "")
+	batch2 := newTestNodePod("batch-2", "uid-4", "node-1", corev1.PodQOSBestEffort, "")
+	web3 := newTestNodePod("web-3", "uid-5", "node-2", corev1.PodQOSGuaranteed, "1")
+	// the annotation of pods no longer interfered is removed
+	web2.Annotations = map[string]string{interferencev1alpha1.AnnotationPodInterference: `{"severity":"Low"}`}
+	events := []*interferencev1alpha1.InterferenceEvent{
+		newTestEvent("event-1", web1, "main", interferencev1alpha1.InterferenceSeverityLow, batch2, batch1),
+		newTestEvent("event-2", web1, "sidecar", interferencev1alpha1.InterferenceSeverityHigh, batch1),
+		newTestEvent("event-3", web3, "main", interferencev1alpha1.InterferenceSeverityMedium),
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1", Annotations: map[string]string{"other": "value"}},
+	}
+	client := newIndexedClient(fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(
+		web1, web2, batch1, batch2, web3, events[0], events[1], events[2], node,
+	).Build())
+	r := &InterferenceFeedbackReconciler{
+		Client:         client,
+		Scheme:         client.Scheme(),
+		PodAnnotation:  true,
+		NodeAnnotation: true,
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "node-1"}}
+
+	_, err := r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	pod := &corev1.Pod{}
+	assert.NoError(t, client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "web-1"}, pod))
+	podInterference := &interferencev1alpha1.PodInterference{}
+	assert.NoError(t, json.Unmarshal([]byte(pod.Annotations[interferencev1alpha1.AnnotationPodInterference]), podInterference))
+	assert.Equal(t, &interferencev1alpha1.PodInterference{
+		Severity: interferencev1alpha1.InterferenceSeverityHigh,
+		Containers: []interferencev1alpha1.ContainerInterference{
+			{ContainerName: "main", RuleName: "test-rule", Metric: interferencev1alpha1.MetricContainerCPI, Severity: interferencev1alpha1.InterferenceSeverityLow},
+			{ContainerName: "sidecar", RuleName: "test-rule", Metric: interferencev1alpha1.MetricContainerCPI, Severity: interferencev1alpha1.InterferenceSeverityHigh},
+		},
+	}, podInterference)
+	assert.NoError(t, client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "web-2"}, pod))
+	assert.NotContains(t, pod.Annotations, interferencev1alpha1.AnnotationPodInterference)
+	// pods on other nodes are left to the requests of their nodes
+	assert.NoError(t, client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "web-3"}, pod))
+	assert.NotContains(t, pod.Annotations, interferencev1alpha1.AnnotationPodInterference)
+
+	assert.NoError(t, client.Get(context.TODO(), req.NamespacedName, node))
+	assert.Equal(t, "value", node.Annotations["other"])
+	nodeInterference := &interferencev1alpha1.NodeInterference{}
+	assert.NoError(t, json.Unmarshal([]byte(node.Annotations[interferencev1alpha1.AnnotationNodeInterference]), nodeInterference))
+	assert.Equal(t, interferencev1alpha1.InterferenceSeverityHigh, nodeInterference.Severity)
+	assert.Equal(t, []interferencev1alpha1.InterferenceVictim{
+		{Namespace: "default", Name: "web-1", UID: "uid-1", Severity: interferencev1alpha1.InterferenceSeverityHigh},
+	}, nodeInterference.Victims)
+	// batch-1 is suspected by both events of web-1
+	assert.Len(t, nodeInterference.Aggressors, 2)
+	assert.Equal(t, "batch-1", nodeInterference.Aggressors[0].Name)
+	assert.Equal(t, "batch-2", nodeInterference.Aggressors[1].Name)
+
+	// the feedback is unchanged if the events are not
+	resourceVersion := node.ResourceVersion
+	_, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	assert.NoError(t, client.Get(context.TODO(), req.NamespacedName, node))
+	assert.Equal(t, resourceVersion, node.ResourceVersion)
+
+	// the feedback is removed once the events are deleted
+	assert.NoError(t, client.Delete(context.TODO(), events[0]))
+	assert.NoError(t, client.Delete(context.TODO(), events[1]))
+	_, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	assert.NoError(t, client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "web-1"}, pod))
+	assert.NotContains(t, pod.Annotations, interferencev1alpha1.AnnotationPodInterference)
+	assert.NoError(t, client.Get(context.TODO(), req.NamespacedName, node))
+	assert.NotContains(t, node.Annotations, interferencev1alpha1.AnnotationNodeInterference)
+	assert.Equal(t, "value", node.Annotations["other"])
+
+	// pods are still annotated if their nodes are not found
+	_, err = r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "node-2"}})
+	assert.NoError(t, err)
+	assert.NoError(t, client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "web-3"}, pod))
+	assert.Contains(t, pod.Annotations, interferencev1alpha1.AnnotationPodInterference)
+}
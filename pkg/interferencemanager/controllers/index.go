@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+)
+
+const (
+	// podNodeNameField indexes pods by the nodes they are scheduled to.
+	podNodeNameField = "spec.nodeName"
+	// eventNodeNameField indexes InterferenceEvents by the nodes of their targets.
+	eventNodeNameField = "spec.target.nodeName"
+)
+
+var (
+	indexLock sync.Mutex
+	// indexedFields are the fields indexed in the caches of managers, since reconcilers sharing an index can not
+	// register it twice.
+	indexedFields = map[indexedField]bool{}
+)
+
+type indexedField struct {
+	indexer client.FieldIndexer
+	object  string
+	field   string
+}
+
+// indexField indexes @field of @obj in the cache of @mgr unless it is indexed.
+func indexField(mgr ctrl.Manager, obj client.Object, field string, extractValue client.IndexerFunc) error {
+	indexLock.Lock()
+	defer indexLock.Unlock()
+	key := indexedField{indexer: mgr.GetFieldIndexer(), object: fmt.Sprintf("%T", obj), field: field}
+	if indexedFields[key] {
+		return nil
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), obj, field, extractValue); err != nil {
+		return err
+	}
+	indexedFields[key] = true
+	return nil
+}
+
+func indexPodNodeName(obj client.Object) []string {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.Spec.NodeName == "" {
+		return nil
+	}
+	return []string{pod.Spec.NodeName}
+}
+
+func indexEventNodeName(obj client.Object) []string {
+	event, ok := obj.(*interferencev1alpha1.InterferenceEvent)
+	if !ok || event.Spec.Target.NodeName == "" {
+		return nil
+	}
+	return []string{event.Spec.Target.NodeName}
+}
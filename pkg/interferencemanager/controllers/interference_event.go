@@ -0,0 +1,221 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+)
+
+const (
+	defaultEventTTL = time.Hour
+	// maxAggressors is the maximum number of aggressors recorded in an event.
+	maxAggressors = 5
+)
+
+// recordEvents writes an InterferenceEvent for each verdict, events of the same pod and container are updated
+// in place, so that a lasting interference is recorded by one event. Events are written in the namespace of the
+// rule, which is the namespace of the targets, while aggressors are suspected among the pods of all namespaces on
+// the nodes of the targets.
+func (r *InterferenceDetectionRuleReconciler) recordEvents(ctx context.Context, rule *interferencev1alpha1.InterferenceDetectionRule,
+	podWorkloads map[string]interferencev1alpha1.WorkloadReference, verdicts []verdict, now time.Time) error {
+	if len(verdicts) == 0 {
+		return nil
+	}
+	logger := log.FromContext(ctx)
+
+	// pods are listed by nodes with the index, and only once for the verdicts on the same node
+	nodePods := map[string][]*corev1.Pod{}
+	listNodePods := func(nodeName string) ([]*corev1.Pod, error) {
+		if pods, ok := nodePods[nodeName]; ok || nodeName == "" {
+			return pods, nil
+		}
+		podList := &corev1.PodList{}
+		if err := r.List(ctx, podList, client.MatchingFields{podNodeNameField: nodeName}); err != nil {
+			return nil, err
+		}
+		pods := make([]*corev1.Pod, 0, len(podList.Items))
+		for i := range podList.Items {
+			pods = append(pods, &podList.Items[i])
+		}
+		nodePods[nodeName] = pods
+		return pods, nil
+	}
+
+	ttl := eventTTLOf(rule)
+	for i := range verdicts {
+		v := &verdicts[i]
+		target := interferencev1alpha1.InterferenceTarget{
+			Namespace:     rule.Namespace,
+			Name:          v.podName,
+			UID:           v.podUID,
+			ContainerName: v.containerName,
+			NodeName:      v.nodeName,
+			Owner:         v.owner,
+		}
+		if target.Name != "" && target.NodeName == "" {
+			pod := &corev1.Pod{}
+			if err := r.Get(ctx, types.NamespacedName{Namespace: rule.Namespace, Name: target.Name}, pod); err == nil &&
+				pod.UID == target.UID {
+				target.NodeName = pod.Spec.NodeName
+			}
+		}
+		pods, err := listNodePods(target.NodeName)
+		if err != nil {
+			return err
+		}
+		aggressors := suspectAggressors(&target, pods, podWorkloads)
+		if err := r.writeEvent(ctx, rule, &target, v, aggressors, now, ttl); err != nil {
+			logger.Error(err, "failed to write interference event", "pod", target.Namespace+"/"+target.Name,
+				"container", target.ContainerName)
+		}
+	}
+	return nil
+}
+
+func (r *InterferenceDetectionRuleReconciler) writeEvent(ctx context.Context, rule *interferencev1alpha1.InterferenceDetectionRule,
+	target *interferencev1alpha1.InterferenceTarget, v *verdict, aggressors []interferencev1alpha1.AggressorPod,
+	now time.Time, ttl time.Duration) error {
+	event := &interferencev1alpha1.InterferenceEvent{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: rule.Namespace,
+			Name:      eventName(rule.Name, target),
+		},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, event, func() error {
+		spec := &event.Spec
+		// an expired event which is not deleted yet starts over
+		if event.ResourceVersion == "" || !now.Before(spec.ExpireTime.Time) {
+			spec.FirstDetectedTime = metav1.NewTime(now)
+			spec.Count = 0
+		}
+		spec.RuleName = rule.Name
+		spec.Target = *target
+		spec.Metric = rule.Spec.Metric.Name
+		spec.ObservedValue = newQuantity(v.value)
+		spec.BaselineMean = nil
+		if v.mean != nil {
+			spec.BaselineMean = newQuantityPtr(*v.mean)
+		}
+		spec.Threshold = newQuantity(v.threshold)
+		spec.Severity = severityOf(v.value, v.threshold)
+		spec.Aggressors = aggressors
+		spec.LastDetectedTime = metav1.NewTime(now)
+		spec.Count++
+		spec.ExpireTime = metav1.NewTime(now.Add(ttl))
+		return controllerutil.SetOwnerReference(rule, event, r.Scheme)
+	})
+	return err
+}
+
+// eventName generates a stable name for the event of a container detected by the rule.
+func eventName(ruleName string, target *interferencev1alpha1.InterferenceTarget) string {
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte(fmt.Sprintf("%s/%s", target.UID, target.ContainerName)))
+	return fmt.Sprintf("%s-%08x", ruleName, hasher.Sum32())
+}
+
+func eventTTLOf(rule *interferencev1alpha1.InterferenceDetectionRule) time.Duration {
+	if rule.Spec.EventTTL != nil && rule.Spec.EventTTL.Duration > 0 {
+		return rule.Spec.EventTTL.Duration
+	}
+	return defaultEventTTL
+}
+
+// suspectAggressors picks the running pods on the node of the target except those of the target's workload.
+// Pods of lower QoS classes are more suspected since they are usually batch jobs, then pods requesting more CPU.
+func suspectAggressors(target *interferencev1alpha1.InterferenceTarget, nodePods []*corev1.Pod,
+	podWorkloads map[string]interferencev1alpha1.WorkloadReference) []interferencev1alpha1.AggressorPod {
+	type candidate struct {
+		pod        *corev1.Pod
+		cpuRequest resource.Quantity
+	}
+	var candidates []candidate
+	for _, pod := range nodePods {
+		if pod.UID == target.UID || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if owner, ok := podWorkloads[string(pod.UID)]; ok && owner == target.Owner {
+			continue
+		}
+		c := candidate{pod: pod}
+		for i := range pod.Spec.Containers {
+			if request, ok := pod.Spec.Containers[i].Resources.Requests[corev1.ResourceCPU]; ok {
+				c.cpuRequest.Add(request)
+			}
+		}
+		candidates = append(candidates, c)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		qi, qj := qosRank(candidates[i].pod.Status.QOSClass), qosRank(candidates[j].pod.Status.QOSClass)
+		if qi != qj {
+			return qi < qj
+		}
+		if cmp := candidates[i].cpuRequest.Cmp(candidates[j].cpuRequest); cmp != 0 {
+			return cmp > 0
+		}
+		if candidates[i].pod.Namespace != candidates[j].pod.Namespace {
+			return candidates[i].pod.Namespace < candidates[j].pod.Namespace
+		}
+		return candidates[i].pod.Name < candidates[j].pod.Name
+	})
+	if len(candidates) > maxAggressors {
+		candidates = candidates[:maxAggressors]
+	}
+
+	aggressors := make([]interferencev1alpha1.AggressorPod, 0, len(candidates))
+	for _, c := range candidates {
+		aggressor := interferencev1alpha1.AggressorPod{
+			Namespace: c.pod.Namespace,
+			Name:      c.pod.Name,
+			UID:       c.pod.UID,
+			QOSClass:  string(c.pod.Status.QOSClass),
+		}
+		if !c.cpuRequest.IsZero() {
+			cpuRequest := c.cpuRequest.DeepCopy()
+			aggressor.CPURequest = &cpuRequest
+		}
+		aggressors = append(aggressors, aggressor)
+	}
+	return aggressors
+}
+
+func qosRank(qosClass corev1.PodQOSClass) int {
+	switch qosClass {
+	case corev1.PodQOSBestEffort:
+		return 0
+	case corev1.PodQOSBurstable:
+		return 1
+	case corev1.PodQOSGuaranteed:
+		return 2
+	}
+	// unknown classes are least suspected
+	return 3
+}
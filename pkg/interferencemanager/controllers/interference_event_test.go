@@ -0,0 +1,263 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/aggregation"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+)
+
+// indexedClient selects objects by the field selectors of indexes in List like the cache does, which the fake
+// client ignores.
+type indexedClient struct {
+	client.Client
+	indexers map[string]client.IndexerFunc
+}
+
+func newIndexedClient(c client.Client) *indexedClient {
+	return &indexedClient{
+		Client: c,
+		indexers: map[string]client.IndexerFunc{
+			podNodeNameField:   indexPodNodeName,
+			eventNodeNameField: indexEventNodeName,
+		},
+	}
+}
+
+func (c *indexedClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := c.Client.List(ctx, list, opts...); err != nil {
+		return err
+	}
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	if listOpts.FieldSelector == nil {
+		return nil
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	var selected []runtime.Object
+	for _, item := range items {
+		indexed := fields.Set{}
+		for field, indexer := range c.indexers {
+			if values := indexer(item.(client.Object)); len(values) > 0 {
+				indexed[field] = values[0]
+			}
+		}
+		if listOpts.FieldSelector.Matches(indexed) {
+			selected = append(selected, item)
+		}
+	}
+	return meta.SetList(list, selected)
+}
+
+func newTestNodePod(name, uid, node string, qosClass corev1.PodQOSClass, cpuRequest string) *corev1.Pod {
+	pod := newTestPod(name, uid, "ReplicaSet", name)
+	pod.Spec.NodeName = node
+	pod.Status.QOSClass = qosClass
+	container := corev1.Container{Name: "main"}
+	if cpuRequest != "" {
+		container.Resources.Requests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpuRequest)}
+	}
+	pod.Spec.Containers = []corev1.Container{container}
+	return pod
+}
+
+func TestThresholdOf(t *testing.T) {
+	now := time.Now()
+	a := aggregation.NewContainerAggregation(aggregation.NewDefaultHistogramOptions(), time.Hour)
+	for _, value := range []float64{1, 3, 1, 3} {
+		a.AddSample(value, now)
+	}
+
+	threshold, ok := thresholdOf(&interferencev1alpha1.DetectionAlgorithm{
+		Type:            interferencev1alpha1.StaticThresholdAlgorithm,
+		StaticThreshold: &interferencev1alpha1.StaticThresholdArgs{Threshold: resource.MustParse("1.5")},
+	}, nil)
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, threshold)
+
+	threshold, ok = thresholdOf(&interferencev1alpha1.DetectionAlgorithm{Type: interferencev1alpha1.MeanStdDevAlgorithm}, a)
+	assert.True(t, ok)
+	assert.InEpsilon(t, 5, threshold, 0.05)
+
+	factor := resource.MustParse("1")
+	threshold, ok = thresholdOf(&interferencev1alpha1.DetectionAlgorithm{
+		Type:       interferencev1alpha1.MeanStdDevAlgorithm,
+		MeanStdDev: &interferencev1alpha1.MeanStdDevArgs{StdDevFactor: &factor},
+	}, a)
+	assert.True(t, ok)
+	assert.InEpsilon(t, 3, threshold, 0.05)
+
+	tolerance := resource.MustParse("0.5")
+	threshold, ok = thresholdOf(&interferencev1alpha1.DetectionAlgorithm{
+		Type:       interferencev1alpha1.PercentileAlgorithm,
+		Percentile: &interferencev1alpha1.PercentileArgs{Percentile: pointer.Int32(50), Tolerance: &tolerance},
+	}, a)
+	assert.True(t, ok)
+	assert.InEpsilon(t, 1.5*a.Histogram.Percentile(0.5), threshold, 0.001)
+
+	// baseline algorithms cannot judge workloads without baselines
+	_, ok = thresholdOf(&interferencev1alpha1.DetectionAlgorithm{Type: interferencev1alpha1.PercentileAlgorithm}, nil)
+	assert.False(t, ok)
+	_, ok = thresholdOf(&interferencev1alpha1.DetectionAlgorithm{Type: interferencev1alpha1.StaticThresholdAlgorithm}, nil)
+	assert.False(t, ok)
+}
+
+func TestSeverityOf(t *testing.T) {
+	assert.Equal(t, interferencev1alpha1.InterferenceSeverityLow, severityOf(1.2, 1))
+	assert.Equal(t, interferencev1alpha1.InterferenceSeverityMedium, severityOf(1.5, 1))
+	assert.Equal(t, interferencev1alpha1.InterferenceSeverityHigh, severityOf(3, 1))
+	assert.Equal(t, interferencev1alpha1.InterferenceSeverityHigh, severityOf(0.1, 0))
+}
+
+func TestSuspectAggressors(t *testing.T) {
+	target := newTestNodePod("target", "uid-0", "node-1", corev1.PodQOSGuaranteed, "1")
+	sibling := newTestNodePod("target-2", "uid-1", "node-1", corev1.PodQOSBestEffort, "")
+	finished := newTestNodePod("finished", "uid-2", "node-1", corev1.PodQOSBestEffort, "")
+	finished.Status.Phase = corev1.PodSucceeded
+	nodePods := []*corev1.Pod{
+		target,
+		sibling,
+		finished,
+		newTestNodePod("guaranteed", "uid-3", "node-1", corev1.PodQOSGuaranteed, "4"),
+		newTestNodePod("burstable-small", "uid-4", "node-1", corev1.PodQOSBurstable, "500m"),
+		newTestNodePod("burstable-large", "uid-5", "node-1", corev1.PodQOSBurstable, "2"),
+		newTestNodePod("besteffort", "uid-6", "node-1", corev1.PodQOSBestEffort, ""),
+	}
+	owner := interferencev1alpha1.WorkloadReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "target"}
+	podWorkloads := map[string]interferencev1alpha1.WorkloadReference{
+		"uid-0": owner,
+		"uid-1": owner,
+	}
+
+	aggressors := suspectAggressors(&interferencev1alpha1.InterferenceTarget{
+		Namespace: "default",
+		Name:      "target",
+		UID:       "uid-0",
+		NodeName:  "node-1",
+		Owner:     owner,
+	}, nodePods, podWorkloads)
+	var names []string
+	for _, aggressor := range aggressors {
+		names = append(names, aggressor.Name)
+	}
+	assert.Equal(t, []string{"besteffort", "burstable-large", "burstable-small", "guaranteed"}, names)
+	assert.Nil(t, aggressors[0].CPURequest)
+	assert.Equal(t, "2", aggressors[1].CPURequest.String())
+	assert.Equal(t, string(corev1.PodQOSBurstable), aggressors[1].QOSClass)
+}
+
+func TestInterferenceDetectionRuleRecordEvents(t *testing.T) {
+	rule := newTestRule()
+	rule.UID = "rule-uid"
+	rule.Spec.Algorithm = interferencev1alpha1.DetectionAlgorithm{
+		Type:            interferencev1alpha1.StaticThresholdAlgorithm,
+		StaticThreshold: &interferencev1alpha1.StaticThresholdArgs{Threshold: resource.MustParse("2")},
+	}
+	rule.Spec.EventTTL = &metav1.Duration{Duration: 10 * time.Minute}
+	victim := newTestNodePod("web-1", "uid-1", "node-1", corev1.PodQOSBurstable, "1")
+	victim.Labels = map[string]string{"app": "web"}
+	victim.OwnerReferences[0].Name = "web"
+	// pods of other namespaces are not targets, but may be aggressors on the same node
+	otherNamespace := newTestNodePod("batch", "uid-4", "node-1", corev1.PodQOSBestEffort, "")
+	otherNamespace.Namespace = "kube-system"
+	client := newIndexedClient(fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(
+		rule,
+		victim,
+		newTestNodePod("batch", "uid-2", "node-1", corev1.PodQOSBestEffort, ""),
+		newTestNodePod("other", "uid-3", "node-2", corev1.PodQOSBestEffort, ""),
+		otherNamespace,
+	).Build())
+	provider := &fakeMetricProvider{
+		metrics: []*common.Metric{
+			{Labels: map[string]string{common.PodUID: "uid-1", common.PodNamespace: "default", common.PodName: "web-1",
+				common.ContainerName: "main"}, Value: 5},
+			{Labels: map[string]string{common.PodUID: "uid-4", common.PodNamespace: "kube-system", common.PodName: "batch",
+				common.ContainerName: "main"}, Value: 5},
+		},
+	}
+	store := NewSampleStore()
+	store.markRestored()
+	r := &InterferenceDetectionRuleReconciler{
+		Client:         client,
+		Scheme:         client.Scheme(),
+		MetricProvider: provider,
+		Store:          store,
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: rule.Namespace, Name: rule.Name}}
+
+	_, err := r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	events := &interferencev1alpha1.InterferenceEventList{}
+	assert.NoError(t, client.List(context.TODO(), events))
+	assert.Len(t, events.Items, 1)
+	event := events.Items[0]
+	assert.Equal(t, rule.Name, event.Spec.RuleName)
+	assert.Equal(t, "web-1", event.Spec.Target.Name)
+	assert.Equal(t, "main", event.Spec.Target.ContainerName)
+	assert.Equal(t, "node-1", event.Spec.Target.NodeName)
+	assert.Equal(t, "web", event.Spec.Target.Owner.Name)
+	assert.Equal(t, interferencev1alpha1.InterferenceSeverityHigh, event.Spec.Severity)
+	assert.Equal(t, 5.0, event.Spec.ObservedValue.AsApproximateFloat64())
+	assert.Equal(t, 2.0, event.Spec.Threshold.AsApproximateFloat64())
+	if assert.Len(t, event.Spec.Aggressors, 2) {
+		assert.Equal(t, "batch", event.Spec.Aggressors[0].Name)
+		assert.Equal(t, "default", event.Spec.Aggressors[0].Namespace)
+		assert.Equal(t, "batch", event.Spec.Aggressors[1].Name)
+		assert.Equal(t, "kube-system", event.Spec.Aggressors[1].Namespace)
+	}
+	assert.Equal(t, int32(1), event.Spec.Count)
+	assert.Equal(t, 10*time.Minute, event.Spec.ExpireTime.Sub(event.Spec.LastDetectedTime.Time))
+	assert.Len(t, event.OwnerReferences, 1)
+	assert.Equal(t, rule.UID, event.OwnerReferences[0].UID)
+
+	// repeated detections update the same event
+	provider.metrics[0].Value = 2.5
+	_, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	assert.NoError(t, client.List(context.TODO(), events))
+	assert.Len(t, events.Items, 1)
+	assert.Equal(t, int32(2), events.Items[0].Spec.Count)
+	assert.Equal(t, interferencev1alpha1.InterferenceSeverityLow, events.Items[0].Spec.Severity)
+
+	// values below the threshold fire no event
+	provider.metrics[0].Value = 1
+	_, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	assert.NoError(t, client.List(context.TODO(), events))
+	assert.Equal(t, int32(2), events.Items[0].Spec.Count)
+}
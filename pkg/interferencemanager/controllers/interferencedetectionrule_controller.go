@@ -18,45 +18,319 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+	metric_provider "github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+)
+
+const (
+	defaultEvaluationWindow   = 24 * time.Hour
+	defaultEvaluationInterval = time.Minute
+	defaultMinSampleCount     = 10
+
+	// waitRestoreInterval is the interval to requeue rules until the sample store is restored from checkpoints.
+	waitRestoreInterval = time.Second
+	// backfillMinGap is the minimal number of missing evaluation intervals to backfill with a range query.
+	backfillMinGap = 3
 )
 
 // InterferenceDetectionRuleReconciler reconciles a InterferenceDetectionRule object
 type InterferenceDetectionRuleReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme         *runtime.Scheme
+	MetricProvider metric_provider.MetricProvider
+	Store          *SampleStore
+	// PollInterval is the evaluation interval of rules without one, default to 1 minute.
+	PollInterval time.Duration
 }
 
 //+kubebuilder:rbac:groups=interference.koordinator.sh,resources=interferencedetectionrules,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=interference.koordinator.sh,resources=interferencedetectionrules/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=interference.koordinator.sh,resources=interferencedetectionrules/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=custom.metrics.k8s.io;external.metrics.k8s.io,resources=*,verbs=get;list
+//+kubebuilder:rbac:groups=slo.koordinator.sh,resources=nodemetrics,verbs=get;list;watch
+//+kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the InterferenceDetectionRule object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
-//
-// For more details, check Reconcile and its Result here:
-// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.1/pkg/reconcile
+// Reconcile resolves the workloads selected by the rule, collects their samples from the metric provider and
+// calculates their baselines within the evaluation window into the rule's status. Containers whose latest samples
+// exceed the thresholds of the algorithm are recorded into InterferenceEvents. The rule is requeued on its
+// evaluation interval to keep collecting samples.
 func (r *InterferenceDetectionRuleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+	logger := log.FromContext(ctx)
+
+	rule := &interferencev1alpha1.InterferenceDetectionRule{}
+	if err := r.Get(ctx, req.NamespacedName, rule); err != nil {
+		if errors.IsNotFound(err) {
+			r.Store.forgetRule(req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if !r.Store.HasRestored() {
+		logger.V(4).Info("waiting for samples restored from checkpoints")
+		return ctrl.Result{RequeueAfter: waitRestoreInterval}, nil
+	}
+
+	newStatus := rule.Status.DeepCopy()
+	newStatus.ObservedGeneration = rule.Generation
+
+	if err := validateRule(rule); err != nil {
+		logger.Info("invalid interference detection rule", "reason", err.Error())
+		meta.SetStatusCondition(&newStatus.Conditions, metav1.Condition{
+			Type:    interferencev1alpha1.RuleConditionBaselineReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  "InvalidSpec",
+			Message: err.Error(),
+		})
+		// the rule will be reconciled again once its spec is updated
+		return ctrl.Result{}, r.updateStatus(ctx, rule, newStatus)
+	}
+	window, interval, minSampleCount := evaluationArgs(rule, r.PollInterval)
+
+	podWorkloads, err := selectWorkloadPods(ctx, r.Client, rule.Namespace, rule.Spec.WorkloadSelector)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 
-	// TODO(user): your logic here
+	now := time.Now()
+	r.backfill(ctx, rule, podWorkloads, window, interval, now)
+	metrics, err := r.queryMetric(rule.Spec.Metric.Name, rule.Namespace)
+	if err != nil {
+		logger.Error(err, "failed to query metric", "metric", rule.Spec.Metric.Name)
+		meta.SetStatusCondition(&newStatus.Conditions, metav1.Condition{
+			Type:    interferencev1alpha1.RuleConditionMetricAvailable,
+			Status:  metav1.ConditionFalse,
+			Reason:  "QueryFailed",
+			Message: err.Error(),
+		})
+		return ctrl.Result{RequeueAfter: interval}, r.updateStatus(ctx, rule, newStatus)
+	}
+	meta.SetStatusCondition(&newStatus.Conditions, metav1.Condition{
+		Type:   interferencev1alpha1.RuleConditionMetricAvailable,
+		Status: metav1.ConditionTrue,
+		Reason: "QuerySucceeded",
+	})
+
+	verdicts := r.Store.detect(req.NamespacedName, rule.Spec.Metric.Name, podWorkloads, metrics, &rule.Spec.Algorithm,
+		minSampleCount)
+	if err := r.recordEvents(ctx, rule, podWorkloads, verdicts, now); err != nil {
+		logger.Error(err, "failed to record interference events")
+	}
+	baselines, pending := r.Store.updateBaselines(req.NamespacedName, rule.Spec.Metric.Name, podWorkloads, metrics,
+		window, now, minSampleCount)
+	omitted := 0
+	if len(baselines) > interferencev1alpha1.MaxBaselines {
+		omitted = len(baselines) - interferencev1alpha1.MaxBaselines
+		logger.V(4).Info("baselines omitted in status", "count", omitted)
+	}
+	newStatus.Baselines = keepUnchangedBaselines(rule.Status.Baselines, baselines[:len(baselines)-omitted])
+	switch {
+	case len(baselines) == 0 && pending == 0:
+		meta.SetStatusCondition(&newStatus.Conditions, metav1.Condition{
+			Type:    interferencev1alpha1.RuleConditionBaselineReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  "NoSamples",
+			Message: "no samples of selected workloads in the evaluation window",
+		})
+	case pending > 0:
+		meta.SetStatusCondition(&newStatus.Conditions, metav1.Condition{
+			Type:   interferencev1alpha1.RuleConditionBaselineReady,
+			Status: metav1.ConditionFalse,
+			Reason: "InsufficientSamples",
+			Message: fmt.Sprintf("%d of %d workloads have less than %d samples",
+				pending, pending+len(baselines), minSampleCount),
+		})
+	default:
+		cond := metav1.Condition{
+			Type:   interferencev1alpha1.RuleConditionBaselineReady,
+			Status: metav1.ConditionTrue,
+			Reason: "BaselineCalculated",
+		}
+		if omitted > 0 {
+			cond.Message = fmt.Sprintf("%d of %d baselines are omitted in status, which exceed the limit %d",
+				omitted, len(baselines), interferencev1alpha1.MaxBaselines)
+		}
+		meta.SetStatusCondition(&newStatus.Conditions, cond)
+	}
+
+	return ctrl.Result{RequeueAfter: interval}, r.updateStatus(ctx, rule, newStatus)
+}
+
+// queryMetric gets the latest samples of the metric of pods in @namespace from the metric provider.
+func (r *InterferenceDetectionRuleReconciler) queryMetric(metric interferencev1alpha1.MetricName, namespace string) ([]*common.Metric, error) {
+	if r.MetricProvider == nil {
+		return nil, fmt.Errorf("metric provider is not configured")
+	}
+	filterLabels := map[string]string{common.PodNamespace: namespace}
+	switch metric {
+	case interferencev1alpha1.MetricContainerCPI:
+		return r.MetricProvider.GetCPI(common.MetricQueryOptions{MetricName: common.KoordletContainerCPI, FilterLabels: filterLabels},
+			common.MakeContainerCPILabels)
+	case interferencev1alpha1.MetricPodCPI:
+		return r.MetricProvider.GetCPI(common.MetricQueryOptions{MetricName: common.KoordletPodCPI, FilterLabels: filterLabels},
+			common.MakePodCPILabels)
+	case interferencev1alpha1.MetricPodCPUUsage, interferencev1alpha1.MetricPodMemoryUsage:
+		return r.MetricProvider.Query(podMetricQuery(metric, namespace), common.MakePodLabels)
+	}
+	return r.MetricProvider.Query(containerMetricQuery(metric, namespace), common.MakeContainerLabels)
+}
+
+// queryMetricRange gets the history samples of the metric of pods in @namespace within @timeRange from the metric
+// provider.
+func (r *InterferenceDetectionRuleReconciler) queryMetricRange(metric interferencev1alpha1.MetricName, namespace string,
+	timeRange common.TimeRange) ([]*common.MetricSeries, error) {
+	if r.MetricProvider == nil {
+		return nil, fmt.Errorf("metric provider is not configured")
+	}
+	filterLabels := map[string]string{common.PodNamespace: namespace}
+	switch metric {
+	case interferencev1alpha1.MetricContainerCPI:
+		return r.MetricProvider.GetCPIRange(common.MetricQueryOptions{MetricName: common.KoordletContainerCPI,
+			FilterLabels: filterLabels, Range: &timeRange}, common.MakeContainerCPILabels)
+	case interferencev1alpha1.MetricPodCPI:
+		return r.MetricProvider.GetCPIRange(common.MetricQueryOptions{MetricName: common.KoordletPodCPI,
+			FilterLabels: filterLabels, Range: &timeRange}, common.MakePodCPILabels)
+	case interferencev1alpha1.MetricPodCPUUsage, interferencev1alpha1.MetricPodMemoryUsage:
+		return r.MetricProvider.QueryRange(podMetricQuery(metric, namespace), timeRange, common.MakePodLabels)
+	}
+	return r.MetricProvider.QueryRange(containerMetricQuery(metric, namespace), timeRange, common.MakeContainerLabels)
+}
+
+// containerMetricQuery makes the query of metrics other than CPI of pods in @namespace, which are expected to be
+// labeled with containers like CPI, and averaged among instances. Series without container labels, e.g. the ones
+// describing pods in the custom metrics API, are taken as the samples of pods.
+func containerMetricQuery(metric interferencev1alpha1.MetricName, namespace string) common.MetricQuery {
+	return common.MetricQuery{
+		MetricSelector: common.MetricSelector{
+			MetricName:   string(metric),
+			FilterLabels: map[string]string{common.PodNamespace: namespace},
+		},
+		GroupByLabels: []string{
+			common.ContainerID,
+			common.ContainerName,
+			common.PodUID,
+			common.PodNamespace,
+			common.PodName,
+			common.Node,
+		},
+		Aggregation: common.AggregationAvg,
+	}
+}
+
+// podMetricQuery makes the query of metrics labeled with pods only, e.g. the usages in NodeMetrics, whose samples
+// are evaluated per pod.
+func podMetricQuery(metric interferencev1alpha1.MetricName, namespace string) common.MetricQuery {
+	return common.MetricQuery{
+		MetricSelector: common.MetricSelector{
+			MetricName:   string(metric),
+			FilterLabels: map[string]string{common.PodNamespace: namespace},
+		},
+		GroupByLabels: []string{
+			common.PodUID,
+			common.PodNamespace,
+			common.PodName,
+			common.Node,
+		},
+		Aggregation: common.AggregationAvg,
+	}
+}
+
+// backfill adds the history samples of selected workloads missing in the store, either since the rule has no
+// samples yet, or the manager has been down for a while. Samples older than the evaluation window are not needed.
+func (r *InterferenceDetectionRuleReconciler) backfill(ctx context.Context, rule *interferencev1alpha1.InterferenceDetectionRule,
+	podWorkloads map[string]interferencev1alpha1.WorkloadReference, window, interval time.Duration, now time.Time) {
+	logger := log.FromContext(ctx)
+	ruleName := types.NamespacedName{Namespace: rule.Namespace, Name: rule.Name}
+
+	start := now.Add(-window)
+	if last := r.Store.lastSampleTime(ruleName, rule.Spec.Metric.Name); last.After(start) {
+		start = last.Add(interval)
+	}
+	// the latest sample is collected by the instant query
+	end := now.Add(-interval)
+	if end.Sub(start) < backfillMinGap*interval {
+		return
+	}
+	series, err := r.queryMetricRange(rule.Spec.Metric.Name, rule.Namespace, common.TimeRange{Start: start, End: end, Step: interval})
+	if err != nil {
+		// the baselines are still built up by instant queries
+		logger.Error(err, "failed to backfill samples", "metric", rule.Spec.Metric.Name, "start", start, "end", end)
+		return
+	}
+	added := r.Store.addSeries(ruleName, rule.Spec.Metric.Name, podWorkloads, series, window)
+	logger.V(4).Info("backfilled samples", "metric", rule.Spec.Metric.Name, "start", start, "end", end, "count", added)
+}
+
+func (r *InterferenceDetectionRuleReconciler) updateStatus(ctx context.Context,
+	rule *interferencev1alpha1.InterferenceDetectionRule, newStatus *interferencev1alpha1.InterferenceDetectionRuleStatus) error {
+	if reflect.DeepEqual(&rule.Status, newStatus) {
+		return nil
+	}
+	rule.Status = *newStatus
+	return r.Status().Update(ctx, rule)
+}
+
+// validateRule checks the arguments of the rule which can not be validated by the CRD schema.
+func validateRule(rule *interferencev1alpha1.InterferenceDetectionRule) error {
+	return rule.Spec.Algorithm.Validate()
+}
+
+// evaluationArgs returns the evaluation window, interval and minimum sample count of the rule, with defaults for
+// the unset ones. The interval defaults to @pollInterval if it is set.
+func evaluationArgs(rule *interferencev1alpha1.InterferenceDetectionRule, pollInterval time.Duration) (time.Duration, time.Duration, int64) {
+	window, interval, minSampleCount := defaultEvaluationWindow, defaultEvaluationInterval, int64(defaultMinSampleCount)
+	if pollInterval > 0 {
+		interval = pollInterval
+	}
+	if rule.Spec.EvaluationWindow != nil && rule.Spec.EvaluationWindow.Duration > 0 {
+		window = rule.Spec.EvaluationWindow.Duration
+	}
+	if rule.Spec.EvaluationInterval != nil && rule.Spec.EvaluationInterval.Duration > 0 {
+		interval = rule.Spec.EvaluationInterval.Duration
+	}
+	if rule.Spec.MinSampleCount != nil && *rule.Spec.MinSampleCount > 0 {
+		minSampleCount = *rule.Spec.MinSampleCount
+	}
+	return window, interval, minSampleCount
+}
 
-	return ctrl.Result{}, nil
+func baselineLess(a, b *interferencev1alpha1.WorkloadBaseline) bool {
+	if a.Owner.Namespace != b.Owner.Namespace {
+		return a.Owner.Namespace < b.Owner.Namespace
+	}
+	if a.Owner.Kind != b.Owner.Kind {
+		return a.Owner.Kind < b.Owner.Kind
+	}
+	if a.Owner.Name != b.Owner.Name {
+		return a.Owner.Name < b.Owner.Name
+	}
+	return a.ContainerName < b.ContainerName
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// SetupWithManager sets up the controller with the Manager. Rules are reconciled on spec changes only, since they
+// are requeued on their evaluation intervals, and updates of status must not trigger evaluations.
 func (r *InterferenceDetectionRuleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := indexField(mgr, &corev1.Pod{}, podNodeNameField, indexPodNodeName); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&interferencev1alpha1.InterferenceDetectionRule{}).
+		For(&interferencev1alpha1.InterferenceDetectionRule{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
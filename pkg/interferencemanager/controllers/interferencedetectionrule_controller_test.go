@@ -0,0 +1,433 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	slov1alpha1 "github.com/koordinator-sh/koordinator/apis/slo/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/aggregation"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+)
+
+type fakeMetricProvider struct {
+	metrics []*common.Metric
+	series  []*common.MetricSeries
+	err     error
+
+	timeRanges   []common.TimeRange
+	filterLabels []map[string]string
+}
+
+func (f *fakeMetricProvider) GetCPI(options common.MetricQueryOptions, labelFunc common.MakeLabelsFunc) ([]*common.Metric, error) {
+	f.filterLabels = append(f.filterLabels, options.FilterLabels)
+	return f.metrics, f.err
+}
+
+func (f *fakeMetricProvider) Query(query common.MetricQuery, labelFunc common.MakeLabelsFunc) ([]*common.Metric, error) {
+	f.filterLabels = append(f.filterLabels, query.FilterLabels)
+	return f.metrics, f.err
+}
+
+func (f *fakeMetricProvider) GetCPIRange(options common.MetricQueryOptions, labelFunc common.MakeLabelsFunc) ([]*common.MetricSeries, error) {
+	f.timeRanges = append(f.timeRanges, *options.Range)
+	f.filterLabels = append(f.filterLabels, options.FilterLabels)
+	return f.series, f.err
+}
+
+func (f *fakeMetricProvider) QueryRange(query common.MetricQuery, timeRange common.TimeRange, labelFunc common.MakeLabelsFunc) ([]*common.MetricSeries, error) {
+	f.timeRanges = append(f.timeRanges, timeRange)
+	f.filterLabels = append(f.filterLabels, query.FilterLabels)
+	return f.series, f.err
+}
+
+func newTestScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = interferencev1alpha1.AddToScheme(scheme)
+	_ = slov1alpha1.AddToScheme(scheme)
+	return scheme
+}
+
+func newTestPod(name, uid, ownerKind, ownerName string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      name,
+			UID:       types.UID(uid),
+			Labels:    map[string]string{"app": ownerName},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "apps/v1",
+					Kind:       ownerKind,
+					Name:       ownerName,
+					Controller: pointer.Bool(true),
+				},
+			},
+		},
+	}
+}
+
+func newTestRule() *interferencev1alpha1.InterferenceDetectionRule {
+	return &interferencev1alpha1.InterferenceDetectionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "test-rule",
+		},
+		Spec: interferencev1alpha1.InterferenceDetectionRuleSpec{
+			Metric: interferencev1alpha1.MetricSource{Name: interferencev1alpha1.MetricContainerCPI},
+			WorkloadSelector: &interferencev1alpha1.WorkloadSelector{
+				OwnerKinds: []string{"ReplicaSet"},
+			},
+			Algorithm: interferencev1alpha1.DetectionAlgorithm{
+				Type: interferencev1alpha1.MeanStdDevAlgorithm,
+			},
+			MinSampleCount: pointer.Int64(3),
+		},
+	}
+}
+
+func TestInterferenceDetectionRuleReconcile(t *testing.T) {
+	rule := newTestRule()
+	client := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(
+		rule,
+		newTestPod("web-1", "uid-1", "ReplicaSet", "web"),
+		newTestPod("web-2", "uid-2", "ReplicaSet", "web"),
+		newTestPod("job-1", "uid-3", "Job", "job"),
+	).Build()
+	provider := &fakeMetricProvider{
+		metrics: []*common.Metric{
+			{Labels: map[string]string{common.PodUID: "uid-1", common.ContainerName: "main"}, Value: 1},
+			{Labels: map[string]string{common.PodUID: "uid-2", common.ContainerName: "main"}, Value: 3},
+			{Labels: map[string]string{common.PodUID: "uid-3", common.ContainerName: "main"}, Value: 10},
+		},
+	}
+	store := NewSampleStore()
+	store.markRestored()
+	r := &InterferenceDetectionRuleReconciler{
+		Client:         client,
+		Scheme:         client.Scheme(),
+		MetricProvider: provider,
+		Store:          store,
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: rule.Namespace, Name: rule.Name}}
+
+	result, err := r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultEvaluationInterval, result.RequeueAfter)
+	got := &interferencev1alpha1.InterferenceDetectionRule{}
+	assert.NoError(t, client.Get(context.TODO(), req.NamespacedName, got))
+	assert.True(t, meta.IsStatusConditionTrue(got.Status.Conditions, interferencev1alpha1.RuleConditionMetricAvailable))
+	assert.True(t, meta.IsStatusConditionFalse(got.Status.Conditions, interferencev1alpha1.RuleConditionBaselineReady))
+	assert.Empty(t, got.Status.Baselines)
+	// metrics are queried in the namespace of the rule
+	assert.NotEmpty(t, provider.filterLabels)
+	for _, filterLabels := range provider.filterLabels {
+		assert.Equal(t, map[string]string{common.PodNamespace: "default"}, filterLabels)
+	}
+
+	// samples of the two pods are aggregated into the workload, and the job is not selected
+	_, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	assert.NoError(t, client.Get(context.TODO(), req.NamespacedName, got))
+	assert.True(t, meta.IsStatusConditionTrue(got.Status.Conditions, interferencev1alpha1.RuleConditionBaselineReady))
+	assert.Len(t, got.Status.Baselines, 1)
+	baseline := got.Status.Baselines[0]
+	assert.Equal(t, "web", baseline.Owner.Name)
+	assert.Equal(t, "main", baseline.ContainerName)
+	assert.Equal(t, int64(4), baseline.SampleCount)
+	assert.InEpsilon(t, 2, baseline.Mean.AsApproximateFloat64(), 0.05)
+	assert.InEpsilon(t, 1, baseline.StdDev.AsApproximateFloat64(), 0.05)
+
+	// provider errors are reported in conditions
+	provider.err = fmt.Errorf("prometheus is unreachable")
+	_, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	assert.NoError(t, client.Get(context.TODO(), req.NamespacedName, got))
+	cond := meta.FindStatusCondition(got.Status.Conditions, interferencev1alpha1.RuleConditionMetricAvailable)
+	assert.NotNil(t, cond)
+	assert.Equal(t, metav1.ConditionFalse, cond.Status)
+	assert.Contains(t, cond.Message, "prometheus is unreachable")
+}
+
+func TestInterferenceDetectionRuleInvalidSpec(t *testing.T) {
+	rule := newTestRule()
+	rule.Spec.Algorithm = interferencev1alpha1.DetectionAlgorithm{Type: interferencev1alpha1.StaticThresholdAlgorithm}
+	client := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(rule).Build()
+	provider := &fakeMetricProvider{}
+	store := NewSampleStore()
+	store.markRestored()
+	r := &InterferenceDetectionRuleReconciler{
+		Client:         client,
+		Scheme:         client.Scheme(),
+		MetricProvider: provider,
+		Store:          store,
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: rule.Namespace, Name: rule.Name}}
+
+	// rules missing the arguments of their algorithms are not evaluated until they are updated
+	result, err := r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Empty(t, provider.filterLabels)
+	got := &interferencev1alpha1.InterferenceDetectionRule{}
+	assert.NoError(t, client.Get(context.TODO(), req.NamespacedName, got))
+	cond := meta.FindStatusCondition(got.Status.Conditions, interferencev1alpha1.RuleConditionBaselineReady)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, "InvalidSpec", cond.Reason)
+		assert.Contains(t, cond.Message, "staticThreshold is required")
+	}
+}
+
+func TestInterferenceDetectionRulePodMetric(t *testing.T) {
+	rule := newTestRule()
+	rule.Spec.Metric.Name = interferencev1alpha1.MetricPodCPUUsage
+	client := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(
+		rule,
+		newTestPod("web-1", "uid-1", "ReplicaSet", "web"),
+		newTestPod("web-2", "uid-2", "ReplicaSet", "web"),
+	).Build()
+	// usages in NodeMetrics are labeled with pods only
+	provider := &fakeMetricProvider{
+		metrics: []*common.Metric{
+			{Labels: map[string]string{common.PodUID: "uid-1", common.PodNamespace: "default", common.PodName: "web-1"}, Value: 1},
+			{Labels: map[string]string{common.PodUID: "uid-2", common.PodNamespace: "default", common.PodName: "web-2"}, Value: 3},
+		},
+	}
+	store := NewSampleStore()
+	store.markRestored()
+	r := &InterferenceDetectionRuleReconciler{
+		Client:         client,
+		Scheme:         client.Scheme(),
+		MetricProvider: provider,
+		Store:          store,
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: rule.Namespace, Name: rule.Name}}
+	for i := 0; i < 2; i++ {
+		_, err := r.Reconcile(context.TODO(), req)
+		assert.NoError(t, err)
+	}
+
+	got := &interferencev1alpha1.InterferenceDetectionRule{}
+	assert.NoError(t, client.Get(context.TODO(), req.NamespacedName, got))
+	assert.True(t, meta.IsStatusConditionTrue(got.Status.Conditions, interferencev1alpha1.RuleConditionBaselineReady))
+	assert.Len(t, got.Status.Baselines, 1)
+	assert.Equal(t, "web", got.Status.Baselines[0].Owner.Name)
+	assert.Equal(t, "", got.Status.Baselines[0].ContainerName)
+	assert.Equal(t, int64(4), got.Status.Baselines[0].SampleCount)
+
+	query := podMetricQuery(rule.Spec.Metric.Name, rule.Namespace)
+	assert.Equal(t, []string{common.PodUID, common.PodNamespace, common.PodName, common.Node}, query.GroupByLabels)
+}
+
+func TestInterferenceDetectionRuleBackfill(t *testing.T) {
+	rule := newTestRule()
+	client := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(
+		rule,
+		newTestPod("web-1", "uid-1", "ReplicaSet", "web"),
+	).Build()
+	now := time.Now()
+	provider := &fakeMetricProvider{
+		metrics: []*common.Metric{
+			{Labels: map[string]string{common.PodUID: "uid-1", common.ContainerName: "main"}, Value: 2},
+		},
+		series: []*common.MetricSeries{
+			{
+				Labels: map[string]string{common.PodUID: "uid-1", common.ContainerName: "main"},
+				Samples: []common.Sample{
+					{Timestamp: now.Add(-3 * time.Hour), Value: 1},
+					{Timestamp: now.Add(-2 * time.Hour), Value: 2},
+					{Timestamp: now.Add(-time.Hour), Value: 3},
+				},
+			},
+			{
+				// pods not selected are ignored
+				Labels:  map[string]string{common.PodUID: "uid-unknown", common.ContainerName: "main"},
+				Samples: []common.Sample{{Timestamp: now.Add(-time.Hour), Value: 100}},
+			},
+		},
+	}
+	store := NewSampleStore()
+	store.markRestored()
+	r := &InterferenceDetectionRuleReconciler{
+		Client:         client,
+		Scheme:         client.Scheme(),
+		MetricProvider: provider,
+		Store:          store,
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: rule.Namespace, Name: rule.Name}}
+
+	// history samples make the baseline ready on the first reconcile
+	_, err := r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	got := &interferencev1alpha1.InterferenceDetectionRule{}
+	assert.NoError(t, client.Get(context.TODO(), req.NamespacedName, got))
+	assert.True(t, meta.IsStatusConditionTrue(got.Status.Conditions, interferencev1alpha1.RuleConditionBaselineReady))
+	assert.Len(t, got.Status.Baselines, 1)
+	assert.Equal(t, int64(4), got.Status.Baselines[0].SampleCount)
+	assert.Len(t, provider.timeRanges, 1)
+	assert.Equal(t, defaultEvaluationInterval, provider.timeRanges[0].Step)
+	assert.InDelta(t, float64(defaultEvaluationWindow), float64(provider.timeRanges[0].End.Sub(provider.timeRanges[0].Start)),
+		float64(2*defaultEvaluationInterval))
+
+	// no backfill while samples are collected continuously
+	_, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	assert.Len(t, provider.timeRanges, 1)
+}
+
+func TestBaselineOf(t *testing.T) {
+	now := time.Now()
+	key := aggregation.AggregationKey{ContainerName: "main", Metric: interferencev1alpha1.MetricContainerCPI}
+	a := aggregation.NewContainerAggregation(aggregation.NewDefaultHistogramOptions(), 24*time.Hour)
+	for i := 1; i <= 100; i++ {
+		a.AddSample(float64(i), now.Add(-time.Duration(100-i)*time.Minute))
+	}
+	b := baselineOf(key, a, now)
+	assert.Equal(t, "main", b.ContainerName)
+	assert.Equal(t, int64(100), b.SampleCount)
+	assert.InEpsilon(t, 50.5, b.Mean.AsApproximateFloat64(), 0.05)
+	assert.InEpsilon(t, 50, b.P50.AsApproximateFloat64(), 0.1)
+	assert.InEpsilon(t, 90, b.P90.AsApproximateFloat64(), 0.1)
+	assert.InEpsilon(t, 99, b.P99.AsApproximateFloat64(), 0.1)
+	assert.True(t, b.WindowStart.Time.Equal(now.Add(-99*time.Minute)))
+	assert.True(t, b.WindowEnd.Time.Equal(now))
+}
+
+func TestBaselineOfMemory(t *testing.T) {
+	now := time.Now()
+	key := aggregation.AggregationKey{ContainerName: "main", Metric: interferencev1alpha1.MetricPodMemoryUsage}
+	a := aggregation.NewContainerAggregation(aggregation.NewHistogramOptionsOf(key.Metric), 24*time.Hour)
+	for i := 1; i <= 100; i++ {
+		// 1 GiB to 100 GiB
+		a.AddSample(float64(i<<30), now.Add(-time.Duration(100-i)*time.Minute))
+	}
+	b := baselineOf(key, a, now)
+	assert.InEpsilon(t, float64(50<<30), b.P50.AsApproximateFloat64(), 0.1)
+	assert.InEpsilon(t, float64(99<<30), b.P99.AsApproximateFloat64(), 0.1)
+	assert.InEpsilon(t, 50.5*(1<<30), b.Mean.AsApproximateFloat64(), 0.05)
+}
+
+func TestNewQuantity(t *testing.T) {
+	for value, want := range map[float64]string{
+		1.5:  "1500m",
+		1e-9: "1n",
+		// too large for nano precision
+		100 << 30:  "107374182400",
+		-100 << 30: "-107374182400",
+	} {
+		q := newQuantity(value)
+		assert.Equal(t, want, q.String())
+	}
+	q := newQuantity(1e30)
+	assert.Equal(t, int64(math.MaxInt64-1023), q.Value())
+}
+
+func TestInterferenceDetectionRuleDedupSamples(t *testing.T) {
+	rule := newTestRule()
+	rule.Spec.MinSampleCount = pointer.Int64(2)
+	otherPod := newTestPod("web-1", "uid-other", "ReplicaSet", "web")
+	otherPod.Namespace = "other"
+	client := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(
+		rule,
+		newTestPod("web-1", "uid-1", "ReplicaSet", "web"),
+		otherPod,
+	).Build()
+	now := time.Now()
+	provider := &fakeMetricProvider{}
+	setSamples := func(timestamp time.Time, value float64) {
+		provider.metrics = []*common.Metric{
+			{Labels: map[string]string{common.PodUID: "uid-1", common.ContainerName: "main"}, Value: value, Timestamp: timestamp},
+			// pods in other namespaces are not selected
+			{Labels: map[string]string{common.PodUID: "uid-other", common.ContainerName: "main"}, Value: 100, Timestamp: timestamp},
+		}
+	}
+	store := NewSampleStore()
+	store.markRestored()
+	r := &InterferenceDetectionRuleReconciler{
+		Client:         client,
+		Scheme:         client.Scheme(),
+		MetricProvider: provider,
+		Store:          store,
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: rule.Namespace, Name: rule.Name}}
+	got := &interferencev1alpha1.InterferenceDetectionRule{}
+
+	setSamples(now.Add(-time.Minute), 1)
+	_, err := r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	setSamples(now, 3)
+	_, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	assert.NoError(t, client.Get(context.TODO(), req.NamespacedName, got))
+	assert.Len(t, got.Status.Baselines, 1)
+	assert.Equal(t, int64(2), got.Status.Baselines[0].SampleCount)
+	assert.Equal(t, "default", got.Status.Baselines[0].Owner.Namespace)
+	resourceVersion := got.ResourceVersion
+
+	// the same samples are not added again, and the status is not updated
+	_, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	assert.NoError(t, client.Get(context.TODO(), req.NamespacedName, got))
+	assert.Equal(t, int64(2), got.Status.Baselines[0].SampleCount)
+	assert.Equal(t, resourceVersion, got.ResourceVersion)
+}
+
+func TestKeepUnchangedBaselines(t *testing.T) {
+	now := time.Now()
+	key := aggregation.AggregationKey{ContainerName: "main", Metric: interferencev1alpha1.MetricContainerCPI}
+	a := aggregation.NewContainerAggregation(aggregation.NewDefaultHistogramOptions(), 24*time.Hour)
+	a.AddSample(1, now.Add(-time.Minute))
+	old := *baselineOf(key, a, now.Add(-time.Minute))
+
+	unchanged := keepUnchangedBaselines([]interferencev1alpha1.WorkloadBaseline{old},
+		[]interferencev1alpha1.WorkloadBaseline{*baselineOf(key, a, now)})
+	assert.Equal(t, old.LastUpdateTime, unchanged[0].LastUpdateTime)
+
+	a.AddSample(2, now)
+	changed := keepUnchangedBaselines([]interferencev1alpha1.WorkloadBaseline{old},
+		[]interferencev1alpha1.WorkloadBaseline{*baselineOf(key, a, now)})
+	assert.True(t, changed[0].LastUpdateTime.Time.Equal(now))
+}
+
+func TestEvaluationArgs(t *testing.T) {
+	rule := newTestRule()
+	_, interval, _ := evaluationArgs(rule, 0)
+	assert.Equal(t, defaultEvaluationInterval, interval)
+	// the poll interval applies to rules without an evaluation interval
+	_, interval, _ = evaluationArgs(rule, 30*time.Second)
+	assert.Equal(t, 30*time.Second, interval)
+	rule.Spec.EvaluationInterval = &metav1.Duration{Duration: 2 * time.Minute}
+	_, interval, _ = evaluationArgs(rule, 30*time.Second)
+	assert.Equal(t, 2*time.Minute, interval)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+)
+
+// InterferenceEventReconciler reconciles a InterferenceEvent object
+type InterferenceEventReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=interference.koordinator.sh,resources=interferenceevents,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=interference.koordinator.sh,resources=interferenceevents/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=interference.koordinator.sh,resources=interferenceevents/finalizers,verbs=update
+
+// Reconcile deletes the event after its ExpireTime, which is postponed by the InterferenceDetectionRuleReconciler
+// as long as the interference lasts. Events are owned by their rules, so they are also deleted by the kubernetes
+// garbage collector along with the rules.
+func (r *InterferenceEventReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	event := &interferencev1alpha1.InterferenceEvent{}
+	if err := r.Get(ctx, req.NamespacedName, event); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if remaining := time.Until(event.Spec.ExpireTime.Time); remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+	logger.V(4).Info("delete expired interference event", "lastDetectedTime", event.Spec.LastDetectedTime)
+	// the precondition keeps the event if it is updated by a detection meanwhile
+	return ctrl.Result{}, client.IgnoreNotFound(r.Delete(ctx, event, client.Preconditions{
+		ResourceVersion: &event.ResourceVersion,
+	}))
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *InterferenceEventReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&interferencev1alpha1.InterferenceEvent{}).
+		Complete(r)
+}
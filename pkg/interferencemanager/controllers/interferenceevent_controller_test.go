@@ -0,0 +1,64 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+)
+
+func TestInterferenceEventReconcile(t *testing.T) {
+	active := &interferencev1alpha1.InterferenceEvent{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "active"},
+		Spec: interferencev1alpha1.InterferenceEventSpec{
+			ExpireTime: metav1.NewTime(time.Now().Add(time.Hour)),
+		},
+	}
+	expired := &interferencev1alpha1.InterferenceEvent{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "expired"},
+		Spec: interferencev1alpha1.InterferenceEventSpec{
+			ExpireTime: metav1.NewTime(time.Now().Add(-time.Minute)),
+		},
+	}
+	client := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(active, expired).Build()
+	r := &InterferenceEventReconciler{Client: client, Scheme: client.Scheme()}
+
+	result, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "active"}})
+	assert.NoError(t, err)
+	assert.True(t, result.RequeueAfter > 59*time.Minute && result.RequeueAfter <= time.Hour)
+	assert.NoError(t, client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "active"}, active))
+
+	result, err = r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "expired"}})
+	assert.NoError(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	err = client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "expired"}, expired)
+	assert.True(t, errors.IsNotFound(err))
+
+	// events already deleted are ignored
+	_, err = r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "expired"}})
+	assert.NoError(t, err)
+}
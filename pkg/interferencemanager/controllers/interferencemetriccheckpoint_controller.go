@@ -18,44 +18,208 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
 
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
 )
 
-// InterferenceMetricCheckpointReconciler reconciles a InterferenceMetricCheckpoint object
+const (
+	defaultCheckpointInterval = 10 * time.Minute
+	// checkpointGCInterval is the interval to check whether the workload of a checkpoint still exists.
+	checkpointGCInterval = 10 * time.Minute
+	// checkpointNameSuffixLen is the length of the hash suffix of checkpoint names, e.g. "-0123abcd".
+	checkpointNameSuffixLen = 9
+	// flushTimeout is the timeout to flush checkpoints when the manager is stopping.
+	flushTimeout = 30 * time.Second
+)
+
+var checkpointLog = ctrl.Log.WithName("checkpoint")
+
+// InterferenceMetricCheckpointReconciler reconciles a InterferenceMetricCheckpoint object.
+// It also runs as a leader election runnable of the manager, which restores the SampleStore from checkpoints on
+// startup and periodically writes the SampleStore back into checkpoints.
 type InterferenceMetricCheckpointReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	Store  *SampleStore
+	// CheckpointInterval is the interval to write checkpoints, default to 10 minutes.
+	CheckpointInterval time.Duration
 }
 
 //+kubebuilder:rbac:groups=interference.koordinator.sh,resources=interferencemetriccheckpoints,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=interference.koordinator.sh,resources=interferencemetriccheckpoints/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=interference.koordinator.sh,resources=interferencemetriccheckpoints/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apps,resources=deployments;replicasets;statefulsets;daemonsets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch,resources=jobs;cronjobs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apps.kruise.io,resources=clonesets;statefulsets,verbs=get;list;watch
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the InterferenceMetricCheckpoint object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
-//
-// For more details, check Reconcile and its Result here:
-// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.1/pkg/reconcile
+// Reconcile garbage-collects the checkpoint whose rule or workload no longer exists. Checkpoints are owned by
+// their rules, so they are also deleted by the kubernetes garbage collector along with the rules.
 func (r *InterferenceMetricCheckpointReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+	logger := log.FromContext(ctx)
+
+	checkpoint := &interferencev1alpha1.InterferenceMetricCheckpoint{}
+	if err := r.Get(ctx, req.NamespacedName, checkpoint); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	rule := &interferencev1alpha1.InterferenceDetectionRule{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: checkpoint.Namespace, Name: checkpoint.Spec.RuleName}, rule)
+	if errors.IsNotFound(err) {
+		logger.V(4).Info("delete checkpoint since its rule no longer exists", "rule", checkpoint.Spec.RuleName)
+		return ctrl.Result{}, client.IgnoreNotFound(r.Delete(ctx, checkpoint))
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	exist, err := r.workloadExists(ctx, &checkpoint.Spec.Owner)
+	if meta.IsNoMatchError(err) {
+		// the CRD of the workload kind is not installed, e.g. Kruise CloneSet, keep the checkpoint in case it is
+		// installed later instead of retrying on errors
+		logger.V(4).Info("skip checkpoint since its workload kind is not served", "workload", checkpoint.Spec.Owner)
+		return ctrl.Result{RequeueAfter: checkpointGCInterval}, nil
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !exist {
+		logger.V(4).Info("delete checkpoint since its workload no longer exists", "workload", checkpoint.Spec.Owner)
+		return ctrl.Result{}, client.IgnoreNotFound(r.Delete(ctx, checkpoint))
+	}
+	return ctrl.Result{RequeueAfter: checkpointGCInterval}, nil
+}
+
+func (r *InterferenceMetricCheckpointReconciler) workloadExists(ctx context.Context, owner *interferencev1alpha1.WorkloadReference) (bool, error) {
+	gv, err := schema.ParseGroupVersion(owner.APIVersion)
+	if err != nil {
+		// the workload can never be found with an invalid api version
+		return false, nil
+	}
+	obj := &metav1.PartialObjectMetadata{}
+	obj.SetGroupVersionKind(gv.WithKind(owner.Kind))
+	err = r.Get(ctx, types.NamespacedName{Namespace: owner.Namespace, Name: owner.Name}, obj)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
 
-	// TODO(user): your logic here
+// Start restores the SampleStore from checkpoints, then writes checkpoints every CheckpointInterval until the
+// context is done. Checkpoints are written once more before it returns, so that a new leader can restore the
+// latest state.
+func (r *InterferenceMetricCheckpointReconciler) Start(ctx context.Context) error {
+	if err := r.restore(ctx); err != nil {
+		return fmt.Errorf("failed to restore from checkpoints: %v", err)
+	}
+	r.Store.markRestored()
+
+	interval := r.CheckpointInterval
+	if interval <= 0 {
+		interval = defaultCheckpointInterval
+	}
+	wait.Until(func() {
+		r.flush(ctx)
+	}, interval, ctx.Done())
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	r.flush(flushCtx)
+	return nil
+}
+
+func (r *InterferenceMetricCheckpointReconciler) restore(ctx context.Context) error {
+	checkpointList := &interferencev1alpha1.InterferenceMetricCheckpointList{}
+	if err := r.List(ctx, checkpointList); err != nil {
+		return err
+	}
+	restored := 0
+	for i := range checkpointList.Items {
+		checkpoint := &checkpointList.Items[i]
+		if checkpoint.Spec.Version != interferencev1alpha1.CheckpointVersion {
+			checkpointLog.Info("skip checkpoint of unknown version", "checkpoint", client.ObjectKeyFromObject(checkpoint),
+				"version", checkpoint.Spec.Version)
+			continue
+		}
+		ruleName := types.NamespacedName{Namespace: checkpoint.Namespace, Name: checkpoint.Spec.RuleName}
+		if err := r.Store.loadCheckpoint(ruleName, &checkpoint.Spec); err != nil {
+			checkpointLog.Error(err, "skip invalid checkpoint", "checkpoint", client.ObjectKeyFromObject(checkpoint))
+			continue
+		}
+		restored++
+	}
+	checkpointLog.Info("restored samples from checkpoints", "count", restored)
+	return nil
+}
+
+// flush writes the checkpoints of all rules in the SampleStore.
+func (r *InterferenceMetricCheckpointReconciler) flush(ctx context.Context) {
+	now := time.Now()
+	for _, ruleName := range r.Store.ruleNames() {
+		rule := &interferencev1alpha1.InterferenceDetectionRule{}
+		if err := r.Get(ctx, ruleName, rule); err != nil {
+			if errors.IsNotFound(err) {
+				r.Store.forgetRule(ruleName)
+			} else {
+				checkpointLog.Error(err, "failed to get rule", "rule", ruleName)
+			}
+			continue
+		}
+		for _, spec := range r.Store.saveCheckpoints(ruleName, now) {
+			if err := r.writeCheckpoint(ctx, rule, spec); err != nil {
+				checkpointLog.Error(err, "failed to write checkpoint", "rule", ruleName, "workload", spec.Owner)
+			}
+		}
+	}
+}
+
+func (r *InterferenceMetricCheckpointReconciler) writeCheckpoint(ctx context.Context,
+	rule *interferencev1alpha1.InterferenceDetectionRule, spec *interferencev1alpha1.InterferenceMetricCheckpointSpec) error {
+	checkpoint := &interferencev1alpha1.InterferenceMetricCheckpoint{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: rule.Namespace,
+			Name:      checkpointName(rule.Name, &spec.Owner, spec.Metric),
+		},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, checkpoint, func() error {
+		checkpoint.Spec = *spec
+		return controllerutil.SetOwnerReference(rule, checkpoint, r.Scheme)
+	})
+	return err
+}
 
-	return ctrl.Result{}, nil
+// checkpointName generates a stable name for the checkpoint of a workload on a metric in the rule. Rule names too
+// long to be prefixed are truncated, and hashed along with the workload to tell rules with the same prefix apart.
+func checkpointName(ruleName string, owner *interferencev1alpha1.WorkloadReference, metric interferencev1alpha1.MetricName) string {
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte(fmt.Sprintf("%s/%s/%s/%s/%s", owner.APIVersion, owner.Kind, owner.Namespace, owner.Name, metric)))
+	if maxPrefixLen := validation.DNS1123SubdomainMaxLength - checkpointNameSuffixLen; len(ruleName) > maxPrefixLen {
+		_, _ = hasher.Write([]byte("/" + ruleName))
+		// the prefix must end with an alphanumeric character to keep the name a valid DNS subdomain
+		ruleName = strings.TrimRight(ruleName[:maxPrefixLen], "-.")
+	}
+	return fmt.Sprintf("%s-%08x", ruleName, hasher.Sum32())
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *InterferenceMetricCheckpointReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.Add(r); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&interferencev1alpha1.InterferenceMetricCheckpoint{}).
 		Complete(r)
@@ -0,0 +1,192 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+)
+
+func TestSampleStoreCheckpoint(t *testing.T) {
+	ruleName := types.NamespacedName{Namespace: "default", Name: "test-rule"}
+	owner := interferencev1alpha1.WorkloadReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Namespace: "default", Name: "web"}
+	podWorkloads := map[string]interferencev1alpha1.WorkloadReference{"uid-1": owner}
+	now := time.Now()
+
+	store := NewSampleStore()
+	for i := 0; i < 100; i++ {
+		metrics := []*common.Metric{
+			{Labels: map[string]string{common.PodUID: "uid-1", common.ContainerName: "main"}, Value: float64(i%10) + 1},
+		}
+		store.updateBaselines(ruleName, interferencev1alpha1.MetricContainerCPI, podWorkloads, metrics,
+			time.Hour, now.Add(time.Duration(i-100)*time.Second), 1)
+	}
+	expected, _ := store.updateBaselines(ruleName, interferencev1alpha1.MetricContainerCPI, podWorkloads, nil,
+		time.Hour, now, 1)
+
+	checkpoints := store.saveCheckpoints(ruleName, now)
+	assert.Len(t, checkpoints, 1)
+	assert.Equal(t, interferencev1alpha1.CheckpointVersion, checkpoints[0].Version)
+	assert.Equal(t, owner, checkpoints[0].Owner)
+	assert.Len(t, checkpoints[0].Histograms, 1)
+	assert.Equal(t, int64(100), checkpoints[0].Histograms[0].TotalSamplesCount)
+	assert.Len(t, checkpoints[0].Histograms[0].Histogram.BucketWeights, 10)
+
+	restoredStore := NewSampleStore()
+	assert.NoError(t, restoredStore.loadCheckpoint(ruleName, checkpoints[0]))
+	got, pending := restoredStore.updateBaselines(ruleName, interferencev1alpha1.MetricContainerCPI, podWorkloads, nil,
+		time.Hour, now, 1)
+	assert.Equal(t, 0, pending)
+	assert.Len(t, got, 1)
+	assert.Equal(t, expected[0].SampleCount, got[0].SampleCount)
+	assert.InDelta(t, expected[0].Mean.AsApproximateFloat64(), got[0].Mean.AsApproximateFloat64(), 0.1)
+	assert.InDelta(t, expected[0].P90.AsApproximateFloat64(), got[0].P90.AsApproximateFloat64(), 0.01)
+}
+
+// noMatchClient fails to get objects of kinds not served like the real client does, which the fake client does not.
+type noMatchClient struct {
+	client.Client
+	notServed schema.GroupKind
+}
+
+func (c *noMatchClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if gvk := obj.GetObjectKind().GroupVersionKind(); gvk.GroupKind() == c.notServed {
+		return &meta.NoKindMatchError{GroupKind: gvk.GroupKind(), SearchedVersions: []string{gvk.Version}}
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestInterferenceMetricCheckpointReconcile(t *testing.T) {
+	rule := newTestRule()
+	replicaSet := &appsv1.ReplicaSet{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "ReplicaSet"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+	}
+	newCheckpoint := func(name, ruleName, ownerName string) *interferencev1alpha1.InterferenceMetricCheckpoint {
+		return &interferencev1alpha1.InterferenceMetricCheckpoint{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: interferencev1alpha1.InterferenceMetricCheckpointSpec{
+				Version:  interferencev1alpha1.CheckpointVersion,
+				RuleName: ruleName,
+				Owner: interferencev1alpha1.WorkloadReference{
+					APIVersion: "apps/v1",
+					Kind:       "ReplicaSet",
+					Namespace:  "default",
+					Name:       ownerName,
+				},
+				Metric: interferencev1alpha1.MetricContainerCPI,
+			},
+		}
+	}
+	kindNotInstalled := newCheckpoint("kind-not-installed", rule.Name, "web")
+	kindNotInstalled.Spec.Owner.APIVersion, kindNotInstalled.Spec.Owner.Kind = "apps.kruise.io/v1alpha1", "CloneSet"
+	fakeClient := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(
+		rule,
+		replicaSet,
+		kindNotInstalled,
+		newCheckpoint("alive", rule.Name, "web"),
+		newCheckpoint("rule-deleted", "deleted-rule", "web"),
+		newCheckpoint("workload-deleted", rule.Name, "deleted-workload"),
+	).Build()
+	c := &noMatchClient{Client: fakeClient, notServed: schema.GroupKind{Group: "apps.kruise.io", Kind: "CloneSet"}}
+	r := &InterferenceMetricCheckpointReconciler{
+		Client: c,
+		Scheme: c.Scheme(),
+		Store:  NewSampleStore(),
+	}
+
+	tests := []struct {
+		name        string
+		wantDeleted bool
+	}{
+		{name: "alive", wantDeleted: false},
+		{name: "rule-deleted", wantDeleted: true},
+		{name: "workload-deleted", wantDeleted: true},
+		{name: "kind-not-installed", wantDeleted: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := types.NamespacedName{Namespace: "default", Name: tt.name}
+			result, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key})
+			assert.NoError(t, err)
+			if !tt.wantDeleted {
+				assert.Equal(t, checkpointGCInterval, result.RequeueAfter)
+			}
+			err = c.Get(context.TODO(), key, &interferencev1alpha1.InterferenceMetricCheckpoint{})
+			assert.Equal(t, tt.wantDeleted, errors.IsNotFound(err))
+		})
+	}
+}
+
+func TestSampleStoreCheckpointLimit(t *testing.T) {
+	ruleName := types.NamespacedName{Namespace: "default", Name: "test-rule"}
+	owner := interferencev1alpha1.WorkloadReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Namespace: "default", Name: "web"}
+	podWorkloads := map[string]interferencev1alpha1.WorkloadReference{"uid-1": owner}
+	now := time.Now()
+
+	store := NewSampleStore()
+	var metrics []*common.Metric
+	for i := 0; i < interferencev1alpha1.MaxCheckpointContainers+8; i++ {
+		metrics = append(metrics, &common.Metric{
+			Labels: map[string]string{common.PodUID: "uid-1", common.ContainerName: fmt.Sprintf("c-%02d", i)},
+			Value:  1,
+		})
+	}
+	store.updateBaselines(ruleName, interferencev1alpha1.MetricContainerCPI, podWorkloads, metrics, time.Hour, now, 1)
+
+	// the same containers are kept every time
+	for i := 0; i < 3; i++ {
+		checkpoints := store.saveCheckpoints(ruleName, now)
+		assert.Len(t, checkpoints, 1)
+		histograms := checkpoints[0].Histograms
+		assert.Len(t, histograms, interferencev1alpha1.MaxCheckpointContainers)
+		assert.Equal(t, "c-00", histograms[0].ContainerName)
+		assert.Equal(t, fmt.Sprintf("c-%02d", interferencev1alpha1.MaxCheckpointContainers-1), histograms[len(histograms)-1].ContainerName)
+	}
+}
+
+func TestCheckpointName(t *testing.T) {
+	owner := &interferencev1alpha1.WorkloadReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Namespace: "default", Name: "web"}
+	name := checkpointName("test-rule", owner, interferencev1alpha1.MetricContainerCPI)
+	assert.Regexp(t, "^test-rule-[0-9a-f]{8}$", name)
+	assert.Equal(t, name, checkpointName("test-rule", owner, interferencev1alpha1.MetricContainerCPI))
+
+	longPrefix := strings.Repeat("a", validation.DNS1123SubdomainMaxLength-checkpointNameSuffixLen-1) + "."
+	long1 := checkpointName(longPrefix+"rule-1", owner, interferencev1alpha1.MetricContainerCPI)
+	long2 := checkpointName(longPrefix+"rule-2", owner, interferencev1alpha1.MetricContainerCPI)
+	assert.Empty(t, validation.IsDNS1123Subdomain(long1))
+	assert.Empty(t, validation.IsDNS1123Subdomain(long2))
+	assert.NotEqual(t, long1, long2)
+}
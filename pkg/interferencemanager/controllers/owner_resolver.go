@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+)
+
+const (
+	// maxOwnerDepth bounds the owner chain to walk, in case of cyclic owner references.
+	maxOwnerDepth = 5
+)
+
+// intermediateOwners are the kinds of controllers which may be managed by other workloads, e.g. a ReplicaSet of
+// a Deployment and a Job of a CronJob. Other controllers like StatefulSet and OpenKruise CloneSet own pods
+// directly, and are workloads themselves.
+var intermediateOwners = map[schema.GroupKind]struct{}{
+	{Group: "apps", Kind: "ReplicaSet"}:       {},
+	{Group: "extensions", Kind: "ReplicaSet"}: {},
+	{Group: "batch", Kind: "Job"}:             {},
+}
+
+// OwnerResolver resolves the workload of pods by walking up their controller references, e.g.
+// pod -> ReplicaSet -> Deployment, pod -> Job -> CronJob. Owners are read with metadata only, so that it works on
+// any kind including CRDs and is served by the cache of the controller-runtime client.
+type OwnerResolver struct {
+	client client.Reader
+}
+
+func NewOwnerResolver(c client.Reader) *OwnerResolver {
+	return &OwnerResolver{client: c}
+}
+
+// Resolve returns the top-level controller of the pod. It returns false if the pod has no controller. An
+// intermediate owner which is not found, e.g. deleted or not synced yet, is regarded as the workload.
+func (r *OwnerResolver) Resolve(ctx context.Context, pod *corev1.Pod) (interferencev1alpha1.WorkloadReference, bool, error) {
+	ownerRef := metav1.GetControllerOf(pod)
+	if ownerRef == nil {
+		return interferencev1alpha1.WorkloadReference{}, false, nil
+	}
+	for depth := 0; depth < maxOwnerDepth; depth++ {
+		next, err := r.controllerOf(ctx, pod.Namespace, ownerRef)
+		if err != nil {
+			return interferencev1alpha1.WorkloadReference{}, false, err
+		}
+		if next == nil {
+			break
+		}
+		ownerRef = next
+	}
+	return interferencev1alpha1.WorkloadReference{
+		APIVersion: ownerRef.APIVersion,
+		Kind:       ownerRef.Kind,
+		Namespace:  pod.Namespace,
+		Name:       ownerRef.Name,
+	}, true, nil
+}
+
+// PodOwners returns the function resolving the workloads of pods by names, which queries CPI at
+// common.WorkloadLevel. Pods not found are regarded as pods without workloads.
+func (r *OwnerResolver) PodOwners(ctx context.Context) common.PodOwnerFunc {
+	return func(namespace, name string) (string, string, bool, error) {
+		pod := &corev1.Pod{}
+		if err := r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, pod); err != nil {
+			if errors.IsNotFound(err) {
+				return "", "", false, nil
+			}
+			return "", "", false, err
+		}
+		owner, ok, err := r.Resolve(ctx, pod)
+		if err != nil || !ok {
+			return "", "", false, err
+		}
+		return owner.Kind, owner.Name, true, nil
+	}
+}
+
+// controllerOf returns the controller of the owner, or nil if the owner is a top-level workload.
+func (r *OwnerResolver) controllerOf(ctx context.Context, namespace string, ownerRef *metav1.OwnerReference) (*metav1.OwnerReference, error) {
+	gv, err := schema.ParseGroupVersion(ownerRef.APIVersion)
+	if err != nil {
+		return nil, nil
+	}
+	if _, ok := intermediateOwners[gv.WithKind(ownerRef.Kind).GroupKind()]; !ok {
+		return nil, nil
+	}
+	owner := &metav1.PartialObjectMetadata{}
+	owner.SetGroupVersionKind(gv.WithKind(ownerRef.Kind))
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ownerRef.Name}, owner); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return metav1.GetControllerOf(owner), nil
+}
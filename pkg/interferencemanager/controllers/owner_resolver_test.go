@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+)
+
+func TestOwnerResolver(t *testing.T) {
+	controllerRef := func(apiVersion, kind, name string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{
+			{APIVersion: apiVersion, Kind: kind, Name: name, Controller: pointer.Bool(true)},
+		}
+	}
+	client := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default", Name: "web-5d8f", OwnerReferences: controllerRef("apps/v1", "Deployment", "web"),
+		}},
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "standalone"}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default", Name: "backup-27712", OwnerReferences: controllerRef("batch/v1", "CronJob", "backup"),
+		}},
+	).Build()
+	resolver := NewOwnerResolver(client)
+
+	tests := []struct {
+		name      string
+		ownerRefs []metav1.OwnerReference
+		want      interferencev1alpha1.WorkloadReference
+		wantOK    bool
+	}{
+		{
+			name:      "deployment",
+			ownerRefs: controllerRef("apps/v1", "ReplicaSet", "web-5d8f"),
+			want:      interferencev1alpha1.WorkloadReference{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "web"},
+			wantOK:    true,
+		},
+		{
+			name:      "replicaset without owner",
+			ownerRefs: controllerRef("apps/v1", "ReplicaSet", "standalone"),
+			want:      interferencev1alpha1.WorkloadReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Namespace: "default", Name: "standalone"},
+			wantOK:    true,
+		},
+		{
+			name:      "replicaset not found",
+			ownerRefs: controllerRef("apps/v1", "ReplicaSet", "deleted"),
+			want:      interferencev1alpha1.WorkloadReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Namespace: "default", Name: "deleted"},
+			wantOK:    true,
+		},
+		{
+			name:      "cronjob",
+			ownerRefs: controllerRef("batch/v1", "Job", "backup-27712"),
+			want:      interferencev1alpha1.WorkloadReference{APIVersion: "batch/v1", Kind: "CronJob", Namespace: "default", Name: "backup"},
+			wantOK:    true,
+		},
+		{
+			name:      "statefulset",
+			ownerRefs: controllerRef("apps/v1", "StatefulSet", "db"),
+			want:      interferencev1alpha1.WorkloadReference{APIVersion: "apps/v1", Kind: "StatefulSet", Namespace: "default", Name: "db"},
+			wantOK:    true,
+		},
+		{
+			name:      "kruise cloneset",
+			ownerRefs: controllerRef("apps.kruise.io/v1alpha1", "CloneSet", "cache"),
+			want:      interferencev1alpha1.WorkloadReference{APIVersion: "apps.kruise.io/v1alpha1", Kind: "CloneSet", Namespace: "default", Name: "cache"},
+			wantOK:    true,
+		},
+		{
+			name:      "no controller",
+			ownerRefs: []metav1.OwnerReference{{APIVersion: "v1", Kind: "Node", Name: "node-1"}},
+			wantOK:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newTestPod("pod", "uid", "", "")
+			pod.OwnerReferences = tt.ownerRefs
+			got, ok, err := resolver.Resolve(context.TODO(), pod)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestOwnerResolverPodOwners(t *testing.T) {
+	client := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(
+		newTestPod("web-1", "uid-1", "StatefulSet", "web"),
+	).Build()
+	owners := NewOwnerResolver(client).PodOwners(context.TODO())
+
+	kind, name, ok, err := owners("default", "web-1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "StatefulSet", kind)
+	assert.Equal(t, "web", name)
+
+	_, _, ok, err = owners("default", "deleted")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
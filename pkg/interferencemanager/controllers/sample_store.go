@@ -0,0 +1,306 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/aggregation"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+)
+
+// SampleStore is the in-memory aggregation state of all rules. It is fed by the InterferenceDetectionRuleReconciler
+// and persisted into InterferenceMetricCheckpoints by the InterferenceMetricCheckpointReconciler.
+type SampleStore struct {
+	lock  sync.Mutex
+	rules map[types.NamespacedName]*ruleSamples
+
+	restored *atomic.Bool
+}
+
+// ruleSamples keeps the aggregated samples of all workloads selected by a rule.
+type ruleSamples struct {
+	aggregations aggregation.AggregationsState
+	// lastSampleTimes is the timestamp of the latest sample added of each series, so that the same sample returned
+	// by the metric provider again is not added twice.
+	lastSampleTimes map[seriesKey]time.Time
+}
+
+// seriesKey identifies the series of a container, multiple series are aggregated into the workload.
+type seriesKey struct {
+	podUID        string
+	containerName string
+}
+
+func NewSampleStore() *SampleStore {
+	return &SampleStore{
+		rules:    map[types.NamespacedName]*ruleSamples{},
+		restored: atomic.NewBool(false),
+	}
+}
+
+// HasRestored returns whether the state has been restored from checkpoints, baselines calculated before that are
+// incomplete.
+func (s *SampleStore) HasRestored() bool {
+	return s.restored.Load()
+}
+
+func (s *SampleStore) markRestored() {
+	s.restored.Store(true)
+}
+
+// isAdded returns whether the sample of the series at @timestamp has been added. Samples without timestamps are
+// always new.
+func (r *ruleSamples) isAdded(series seriesKey, timestamp time.Time) bool {
+	if timestamp.IsZero() {
+		return false
+	}
+	last, ok := r.lastSampleTimes[series]
+	return ok && !timestamp.After(last)
+}
+
+func (r *ruleSamples) addSample(key aggregation.AggregationKey, value float64, timestamp time.Time, window time.Duration) {
+	a, ok := r.aggregations[key]
+	if !ok {
+		a = aggregation.NewContainerAggregation(aggregation.NewHistogramOptionsOf(key.Metric), window)
+		r.aggregations[key] = a
+	}
+	a.AddSample(value, timestamp)
+}
+
+func (s *SampleStore) getOrCreateRule(ruleName types.NamespacedName) *ruleSamples {
+	samples, ok := s.rules[ruleName]
+	if !ok {
+		samples = &ruleSamples{
+			aggregations:    aggregation.AggregationsState{},
+			lastSampleTimes: map[seriesKey]time.Time{},
+		}
+		s.rules[ruleName] = samples
+	}
+	return samples
+}
+
+// updateBaselines aggregates the samples of selected workloads, whose weights are halved every @window, and returns
+// the baselines which have at least @minSampleCount samples, along with the number of workloads which have not.
+// Samples are added at their timestamps, or @now if unknown, and samples already added are skipped.
+// Aggregations of workloads no longer selected, of other metrics or without samples in the last @window are dropped.
+func (s *SampleStore) updateBaselines(ruleName types.NamespacedName, metricName interferencev1alpha1.MetricName,
+	podWorkloads map[string]interferencev1alpha1.WorkloadReference, metrics []*common.Metric,
+	window time.Duration, now time.Time, minSampleCount int64) ([]interferencev1alpha1.WorkloadBaseline, int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	samples := s.getOrCreateRule(ruleName)
+
+	for _, metric := range metrics {
+		owner, ok := podWorkloads[metric.Labels[common.PodUID]]
+		if !ok {
+			continue
+		}
+		series := seriesKey{podUID: metric.Labels[common.PodUID], containerName: metric.Labels[common.ContainerName]}
+		if samples.isAdded(series, metric.Timestamp) {
+			continue
+		}
+		timestamp := metric.Timestamp
+		if timestamp.IsZero() {
+			timestamp = now
+		}
+		samples.lastSampleTimes[series] = timestamp
+		key := aggregation.AggregationKey{
+			Owner:         owner,
+			ContainerName: series.containerName,
+			Metric:        metricName,
+		}
+		samples.addSample(key, metric.Value, timestamp, window)
+	}
+	for series := range samples.lastSampleTimes {
+		if _, ok := podWorkloads[series.podUID]; !ok {
+			delete(samples.lastSampleTimes, series)
+		}
+	}
+
+	selectedOwners := map[interferencev1alpha1.WorkloadReference]struct{}{}
+	for _, owner := range podWorkloads {
+		selectedOwners[owner] = struct{}{}
+	}
+	windowStart := now.Add(-window)
+	var baselines []interferencev1alpha1.WorkloadBaseline
+	pending := 0
+	for key, a := range samples.aggregations {
+		if _, ok := selectedOwners[key.Owner]; !ok || key.Metric != metricName {
+			delete(samples.aggregations, key)
+			continue
+		}
+		if a.TotalSamplesCount == 0 || a.LastSampleTime.Before(windowStart) {
+			delete(samples.aggregations, key)
+			continue
+		}
+		if a.TotalSamplesCount < minSampleCount {
+			pending++
+			continue
+		}
+		baselines = append(baselines, *baselineOf(key, a, now))
+	}
+	sort.Slice(baselines, func(i, j int) bool {
+		return baselineLess(&baselines[i], &baselines[j])
+	})
+	return baselines, pending
+}
+
+// addSeries aggregates the history samples of selected workloads, and returns the number of samples added.
+func (s *SampleStore) addSeries(ruleName types.NamespacedName, metricName interferencev1alpha1.MetricName,
+	podWorkloads map[string]interferencev1alpha1.WorkloadReference, series []*common.MetricSeries,
+	window time.Duration) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	samples := s.getOrCreateRule(ruleName)
+
+	added := 0
+	for _, ss := range series {
+		owner, ok := podWorkloads[ss.Labels[common.PodUID]]
+		if !ok {
+			continue
+		}
+		key := aggregation.AggregationKey{
+			Owner:         owner,
+			ContainerName: ss.Labels[common.ContainerName],
+			Metric:        metricName,
+		}
+		for _, sample := range ss.Samples {
+			samples.addSample(key, sample.Value, sample.Timestamp, window)
+			added++
+		}
+	}
+	return added
+}
+
+// lastSampleTime returns the time of the latest sample of the metric in the rule, or zero if there is none.
+func (s *SampleStore) lastSampleTime(ruleName types.NamespacedName, metricName interferencev1alpha1.MetricName) time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var last time.Time
+	samples, ok := s.rules[ruleName]
+	if !ok {
+		return last
+	}
+	for key, a := range samples.aggregations {
+		if key.Metric == metricName && a.LastSampleTime.After(last) {
+			last = a.LastSampleTime
+		}
+	}
+	return last
+}
+
+func (s *SampleStore) forgetRule(ruleName types.NamespacedName) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.rules, ruleName)
+}
+
+// ruleNames returns the names of rules which have samples.
+func (s *SampleStore) ruleNames() []types.NamespacedName {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	names := make([]types.NamespacedName, 0, len(s.rules))
+	for name := range s.rules {
+		names = append(names, name)
+	}
+	return names
+}
+
+// saveCheckpoints serializes the samples of a rule into checkpoint specs, one for each workload and metric. Only the
+// first MaxCheckpointContainers histograms ordered by container names are kept in a checkpoint.
+func (s *SampleStore) saveCheckpoints(ruleName types.NamespacedName, now time.Time) []*interferencev1alpha1.InterferenceMetricCheckpointSpec {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	samples, ok := s.rules[ruleName]
+	if !ok {
+		return nil
+	}
+
+	type workloadMetric struct {
+		owner  interferencev1alpha1.WorkloadReference
+		metric interferencev1alpha1.MetricName
+	}
+	checkpoints := map[workloadMetric]*interferencev1alpha1.InterferenceMetricCheckpointSpec{}
+	for key, a := range samples.aggregations {
+		if a.TotalSamplesCount == 0 {
+			continue
+		}
+		histogram, err := a.SaveToCheckpoint(key.ContainerName)
+		if err != nil {
+			checkpointLog.Error(err, "failed to checkpoint histogram", "rule", ruleName, "workload", key.Owner,
+				"container", key.ContainerName)
+			continue
+		}
+		wm := workloadMetric{owner: key.Owner, metric: key.Metric}
+		checkpoint, ok := checkpoints[wm]
+		if !ok {
+			checkpoint = &interferencev1alpha1.InterferenceMetricCheckpointSpec{
+				Version:        interferencev1alpha1.CheckpointVersion,
+				RuleName:       ruleName.Name,
+				Owner:          key.Owner,
+				Metric:         key.Metric,
+				LastUpdateTime: metav1.NewTime(now),
+			}
+			checkpoints[wm] = checkpoint
+		}
+		checkpoint.Histograms = append(checkpoint.Histograms, *histogram)
+	}
+
+	result := make([]*interferencev1alpha1.InterferenceMetricCheckpointSpec, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		sort.Slice(checkpoint.Histograms, func(i, j int) bool {
+			return checkpoint.Histograms[i].ContainerName < checkpoint.Histograms[j].ContainerName
+		})
+		if dropped := len(checkpoint.Histograms) - interferencev1alpha1.MaxCheckpointContainers; dropped > 0 {
+			checkpointLog.Info("drop histograms exceeding the limit of a checkpoint", "rule", ruleName,
+				"workload", checkpoint.Owner, "metric", checkpoint.Metric, "count", dropped,
+				"limit", interferencev1alpha1.MaxCheckpointContainers)
+			checkpoint.Histograms = checkpoint.Histograms[:interferencev1alpha1.MaxCheckpointContainers]
+		}
+		result = append(result, checkpoint)
+	}
+	return result
+}
+
+// loadCheckpoint restores the aggregated samples of a rule from the checkpoint.
+func (s *SampleStore) loadCheckpoint(ruleName types.NamespacedName, checkpoint *interferencev1alpha1.InterferenceMetricCheckpointSpec) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	samples := s.getOrCreateRule(ruleName)
+	for i := range checkpoint.Histograms {
+		a, err := aggregation.NewContainerAggregationFromCheckpoint(&checkpoint.Histograms[i])
+		if err != nil {
+			return fmt.Errorf("invalid histogram of container %s: %v", checkpoint.Histograms[i].ContainerName, err)
+		}
+		key := aggregation.AggregationKey{
+			Owner:         checkpoint.Owner,
+			ContainerName: checkpoint.Histograms[i].ContainerName,
+			Metric:        checkpoint.Metric,
+		}
+		samples.aggregations[key] = a
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	interferencev1alpha1 "github.com/koordinator-sh/koordetector/apis/interference/v1alpha1"
+)
+
+// selectWorkloadPods lists pods in @namespace selected by @selector and groups them by uid, along with the
+// workloads they belong to, which are resolved to top-level controllers like Deployments. Pods without a controller
+// are ignored since they have no workload to compare with.
+func selectWorkloadPods(ctx context.Context, c client.Client, namespace string,
+	selector *interferencev1alpha1.WorkloadSelector) (map[string]interferencev1alpha1.WorkloadReference, error) {
+	if selector == nil {
+		selector = &interferencev1alpha1.WorkloadSelector{}
+	}
+
+	podWorkloads := map[string]interferencev1alpha1.WorkloadReference{}
+	podSelector := labels.Everything()
+	if selector.PodSelector != nil {
+		s, err := metav1.LabelSelectorAsSelector(selector.PodSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pod selector: %v", err)
+		}
+		podSelector = s
+	}
+	podList := &corev1.PodList{}
+	if err := c.List(ctx, podList, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: podSelector}); err != nil {
+		return nil, err
+	}
+
+	resolver := NewOwnerResolver(c)
+	ownerKinds := sets.NewString(selector.OwnerKinds...)
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		owner, ok, err := resolver.Resolve(ctx, pod)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve workload of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
+		if !ok {
+			continue
+		}
+		if ownerKinds.Len() > 0 && !ownerKinds.Has(owner.Kind) {
+			continue
+		}
+		podWorkloads[string(pod.UID)] = owner
+	}
+	return podWorkloads, nil
+}
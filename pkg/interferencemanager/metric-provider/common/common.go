@@ -17,6 +17,9 @@ limitations under the License.
 package common
 
 import (
+	"fmt"
+	"time"
+
 	prommodel "github.com/prometheus/common/model"
 )
 
@@ -24,18 +27,201 @@ type MetricQueryOptions struct {
 	MetricName   string
 	FilterLabels map[string]string
 
+	// Level is the granularity of the query, it is inferred from MetricName if empty.
+	Level QueryLevel
+
 	PromSumByLabels []string
+
+	// Owners resolves the workloads of pods, which is required at WorkloadLevel.
+	Owners PodOwnerFunc
+
+	// Range is the time window of range queries, it is ignored by instant queries.
+	Range *TimeRange
+}
+
+// TimeRange is the time window of a range query, series are sampled every Step within [Start, End].
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+	Step  time.Duration
+}
+
+// AggregationType is the operator to aggregate series with the same group-by labels.
+type AggregationType string
+
+const (
+	AggregationSum AggregationType = "sum"
+	AggregationAvg AggregationType = "avg"
+	AggregationMax AggregationType = "max"
+	AggregationMin AggregationType = "min"
+)
+
+// MetricSelector selects the series of a metric by labels. Series must have all FilterLabels and match all
+// Matchers.
+type MetricSelector struct {
+	MetricName   string
+	FilterLabels map[string]string
+	Matchers     []LabelMatcher
+}
+
+// MetricQuery describes a query on any metric. Series are aggregated by GroupByLabels with Aggregation, and no
+// aggregation is done if both are empty. If Denominator is set, the result is the ratio of the metric to the
+// denominator, which is aggregated in the same way, e.g. cycles / instructions for CPI.
+type MetricQuery struct {
+	MetricSelector
+
+	GroupByLabels []string
+	Aggregation   AggregationType
+	Denominator   *MetricSelector
+}
+
+// QueryLevel is the granularity of a metric query.
+type QueryLevel string
+
+const (
+	ContainerLevel QueryLevel = "container"
+	PodLevel       QueryLevel = "pod"
+	// WorkloadLevel aggregates pod series by the workloads owning the pods, which are resolved by the interference
+	// manager since sources do not know them, see QueryWorkloadCPI.
+	WorkloadLevel QueryLevel = "workload"
+)
+
+// QueryLevelSpec describes how to query a metric at a level.
+type QueryLevelSpec struct {
+	// MetricName is the metric of the level.
+	MetricName string
+	// GroupByLabels identify series at the level.
+	GroupByLabels []string
+	// MakeLabels converts the labels of results.
+	MakeLabels MakeLabelsFunc
+}
+
+// GetCPIQueryLevel returns how CPI is queried at the level.
+func GetCPIQueryLevel(level QueryLevel) (*QueryLevelSpec, error) {
+	switch level {
+	case ContainerLevel:
+		return &QueryLevelSpec{
+			MetricName:    KoordletContainerCPI,
+			GroupByLabels: []string{ContainerID, ContainerName, PodUID, PodNamespace, PodName, Node},
+			MakeLabels:    MakeContainerCPILabels,
+		}, nil
+	case PodLevel:
+		return &QueryLevelSpec{
+			MetricName:    KoordletPodCPI,
+			GroupByLabels: []string{PodUID, PodNamespace, PodName, Node},
+			MakeLabels:    MakePodCPILabels,
+		}, nil
+	case WorkloadLevel:
+		return &QueryLevelSpec{
+			MetricName:    KoordletPodCPI,
+			GroupByLabels: []string{PodNamespace, OwnerKind, OwnerName},
+			MakeLabels:    MakeWorkloadLabels,
+		}, nil
+	}
+	return nil, fmt.Errorf("query level %v not supported", level)
+}
+
+// CPIQueryLevelOf returns the level of CPI queries, which is inferred from the metric name if not specified.
+func CPIQueryLevelOf(options MetricQueryOptions) (QueryLevel, error) {
+	if options.Level != "" {
+		return options.Level, nil
+	}
+	switch options.MetricName {
+	case KoordletContainerCPI:
+		return ContainerLevel, nil
+	case KoordletPodCPI:
+		return PodLevel, nil
+	}
+	return "", fmt.Errorf("metric name %v not supported", options.MetricName)
 }
 
 type Metric struct {
 	Labels map[string]string
 	Value  float64
+	// Timestamp is when the value is sampled by the source, which is the evaluation time of Prometheus queries, or
+	// zero if the source does not tell.
+	Timestamp time.Time
+}
+
+// NewCPIQuery makes the generic query of CPI at the level of @options, which is the ratio of cycles to
+// instructions. Queries at WorkloadLevel can not be evaluated by sources, use QueryWorkloadCPI instead.
+func NewCPIQuery(options MetricQueryOptions) (MetricQuery, error) {
+	level, err := CPIQueryLevelOf(options)
+	if err != nil {
+		return MetricQuery{}, err
+	}
+	if level == WorkloadLevel {
+		return MetricQuery{}, fmt.Errorf("cpi at %v level is aggregated by owners of pods, not by sources", level)
+	}
+	spec, err := GetCPIQueryLevel(level)
+	if err != nil {
+		return MetricQuery{}, err
+	}
+	if options.PromSumByLabels == nil {
+		options.PromSumByLabels = spec.GroupByLabels
+	}
+	cyclesLabels := map[string]string{CPIField: Cycles}
+	instructionsLabels := map[string]string{CPIField: Instructions}
+	for label, value := range options.FilterLabels {
+		if label == CPIField {
+			continue
+		}
+		cyclesLabels[label] = value
+		instructionsLabels[label] = value
+	}
+	return MetricQuery{
+		MetricSelector: MetricSelector{
+			MetricName:   spec.MetricName,
+			FilterLabels: cyclesLabels,
+		},
+		GroupByLabels: options.PromSumByLabels,
+		Aggregation:   AggregationSum,
+		Denominator: &MetricSelector{
+			MetricName:   spec.MetricName,
+			FilterLabels: instructionsLabels,
+		},
+	}, nil
+}
+
+// CPILabelFunc returns @labelFunc, or the default one of the level of @options if it is nil.
+func CPILabelFunc(options MetricQueryOptions, labelFunc MakeLabelsFunc) (MakeLabelsFunc, error) {
+	if labelFunc != nil {
+		return labelFunc, nil
+	}
+	level, err := CPIQueryLevelOf(options)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := GetCPIQueryLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return spec.MakeLabels, nil
+}
+
+// Sample is a value of a series at a point of time.
+type Sample struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// MetricSeries is the samples of a series in a range query, in time order.
+type MetricSeries struct {
+	Labels  map[string]string
+	Samples []Sample
 }
 
 type ProviderType string
 
 const (
 	PrometheusProvider ProviderType = "prometheus_provider"
+	// CustomMetricsProvider gets metrics from the custom.metrics.k8s.io or external.metrics.k8s.io aggregated API,
+	// e.g. served by prometheus-adapter or KEDA.
+	CustomMetricsProvider ProviderType = "custom_metrics_provider"
+	// NodeMetricProvider gets the resource usages reported by koordlet in Koordinator NodeMetrics.
+	NodeMetricProvider ProviderType = "node_metric_provider"
+	// IngestionProvider serves the samples pushed by koordetector daemons to the interference manager.
+	IngestionProvider ProviderType = "ingestion_provider"
 )
 
 const (
@@ -45,6 +231,9 @@ const (
 	PodName       string = "pod_name"
 	PodNamespace  string = "pod_namespace"
 	PodUID        string = "pod_uid"
+	// OwnerKind and OwnerName are the workload owning a pod, which are attached by QueryWorkloadCPI.
+	OwnerKind string = "owner_kind"
+	OwnerName string = "owner_name"
 
 	CPIField string = "cpi_field"
 )
@@ -57,8 +246,33 @@ const (
 	Instructions string = "instructions"
 )
 
+const (
+	// NodeMetricPodCPUUsage is the cpu usage of pods in cores reported in NodeMetrics.
+	NodeMetricPodCPUUsage string = "nodemetric_pod_cpu_usage"
+	// NodeMetricPodMemoryUsage is the memory usage of pods in bytes reported in NodeMetrics.
+	NodeMetricPodMemoryUsage string = "nodemetric_pod_memory_usage"
+	// NodeMetricNodeCPUUsage is the cpu usage of nodes in cores reported in NodeMetrics.
+	NodeMetricNodeCPUUsage string = "nodemetric_node_cpu_usage"
+	// NodeMetricNodeMemoryUsage is the memory usage of nodes in bytes reported in NodeMetrics.
+	NodeMetricNodeMemoryUsage string = "nodemetric_node_memory_usage"
+)
+
 type MakeLabelsFunc func(metric prommodel.Metric) (map[string]string, error)
 
+// MakeAllLabels keeps all labels of the result series.
+func MakeAllLabels(metric prommodel.Metric) (map[string]string, error) {
+	labels := make(map[string]string, len(metric))
+	for name, value := range metric {
+		labels[string(name)] = string(value)
+	}
+	return labels, nil
+}
+
+// MakeContainerLabels keeps the labels identifying a container, which are shared by container metrics.
+func MakeContainerLabels(metric prommodel.Metric) (map[string]string, error) {
+	return MakeContainerCPILabels(metric)
+}
+
 func MakeContainerCPILabels(metric prommodel.Metric) (map[string]string, error) {
 	labels := map[string]string{
 		ContainerID:   string(metric["container_id"]),
@@ -71,6 +285,11 @@ func MakeContainerCPILabels(metric prommodel.Metric) (map[string]string, error)
 	return labels, nil
 }
 
+// MakePodLabels keeps the labels identifying a pod, which are shared by pod metrics.
+func MakePodLabels(metric prommodel.Metric) (map[string]string, error) {
+	return MakePodCPILabels(metric)
+}
+
 func MakePodCPILabels(metric prommodel.Metric) (map[string]string, error) {
 	labels := map[string]string{
 		PodUID:       string(metric["pod_uid"]),
@@ -80,3 +299,13 @@ func MakePodCPILabels(metric prommodel.Metric) (map[string]string, error) {
 	}
 	return labels, nil
 }
+
+// MakeWorkloadLabels keeps the labels identifying a workload.
+func MakeWorkloadLabels(metric prommodel.Metric) (map[string]string, error) {
+	labels := map[string]string{
+		PodNamespace: string(metric["pod_namespace"]),
+		OwnerKind:    string(metric["owner_kind"]),
+		OwnerName:    string(metric["owner_name"]),
+	}
+	return labels, nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"fmt"
+	"regexp"
+
+	prommodel "github.com/prometheus/common/model"
+)
+
+// MatchType is the type of label matchers in PromQL.
+type MatchType string
+
+const (
+	MatchEqual     MatchType = "="
+	MatchNotEqual  MatchType = "!="
+	MatchRegexp    MatchType = "=~"
+	MatchNotRegexp MatchType = "!~"
+)
+
+// LabelMatcher matches the value of a label like PromQL does, a missing label matches as an empty value.
+type LabelMatcher struct {
+	Name  string
+	Type  MatchType
+	Value string
+}
+
+// Validate checks the label name, the match type and the regexp of the matcher.
+func (m *LabelMatcher) Validate() error {
+	if !prommodel.LabelName(m.Name).IsValid() {
+		return fmt.Errorf("invalid label name %q", m.Name)
+	}
+	switch m.Type {
+	case MatchEqual, MatchNotEqual:
+	case MatchRegexp, MatchNotRegexp:
+		if _, err := compileAnchored(m.Value); err != nil {
+			return fmt.Errorf("invalid regexp %q of label %v: %v", m.Value, m.Name, err)
+		}
+	default:
+		return fmt.Errorf("match type %q of label %v not supported", m.Type, m.Name)
+	}
+	return nil
+}
+
+// compileAnchored compiles the regexp of a matcher, which matches the whole value in PromQL.
+func compileAnchored(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// matchSeries filters @values by @matchers, for providers whose sources only select series by equality.
+func matchSeries(values []LabeledValue, matchers []LabelMatcher) ([]LabeledValue, error) {
+	if len(matchers) == 0 {
+		return values, nil
+	}
+	regexps := make([]*regexp.Regexp, len(matchers))
+	for i := range matchers {
+		if err := matchers[i].Validate(); err != nil {
+			return nil, err
+		}
+		if matchers[i].Type == MatchRegexp || matchers[i].Type == MatchNotRegexp {
+			regexps[i], _ = compileAnchored(matchers[i].Value)
+		}
+	}
+	var result []LabeledValue
+	for _, v := range values {
+		matched := true
+		for i, m := range matchers {
+			value := string(v.Labels[prommodel.LabelName(m.Name)])
+			switch m.Type {
+			case MatchEqual:
+				matched = value == m.Value
+			case MatchNotEqual:
+				matched = value != m.Value
+			case MatchRegexp:
+				matched = regexps[i].MatchString(value)
+			case MatchNotRegexp:
+				matched = !regexps[i].MatchString(value)
+			}
+			if !matched {
+				break
+			}
+		}
+		if matched {
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	prommodel "github.com/prometheus/common/model"
+)
+
+// GetMetricFunc gets the values of all series selected.
+type GetMetricFunc func(selector MetricSelector) ([]LabeledValue, error)
+
+// EvaluateQuery evaluates @query on the series got by @getFunc, for providers whose sources can not aggregate
+// series themselves. The result is ordered by labels.
+func EvaluateQuery(query MetricQuery, getFunc GetMetricFunc, labelFunc MakeLabelsFunc) ([]*Metric, error) {
+	if query.MetricName == "" {
+		return nil, fmt.Errorf("metric name is required")
+	}
+	if labelFunc == nil {
+		labelFunc = MakeAllLabels
+	}
+
+	values, err := getSelected(query.MetricSelector, getFunc)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := aggregate(values, query.GroupByLabels, query.Aggregation)
+	if err != nil {
+		return nil, err
+	}
+	if query.Denominator != nil {
+		denominatorValues, err := getSelected(*query.Denominator, getFunc)
+		if err != nil {
+			return nil, err
+		}
+		denominators, err := aggregate(denominatorValues, query.GroupByLabels, query.Aggregation)
+		if err != nil {
+			return nil, err
+		}
+		groups = divide(groups, denominators)
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]*Metric, 0, len(groups))
+	for _, key := range keys {
+		g := groups[key]
+		labels, err := labelFunc(g.Labels)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &Metric{
+			Labels:    labels,
+			Value:     g.Value,
+			Timestamp: g.Timestamp,
+		})
+	}
+	return result, nil
+}
+
+// getSelected gets the series selected by @getFunc, and filters them by the matchers of @selector, which
+// sources do not support.
+func getSelected(selector MetricSelector, getFunc GetMetricFunc) ([]LabeledValue, error) {
+	values, err := getFunc(selector)
+	if err != nil {
+		return nil, err
+	}
+	return matchSeries(values, selector.Matchers)
+}
+
+// LabeledValue is a value of a metric with its labels, and the time it is sampled if known.
+type LabeledValue struct {
+	Labels    prommodel.Metric
+	Value     float64
+	Timestamp time.Time
+}
+
+// aggregate groups the series by @groupBy labels with @aggregation like PromQL does, series are kept as is if
+// both are empty. The timestamp of a group is the latest one of its series.
+func aggregate(values []LabeledValue, groupBy []string, aggregation AggregationType) (map[string]*LabeledValue, error) {
+	if aggregation == "" && len(groupBy) > 0 {
+		aggregation = AggregationSum
+	}
+	switch aggregation {
+	case "", AggregationSum, AggregationAvg, AggregationMax, AggregationMin:
+	default:
+		return nil, fmt.Errorf("aggregation %v not supported", aggregation)
+	}
+	groups := map[string]*LabeledValue{}
+	if aggregation == "" {
+		for i := range values {
+			groups[values[i].Labels.String()] = &values[i]
+		}
+		return groups, nil
+	}
+
+	counts := map[string]int{}
+	for _, v := range values {
+		groupLabels := prommodel.Metric{}
+		for _, name := range groupBy {
+			if value, ok := v.Labels[prommodel.LabelName(name)]; ok {
+				groupLabels[prommodel.LabelName(name)] = value
+			}
+		}
+		key := groupLabels.String()
+		g, ok := groups[key]
+		if !ok {
+			groups[key] = &LabeledValue{Labels: groupLabels, Value: v.Value, Timestamp: v.Timestamp}
+			counts[key] = 1
+			continue
+		}
+		counts[key]++
+		if v.Timestamp.After(g.Timestamp) {
+			g.Timestamp = v.Timestamp
+		}
+		switch aggregation {
+		case AggregationSum, AggregationAvg:
+			g.Value += v.Value
+		case AggregationMax:
+			if v.Value > g.Value {
+				g.Value = v.Value
+			}
+		case AggregationMin:
+			if v.Value < g.Value {
+				g.Value = v.Value
+			}
+		}
+	}
+	if aggregation == AggregationAvg {
+		for key, g := range groups {
+			g.Value /= float64(counts[key])
+		}
+	}
+	return groups, nil
+}
+
+// divide matches the groups with the same labels like PromQL does, groups without a non-zero denominator are
+// dropped.
+func divide(numerators, denominators map[string]*LabeledValue) map[string]*LabeledValue {
+	result := map[string]*LabeledValue{}
+	for key, n := range numerators {
+		d, ok := denominators[key]
+		if !ok || d.Value == 0 {
+			continue
+		}
+		timestamp := n.Timestamp
+		if d.Timestamp.After(timestamp) {
+			timestamp = d.Timestamp
+		}
+		result[key] = &LabeledValue{Labels: n.Labels, Value: n.Value / d.Value, Timestamp: timestamp}
+	}
+	return result
+}
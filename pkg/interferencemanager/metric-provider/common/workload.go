@@ -0,0 +1,245 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	prommodel "github.com/prometheus/common/model"
+)
+
+// PodOwnerFunc returns the kind and name of the workload which the pod belongs to. It returns false if the pod
+// is not found or has no controller.
+type PodOwnerFunc func(namespace, name string) (kind, ownerName string, ok bool, err error)
+
+// QueryFunc gets the current values of @query, like MetricProvider.Query.
+type QueryFunc func(query MetricQuery, labelFunc MakeLabelsFunc) ([]*Metric, error)
+
+// QueryRangeFunc gets the series of @query within @timeRange, like MetricProvider.QueryRange.
+type QueryRangeFunc func(query MetricQuery, timeRange TimeRange, labelFunc MakeLabelsFunc) ([]*MetricSeries, error)
+
+// QueryWorkloadCPI gets the CPI of workloads, which no source knows. Cycles and instructions of pods are got by
+// @queryFunc, attached with the workloads resolved by options.Owners, and summed up by workloads before
+// dividing. Pods without workloads are dropped.
+func QueryWorkloadCPI(options MetricQueryOptions, queryFunc QueryFunc, labelFunc MakeLabelsFunc) ([]*Metric, error) {
+	query, podQuery, ownerMatchers, err := newWorkloadCPIQuery(options)
+	if err != nil {
+		return nil, err
+	}
+	labelFunc, err = CPILabelFunc(options, labelFunc)
+	if err != nil {
+		return nil, err
+	}
+	owners := cachedOwners(options.Owners)
+	return EvaluateQuery(query, func(selector MetricSelector) ([]LabeledValue, error) {
+		podQuery.MetricSelector = selector
+		metrics, err := queryFunc(podQuery, MakeAllLabels)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]LabeledValue, 0, len(metrics))
+		for _, metric := range metrics {
+			values = append(values, LabeledValue{Labels: toPromLabels(metric.Labels), Value: metric.Value,
+				Timestamp: metric.Timestamp})
+		}
+		return withOwners(values, owners, ownerMatchers)
+	}, labelFunc)
+}
+
+// QueryWorkloadCPIRange gets the CPI series of workloads within options.Range like QueryWorkloadCPI, the series
+// of pods are got by @queryRangeFunc and summed up at each step.
+func QueryWorkloadCPIRange(options MetricQueryOptions, queryRangeFunc QueryRangeFunc, labelFunc MakeLabelsFunc) ([]*MetricSeries, error) {
+	if options.Range == nil {
+		return nil, fmt.Errorf("time range is required for range queries")
+	}
+	query, podQuery, ownerMatchers, err := newWorkloadCPIQuery(options)
+	if err != nil {
+		return nil, err
+	}
+	labelFunc, err = CPILabelFunc(options, labelFunc)
+	if err != nil {
+		return nil, err
+	}
+	owners := cachedOwners(options.Owners)
+
+	// values of pods by selectors and steps
+	valuesAt := map[string]map[time.Time][]LabeledValue{}
+	var steps []time.Time
+	seenSteps := map[time.Time]bool{}
+	for _, selector := range []MetricSelector{query.MetricSelector, *query.Denominator} {
+		podQuery.MetricSelector = selector
+		series, err := queryRangeFunc(podQuery, *options.Range, MakeAllLabels)
+		if err != nil {
+			return nil, err
+		}
+		values := map[time.Time][]LabeledValue{}
+		for _, s := range series {
+			labels := toPromLabels(s.Labels)
+			for _, sample := range s.Samples {
+				values[sample.Timestamp] = append(values[sample.Timestamp],
+					LabeledValue{Labels: labels, Value: sample.Value, Timestamp: sample.Timestamp})
+				if !seenSteps[sample.Timestamp] {
+					seenSteps[sample.Timestamp] = true
+					steps = append(steps, sample.Timestamp)
+				}
+			}
+		}
+		valuesAt[selectorKey(selector)] = values
+	}
+	sort.Slice(steps, func(i, j int) bool { return steps[i].Before(steps[j]) })
+
+	// evaluate with raw labels, so that series are identified before labelFunc drops any label
+	seriesByLabels := map[string]*MetricSeries{}
+	var keys []string
+	for _, step := range steps {
+		metrics, err := EvaluateQuery(query, func(selector MetricSelector) ([]LabeledValue, error) {
+			return withOwners(valuesAt[selectorKey(selector)][step], owners, ownerMatchers)
+		}, MakeAllLabels)
+		if err != nil {
+			return nil, err
+		}
+		for _, metric := range metrics {
+			rawLabels := toPromLabels(metric.Labels)
+			key := rawLabels.String()
+			series, ok := seriesByLabels[key]
+			if !ok {
+				labels, err := labelFunc(rawLabels)
+				if err != nil {
+					return nil, err
+				}
+				series = &MetricSeries{Labels: labels}
+				seriesByLabels[key] = series
+				keys = append(keys, key)
+			}
+			series.Samples = append(series.Samples, Sample{Timestamp: step, Value: metric.Value})
+		}
+	}
+	sort.Strings(keys)
+	result := make([]*MetricSeries, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, seriesByLabels[key])
+	}
+	return result, nil
+}
+
+// newWorkloadCPIQuery makes the query of CPI by workloads, and the query of the pod series to be attached with
+// workloads. Filters on workload labels are returned as matchers, since they are applied after resolving.
+func newWorkloadCPIQuery(options MetricQueryOptions) (MetricQuery, MetricQuery, []LabelMatcher, error) {
+	if options.Owners == nil {
+		return MetricQuery{}, MetricQuery{}, nil, fmt.Errorf("owners of pods are required at %v level", WorkloadLevel)
+	}
+	spec, err := GetCPIQueryLevel(WorkloadLevel)
+	if err != nil {
+		return MetricQuery{}, MetricQuery{}, nil, err
+	}
+	podSpec, err := GetCPIQueryLevel(PodLevel)
+	if err != nil {
+		return MetricQuery{}, MetricQuery{}, nil, err
+	}
+	groupBy := options.PromSumByLabels
+	if groupBy == nil {
+		groupBy = spec.GroupByLabels
+	}
+
+	cyclesLabels := map[string]string{CPIField: Cycles}
+	instructionsLabels := map[string]string{CPIField: Instructions}
+	var ownerMatchers []LabelMatcher
+	for label, value := range options.FilterLabels {
+		switch label {
+		case CPIField:
+			continue
+		case OwnerKind, OwnerName:
+			ownerMatchers = append(ownerMatchers, LabelMatcher{Name: label, Type: MatchEqual, Value: value})
+			continue
+		}
+		cyclesLabels[label] = value
+		instructionsLabels[label] = value
+	}
+	query := MetricQuery{
+		MetricSelector: MetricSelector{
+			MetricName:   spec.MetricName,
+			FilterLabels: cyclesLabels,
+		},
+		GroupByLabels: groupBy,
+		Aggregation:   AggregationSum,
+		Denominator: &MetricSelector{
+			MetricName:   spec.MetricName,
+			FilterLabels: instructionsLabels,
+		},
+	}
+	podQuery := MetricQuery{
+		GroupByLabels: podSpec.GroupByLabels,
+		Aggregation:   AggregationSum,
+	}
+	return query, podQuery, ownerMatchers, nil
+}
+
+// cachedOwners resolves the owner of each pod once in a query, since cycles and instructions are of the same pods.
+func cachedOwners(owners PodOwnerFunc) PodOwnerFunc {
+	type owner struct {
+		kind, name string
+		ok         bool
+	}
+	resolved := map[string]owner{}
+	return func(namespace, name string) (string, string, bool, error) {
+		key := namespace + "/" + name
+		if o, ok := resolved[key]; ok {
+			return o.kind, o.name, o.ok, nil
+		}
+		kind, ownerName, ok, err := owners(namespace, name)
+		if err != nil {
+			return "", "", false, err
+		}
+		resolved[key] = owner{kind: kind, name: ownerName, ok: ok}
+		return kind, ownerName, ok, nil
+	}
+}
+
+// withOwners labels the pod series with OwnerKind and OwnerName, pods without workloads or not matching
+// @matchers are dropped.
+func withOwners(values []LabeledValue, owners PodOwnerFunc, matchers []LabelMatcher) ([]LabeledValue, error) {
+	result := make([]LabeledValue, 0, len(values))
+	for _, v := range values {
+		kind, ownerName, ok, err := owners(string(v.Labels[prommodel.LabelName(PodNamespace)]),
+			string(v.Labels[prommodel.LabelName(PodName)]))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		labels := v.Labels.Clone()
+		labels[prommodel.LabelName(OwnerKind)] = prommodel.LabelValue(kind)
+		labels[prommodel.LabelName(OwnerName)] = prommodel.LabelValue(ownerName)
+		result = append(result, LabeledValue{Labels: labels, Value: v.Value, Timestamp: v.Timestamp})
+	}
+	return matchSeries(result, matchers)
+}
+
+func selectorKey(selector MetricSelector) string {
+	return selector.MetricName + toPromLabels(selector.FilterLabels).String()
+}
+
+func toPromLabels(labels map[string]string) prommodel.Metric {
+	metric := make(prommodel.Metric, len(labels))
+	for name, value := range labels {
+		metric[prommodel.LabelName(name)] = prommodel.LabelValue(value)
+	}
+	return metric
+}
@@ -19,16 +19,124 @@ package config
 import (
 	"time"
 
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
 	mp "github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
 )
 
 type MetricProviderConfig struct {
-	ProviderType mp.ProviderType
-	PromConf     PrometheusProviderConfig
+	ProviderType      mp.ProviderType
+	PromConf          PrometheusProviderConfig
+	CustomMetricsConf CustomMetricsProviderConfig
+	NodeMetricConf    NodeMetricProviderConfig
+	IngestionConf     IngestionProviderConfig
 }
 
 type PrometheusProviderConfig struct {
-	// todo: prom-server service cluster IP, port default 9090, use flag
+	// Address is the url of Prometheus, e.g. http://prometheus-k8s.monitoring:9090.
 	Address      string
 	QueryTimeout time.Duration
+	// CacheTTL is how long the results of queries are shared by identical queries, 15s by default and
+	// disabled if negative. Identical queries in flight are always coalesced.
+	CacheTTL time.Duration
+	// Lookback is how long a series is returned after its last sample, which is 5m in Prometheus by default.
+	// A shorter lookback keeps the last values of idle or deleted containers out of baselines.
+	Lookback time.Duration
+
+	// BearerTokenFile is the file of the bearer token sent to Prometheus, which is read on every request so that
+	// rotated tokens take effect.
+	BearerTokenFile string
+	// BasicAuth authenticates with Prometheus by basic auth, it can not be set with BearerTokenFile.
+	BasicAuth *BasicAuthConfig
+	// TLS configures the TLS connections to Prometheus, including mTLS.
+	TLS TLSConfig
+	// TenantID is sent as the X-Scope-OrgID header to multi-tenant Prometheus like Thanos and Cortex.
+	TenantID string
+	// Headers are extra headers sent to Prometheus.
+	Headers map[string]string
+	// ServiceProxy accesses Prometheus through the service proxy of the apiserver, Address and the auth options
+	// above are ignored if it is set.
+	ServiceProxy *ServiceProxyConfig
+}
+
+type BasicAuthConfig struct {
+	Username string
+	// PasswordFile is the file of the password, e.g. mounted from a secret.
+	PasswordFile string
+}
+
+type TLSConfig struct {
+	// CAFile is the CA to verify Prometheus, the system CAs are used if it is empty.
+	CAFile string
+	// CertFile and KeyFile are the client certificate for mTLS.
+	CertFile   string
+	KeyFile    string
+	ServerName string
+	// InsecureSkipVerify disables the verification of the certificate of Prometheus.
+	InsecureSkipVerify bool
+}
+
+type ServiceProxyConfig struct {
+	// RestConfig is the config to access the apiserver.
+	RestConfig *rest.Config
+	Namespace  string
+	Name       string
+	// Scheme is http or https, http by default.
+	Scheme string
+	// Port is the name or number of the port of the service.
+	Port string
+}
+
+// MetricsAPI is the aggregated API serving metrics.
+type MetricsAPI string
+
+const (
+	// CustomMetricsAPI is custom.metrics.k8s.io, where metrics are described by pods.
+	CustomMetricsAPI MetricsAPI = "custom"
+	// ExternalMetricsAPI is external.metrics.k8s.io, where metrics keep their own labels.
+	ExternalMetricsAPI MetricsAPI = "external"
+)
+
+type CustomMetricsProviderConfig struct {
+	// RestConfig is the config to access the apiserver.
+	RestConfig *rest.Config
+	// API is the metrics API to query, external by default since it keeps the labels of series like pod_uid.
+	API MetricsAPI
+	// Namespace is the namespace of metrics queried without the PodNamespace filter, which is ignored by adapters
+	// serving non-namespaced metrics. Queries of rules are filtered by the namespaces of rules instead.
+	Namespace    string
+	QueryTimeout time.Duration
+}
+
+type NodeMetricProviderConfig struct {
+	// Reader reads NodeMetrics and pods, which is expected to be the cache of the manager.
+	Reader client.Reader
+	// MaxStaleness is the maximum age of NodeMetrics to use, older ones are regarded as koordlet is down.
+	MaxStaleness time.Duration
+}
+
+type IngestionProviderConfig struct {
+	// RestConfig is the config to review the service account tokens of clients.
+	RestConfig *rest.Config
+	// BindAddress is the address the ingestion endpoint binds to.
+	BindAddress string
+	// CertFile and KeyFile serve the endpoint with TLS, which are required since clients send bearer tokens.
+	CertFile string
+	KeyFile  string
+	// AllowedUsers are the users allowed to push samples, e.g.
+	// system:serviceaccount:koordinator-system:koordetector, which is required.
+	AllowedUsers []string
+	// Audiences are the audiences the tokens of clients are issued for, ingestion.DefaultAudience by default.
+	Audiences []string
+	// QueueSize is the number of batches buffered, clients are asked to retry later when the queue is full.
+	QueueSize int
+	// MaxBatchSamples is the maximum number of samples in a batch.
+	MaxBatchSamples int
+	// MaxSeries is the maximum number of series kept, batches of new series beyond it are rejected.
+	MaxSeries int
+	// Retention is how long samples are kept for range queries.
+	Retention time.Duration
+	// Staleness is the maximum age of the latest sample of a series to be returned by instant queries.
+	Staleness time.Duration
 }
@@ -0,0 +1,226 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package custommetrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	prommodel "github.com/prometheus/common/model"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/config"
+)
+
+const (
+	defaultNamespace    = "default"
+	defaultQueryTimeout = 10 * time.Second
+)
+
+type customMetricsProvider struct {
+	client       rest.Interface
+	api          config.MetricsAPI
+	namespace    string
+	queryTimeout time.Duration
+}
+
+// NewCustomMetricsProvider constructs a metric provider that gets data from the custom or external metrics API.
+// Only instant queries are supported, since the APIs serve the latest values only.
+func NewCustomMetricsProvider(cfg config.CustomMetricsProviderConfig) (*customMetricsProvider, error) {
+	if cfg.RestConfig == nil {
+		return nil, fmt.Errorf("rest config is required")
+	}
+	api := cfg.API
+	if api == "" {
+		api = config.ExternalMetricsAPI
+	}
+	var groupVersion string
+	switch api {
+	case config.CustomMetricsAPI:
+		groupVersion = customMetricsGroupVersion
+	case config.ExternalMetricsAPI:
+		groupVersion = externalMetricsGroupVersion
+	default:
+		return nil, fmt.Errorf("metrics api %v not supported", api)
+	}
+	gv, err := schema.ParseGroupVersion(groupVersion)
+	if err != nil {
+		return nil, err
+	}
+	restConfig := rest.CopyConfig(cfg.RestConfig)
+	restConfig.GroupVersion = &gv
+	restConfig.APIPath = "/apis"
+	restConfig.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+	client, err := rest.RESTClientFor(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return newCustomMetricsProvider(client, api, cfg.Namespace, cfg.QueryTimeout), nil
+}
+
+func newCustomMetricsProvider(client rest.Interface, api config.MetricsAPI, namespace string, queryTimeout time.Duration) *customMetricsProvider {
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	if queryTimeout <= 0 {
+		queryTimeout = defaultQueryTimeout
+	}
+	return &customMetricsProvider{
+		client:       client,
+		api:          api,
+		namespace:    namespace,
+		queryTimeout: queryTimeout,
+	}
+}
+
+func (p *customMetricsProvider) GetCPI(options common.MetricQueryOptions, labelFunc common.MakeLabelsFunc) ([]*common.Metric, error) {
+	if options.Level == common.WorkloadLevel {
+		return common.QueryWorkloadCPI(options, p.Query, labelFunc)
+	}
+	query, err := common.NewCPIQuery(options)
+	if err != nil {
+		return nil, err
+	}
+	labelFunc, err = common.CPILabelFunc(options, labelFunc)
+	if err != nil {
+		return nil, err
+	}
+	return p.Query(query, labelFunc)
+}
+
+func (p *customMetricsProvider) Query(query common.MetricQuery, labelFunc common.MakeLabelsFunc) ([]*common.Metric, error) {
+	return common.EvaluateQuery(query, p.getMetric, labelFunc)
+}
+
+func (p *customMetricsProvider) GetCPIRange(options common.MetricQueryOptions, labelFunc common.MakeLabelsFunc) ([]*common.MetricSeries, error) {
+	return nil, fmt.Errorf("range queries are not supported by the %v metrics api", p.api)
+}
+
+func (p *customMetricsProvider) QueryRange(query common.MetricQuery, timeRange common.TimeRange, labelFunc common.MakeLabelsFunc) ([]*common.MetricSeries, error) {
+	return nil, fmt.Errorf("range queries are not supported by the %v metrics api", p.api)
+}
+
+// CheckHealth gets the resources of the metrics API, which fails if the adapter serving it is unavailable.
+func (p *customMetricsProvider) CheckHealth(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, p.queryTimeout)
+	defer cancel()
+	if err := p.client.Get().Do(ctx).Error(); err != nil {
+		return fmt.Errorf("%v metrics api is unavailable: %v", p.api, err)
+	}
+	return nil
+}
+
+// getMetric gets the values of all series selected, with the labels of each series.
+func (p *customMetricsProvider) getMetric(selector common.MetricSelector) ([]common.LabeledValue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.queryTimeout)
+	defer cancel()
+
+	if p.api == config.CustomMetricsAPI {
+		return p.getCustomMetric(ctx, selector)
+	}
+	return p.getExternalMetric(ctx, selector)
+}
+
+// getExternalMetric gets the metric in the namespace of the PodNamespace filter, which adapters are expected to
+// map to the pod_namespace label of series, e.g. by the resource overrides of prometheus-adapter.
+func (p *customMetricsProvider) getExternalMetric(ctx context.Context, selector common.MetricSelector) ([]common.LabeledValue, error) {
+	namespace := p.namespace
+	if value, ok := selector.FilterLabels[common.PodNamespace]; ok {
+		namespace = value
+	}
+	raw, err := p.client.Get().
+		Namespace(namespace).
+		Resource(selector.MetricName).
+		Param("labelSelector", labels.SelectorFromSet(selector.FilterLabels).String()).
+		Do(ctx).
+		Raw()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get external metric %v: %v", selector.MetricName, err)
+	}
+	list := &externalMetricValueList{}
+	if err := json.Unmarshal(raw, list); err != nil {
+		return nil, fmt.Errorf("cannot decode external metric %v: %v", selector.MetricName, err)
+	}
+	filter := labels.SelectorFromSet(selector.FilterLabels)
+	result := make([]common.LabeledValue, 0, len(list.Items))
+	for i := range list.Items {
+		item := &list.Items[i]
+		// adapters may ignore the label selector of metrics they do not know how to filter
+		if !filter.Matches(labels.Set(item.MetricLabels)) {
+			continue
+		}
+		s := common.LabeledValue{Labels: prommodel.Metric{}, Value: item.Value.AsApproximateFloat64(), Timestamp: item.Timestamp.Time}
+		for name, value := range item.MetricLabels {
+			s.Labels[prommodel.LabelName(name)] = prommodel.LabelValue(value)
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// getCustomMetric gets the metric of pods, series are labeled with the namespaces and names of the pods since the
+// custom metrics API describes values by objects. The namespace of pods is overridden by the PodNamespace filter.
+// Values are aggregated by pods in the API, so series have no container labels and are taken as the ones of pods.
+func (p *customMetricsProvider) getCustomMetric(ctx context.Context, selector common.MetricSelector) ([]common.LabeledValue, error) {
+	namespace := p.namespace
+	metricLabels := labels.Set{}
+	for name, value := range selector.FilterLabels {
+		if name == common.PodNamespace {
+			namespace = value
+			continue
+		}
+		metricLabels[name] = value
+	}
+	raw, err := p.client.Get().
+		Namespace(namespace).
+		Resource("pods").
+		Name("*").
+		SubResource(selector.MetricName).
+		Param("metricLabelSelector", labels.SelectorFromSet(metricLabels).String()).
+		Do(ctx).
+		Raw()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get custom metric %v: %v", selector.MetricName, err)
+	}
+	list := &metricValueList{}
+	if err := json.Unmarshal(raw, list); err != nil {
+		return nil, fmt.Errorf("cannot decode custom metric %v: %v", selector.MetricName, err)
+	}
+	result := make([]common.LabeledValue, 0, len(list.Items))
+	for i := range list.Items {
+		item := &list.Items[i]
+		s := common.LabeledValue{Labels: prommodel.Metric{}, Value: item.Value.AsApproximateFloat64(), Timestamp: item.Timestamp.Time}
+		if item.Metric.Selector != nil {
+			for name, value := range item.Metric.Selector.MatchLabels {
+				s.Labels[prommodel.LabelName(name)] = prommodel.LabelValue(value)
+			}
+		}
+		s.Labels[prommodel.LabelName(common.PodNamespace)] = prommodel.LabelValue(item.DescribedObject.Namespace)
+		s.Labels[prommodel.LabelName(common.PodName)] = prommodel.LabelValue(item.DescribedObject.Name)
+		if item.DescribedObject.UID != "" {
+			s.Labels[prommodel.LabelName(common.PodUID)] = prommodel.LabelValue(item.DescribedObject.UID)
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
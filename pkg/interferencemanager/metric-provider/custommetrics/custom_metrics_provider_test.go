@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package custommetrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest/fake"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/config"
+)
+
+// newFakeClient returns a fake REST client of the metrics API which serves the responses by request path.
+func newFakeClient(groupVersion string, responses map[string]string, requests *[]*http.Request) *fake.RESTClient {
+	gv, _ := schema.ParseGroupVersion(groupVersion)
+	return &fake.RESTClient{
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		GroupVersion:         gv,
+		VersionedAPIPath:     "/apis/" + groupVersion,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			*requests = append(*requests, req)
+			body, ok := responses[req.URL.Path]
+			if !ok {
+				return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(bytes.NewBufferString(`{}`))}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			}, nil
+		}),
+	}
+}
+
+func TestExternalMetricsGetCPI(t *testing.T) {
+	series := func(cpiField, container string, value string) string {
+		return `{"metricName":"koordlet_container_cpi","metricLabels":{"cpi_field":"` + cpiField +
+			`","container_id":"c-` + container + `","container_name":"` + container +
+			`","pod_uid":"uid-1","pod_namespace":"default","pod_name":"web-1","node":"node-1"},` +
+			`"timestamp":"2022-06-01T00:00:00Z","value":"` + value + `"}`
+	}
+	var requests []*http.Request
+	client := newFakeClient(externalMetricsGroupVersion, map[string]string{
+		"/apis/external.metrics.k8s.io/v1beta1/namespaces/default/koordlet_container_cpi": `{"items":[` +
+			series("cycles", "main", "3000") + `,` + series("instructions", "main", "2000") + `,` +
+			series("cycles", "sidecar", "100") + `,` + series("instructions", "sidecar", "0") + `]}`,
+	}, &requests)
+	p := newCustomMetricsProvider(client, config.ExternalMetricsAPI, "", time.Second)
+
+	metrics, err := p.GetCPI(common.MetricQueryOptions{MetricName: common.KoordletContainerCPI}, nil)
+	assert.NoError(t, err)
+	// the sidecar without instructions is dropped
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, 1.5, metrics[0].Value)
+	assert.Equal(t, "main", metrics[0].Labels[common.ContainerName])
+	assert.Equal(t, "uid-1", metrics[0].Labels[common.PodUID])
+	assert.Len(t, requests, 2)
+	assert.Equal(t, "cpi_field=cycles", requests[0].URL.Query().Get("labelSelector"))
+	assert.Equal(t, "cpi_field=instructions", requests[1].URL.Query().Get("labelSelector"))
+
+	// the namespace of pods is queried
+	metrics, err = p.GetCPI(common.MetricQueryOptions{MetricName: common.KoordletContainerCPI,
+		FilterLabels: map[string]string{common.PodNamespace: "prod"}}, nil)
+	assert.Error(t, err)
+	assert.Nil(t, metrics)
+	assert.Equal(t, "/apis/external.metrics.k8s.io/v1beta1/namespaces/prod/koordlet_container_cpi", requests[2].URL.Path)
+
+	_, err = p.GetCPIRange(common.MetricQueryOptions{MetricName: common.KoordletContainerCPI}, nil)
+	assert.Error(t, err)
+	_, err = p.GetCPI(common.MetricQueryOptions{Level: common.WorkloadLevel}, nil)
+	assert.Error(t, err)
+}
+
+func TestCustomMetricsQuery(t *testing.T) {
+	value := func(pod, uid, v string) string {
+		return `{"describedObject":{"kind":"Pod","namespace":"prod","name":"` + pod + `","uid":"` + uid + `","apiVersion":"/v1"},` +
+			`"metric":{"name":"koordetector_container_cpu_schedule_latency_seconds"},"timestamp":"2022-06-01T00:00:00Z","value":"` + v + `"}`
+	}
+	var requests []*http.Request
+	client := newFakeClient(customMetricsGroupVersion, map[string]string{
+		"/apis/custom.metrics.k8s.io/v1beta2/namespaces/prod/pods/*/koordetector_container_cpu_schedule_latency_seconds": `{"items":[` +
+			value("web-1", "uid-1", "10m") + `,` + value("web-2", "uid-2", "30m") + `]}`,
+	}, &requests)
+	p := newCustomMetricsProvider(client, config.CustomMetricsAPI, "default", time.Second)
+
+	query := common.MetricQuery{
+		MetricSelector: common.MetricSelector{
+			MetricName:   "koordetector_container_cpu_schedule_latency_seconds",
+			FilterLabels: map[string]string{common.PodNamespace: "prod"},
+		},
+	}
+	metrics, err := p.Query(query, nil)
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 2)
+	assert.Equal(t, map[string]string{common.PodNamespace: "prod", common.PodName: "web-1", common.PodUID: "uid-1"}, metrics[0].Labels)
+	assert.InDelta(t, 0.01, metrics[0].Value, 1e-9)
+	assert.Equal(t, "", requests[0].URL.Query().Get("metricLabelSelector"))
+
+	query.GroupByLabels = []string{common.PodNamespace}
+	query.Aggregation = common.AggregationAvg
+	metrics, err = p.Query(query, nil)
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.InDelta(t, 0.02, metrics[0].Value, 1e-9)
+
+	// errors of the api are returned
+	query.MetricName = "unknown"
+	_, err = p.Query(query, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package custommetrics
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The types below are the subset of k8s.io/metrics/pkg/apis/{custom,external}_metrics used by the provider, which
+// are decoded from the responses of the aggregated APIs directly.
+
+const (
+	customMetricsGroupVersion   = "custom.metrics.k8s.io/v1beta2"
+	externalMetricsGroupVersion = "external.metrics.k8s.io/v1beta1"
+)
+
+// metricValueList is a list of values of a custom metric.
+type metricValueList struct {
+	Items []metricValue `json:"items"`
+}
+
+// metricValue is the value of a custom metric for an object.
+type metricValue struct {
+	DescribedObject corev1.ObjectReference `json:"describedObject"`
+	Metric          metricIdentifier       `json:"metric"`
+	Timestamp       metav1.Time            `json:"timestamp"`
+	Value           resource.Quantity      `json:"value"`
+}
+
+// metricIdentifier identifies a metric by name and optional selector.
+type metricIdentifier struct {
+	Name     string                `json:"name"`
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// externalMetricValueList is a list of values of an external metric.
+type externalMetricValueList struct {
+	Items []externalMetricValue `json:"items"`
+}
+
+// externalMetricValue is the value of a series of an external metric.
+type externalMetricValue struct {
+	MetricName   string            `json:"metricName"`
+	MetricLabels map[string]string `json:"metricLabels"`
+	Timestamp    metav1.Time       `json:"timestamp"`
+	Value        resource.Quantity `json:"value"`
+}
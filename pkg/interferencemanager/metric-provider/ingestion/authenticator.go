@@ -0,0 +1,152 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingestion
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	authenticationclient "k8s.io/client-go/kubernetes/typed/authentication/v1"
+)
+
+const (
+	// defaultTokenCacheTTL is how long the result of a token review is cached, so that clients pushing every few
+	// seconds do not review their tokens every time.
+	defaultTokenCacheTTL = time.Minute
+	// maxCachedTokens bounds the token cache, which is reset once it is full.
+	maxCachedTokens = 4096
+)
+
+// Authenticator authenticates the bearer tokens of clients and returns their user names.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (string, error)
+}
+
+// errUnauthorized is returned for tokens which are valid but whose users are not allowed to push samples.
+type errUnauthorized struct {
+	user string
+}
+
+func (e *errUnauthorized) Error() string {
+	return fmt.Sprintf("user %v is not allowed to push samples", e.user)
+}
+
+type cachedReview struct {
+	user     string
+	err      error
+	expireAt time.Time
+}
+
+// tokenReviewAuthenticator authenticates service account tokens with the TokenReview API.
+type tokenReviewAuthenticator struct {
+	client       authenticationclient.TokenReviewInterface
+	allowedUsers sets.String
+	audiences    []string
+	ttl          time.Duration
+
+	lock  sync.Mutex
+	cache map[[sha256.Size]byte]*cachedReview
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// NewTokenReviewAuthenticator constructs an Authenticator reviewing tokens issued for @audiences with the
+// TokenReview API. Only @allowedUsers are allowed, no one is allowed if it is empty.
+func NewTokenReviewAuthenticator(client authenticationclient.TokenReviewInterface, allowedUsers, audiences []string) Authenticator {
+	return &tokenReviewAuthenticator{
+		client:       client,
+		allowedUsers: sets.NewString(allowedUsers...),
+		audiences:    audiences,
+		ttl:          defaultTokenCacheTTL,
+		cache:        map[[sha256.Size]byte]*cachedReview{},
+		now:          time.Now,
+	}
+}
+
+func (a *tokenReviewAuthenticator) Authenticate(ctx context.Context, token string) (string, error) {
+	if token == "" {
+		return "", &errUnauthenticated{reason: "bearer token is required"}
+	}
+	// tokens are hashed so that they are not kept in memory
+	key := sha256.Sum256([]byte(token))
+	now := a.now()
+	a.lock.Lock()
+	cached, ok := a.cache[key]
+	a.lock.Unlock()
+	if ok && now.Before(cached.expireAt) {
+		return cached.user, cached.err
+	}
+
+	user, err := a.review(ctx, token)
+	// errors of the API server are not cached
+	if err == nil || isUnauthenticated(err) || isUnauthorized(err) {
+		a.lock.Lock()
+		if len(a.cache) >= maxCachedTokens {
+			a.cache = map[[sha256.Size]byte]*cachedReview{}
+		}
+		a.cache[key] = &cachedReview{user: user, err: err, expireAt: now.Add(a.ttl)}
+		a.lock.Unlock()
+	}
+	return user, err
+}
+
+// errUnauthenticated is returned for tokens rejected by the TokenReview API.
+type errUnauthenticated struct {
+	reason string
+}
+
+func (e *errUnauthenticated) Error() string {
+	return fmt.Sprintf("token is not authenticated: %v", e.reason)
+}
+
+func isUnauthenticated(err error) bool {
+	_, ok := err.(*errUnauthenticated)
+	return ok
+}
+
+func isUnauthorized(err error) bool {
+	_, ok := err.(*errUnauthorized)
+	return ok
+}
+
+func (a *tokenReviewAuthenticator) review(ctx context.Context, token string) (string, error) {
+	review, err := a.client.Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: a.audiences},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to review token: %v", err)
+	}
+	if !review.Status.Authenticated {
+		return "", &errUnauthenticated{reason: review.Status.Error}
+	}
+	// authenticators not supporting audiences ignore them, and return the audiences of the API server instead
+	if len(a.audiences) > 0 && !sets.NewString(review.Status.Audiences...).HasAny(a.audiences...) {
+		return "", &errUnauthenticated{reason: "token is not issued for the audiences " + strings.Join(a.audiences, ",")}
+	}
+	user := review.Status.User.Username
+	if !a.allowedUsers.Has(user) {
+		return user, &errUnauthorized{user: user}
+	}
+	return user, nil
+}
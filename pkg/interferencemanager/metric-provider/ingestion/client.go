@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingestion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	// DefaultTokenFile is the projected service account token issued for DefaultAudience.
+	DefaultTokenFile = "/var/run/secrets/interference-manager/token"
+)
+
+// ErrBackpressure is returned by Client.Push when the server asks to retry later, callers should keep the batch
+// and push it with the next one.
+var ErrBackpressure = fmt.Errorf("ingestion server is busy, retry later")
+
+// ErrRejected is wrapped by the errors of Client.Push when the server rejects the batch, e.g. it is malformed, too
+// large or not authorized. Retrying the same batch fails again, so callers should drop it.
+var ErrRejected = fmt.Errorf("ingestion server rejected the samples")
+
+// Client pushes SampleBatches to the ingestion endpoint of the interference manager.
+type Client struct {
+	url        string
+	tokenFile  string
+	httpClient *http.Client
+}
+
+// NewClient constructs a Client pushing to the server at @address, e.g. https://interference-manager:9091,
+// with the token in @tokenFile. The token is read on every push since service account tokens are rotated, and is
+// only sent over HTTPS.
+func NewClient(address, tokenFile string, httpClient *http.Client) (*Client, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %v: %v", address, err)
+	}
+	if tokenFile != "" && u.Scheme != "https" {
+		return nil, fmt.Errorf("address %v must be https to send the token", address)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		url:        strings.TrimSuffix(address, "/") + IngestPath,
+		tokenFile:  tokenFile,
+		httpClient: httpClient,
+	}, nil
+}
+
+// Push sends the batch to the server.
+func (c *Client) Push(ctx context.Context, batch *SampleBatch) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.tokenFile != "" {
+		token, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read token: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusAccepted, http.StatusOK:
+		return nil
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return ErrBackpressure
+	}
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	switch resp.StatusCode {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestEntityTooLarge:
+		return fmt.Errorf("%w, status %v: %s", ErrRejected, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return fmt.Errorf("failed to push samples, status %v: %s", resp.Status, strings.TrimSpace(string(message)))
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingestion
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	prommodel "github.com/prometheus/common/model"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+)
+
+const (
+	// maxRangePoints is the maximum number of points per series in a range query.
+	maxRangePoints = 11000
+)
+
+func (s *Server) GetCPI(options common.MetricQueryOptions, labelFunc common.MakeLabelsFunc) ([]*common.Metric, error) {
+	if options.Level == common.WorkloadLevel {
+		return common.QueryWorkloadCPI(options, s.Query, labelFunc)
+	}
+	query, err := common.NewCPIQuery(options)
+	if err != nil {
+		return nil, err
+	}
+	labelFunc, err = common.CPILabelFunc(options, labelFunc)
+	if err != nil {
+		return nil, err
+	}
+	return s.Query(query, labelFunc)
+}
+
+// Query evaluates @query on the latest samples received which are no older than the staleness.
+func (s *Server) Query(query common.MetricQuery, labelFunc common.MakeLabelsFunc) ([]*common.Metric, error) {
+	return s.queryAt(query, s.now(), labelFunc)
+}
+
+func (s *Server) GetCPIRange(options common.MetricQueryOptions, labelFunc common.MakeLabelsFunc) ([]*common.MetricSeries, error) {
+	if options.Range == nil {
+		return nil, fmt.Errorf("time range is required for range queries")
+	}
+	if options.Level == common.WorkloadLevel {
+		return common.QueryWorkloadCPIRange(options, s.QueryRange, labelFunc)
+	}
+	query, err := common.NewCPIQuery(options)
+	if err != nil {
+		return nil, err
+	}
+	labelFunc, err = common.CPILabelFunc(options, labelFunc)
+	if err != nil {
+		return nil, err
+	}
+	return s.QueryRange(query, *options.Range, labelFunc)
+}
+
+// QueryRange evaluates @query at every step of @timeRange like Prometheus does, so only samples within the
+// retention are served.
+func (s *Server) QueryRange(query common.MetricQuery, timeRange common.TimeRange, labelFunc common.MakeLabelsFunc) ([]*common.MetricSeries, error) {
+	if err := validateTimeRange(timeRange); err != nil {
+		return nil, err
+	}
+	if labelFunc == nil {
+		labelFunc = common.MakeAllLabels
+	}
+	// evaluate with raw labels, so that series are identified before labelFunc drops any label
+	seriesByLabels := map[string]*common.MetricSeries{}
+	var keys []string
+	for t := timeRange.Start; !t.After(timeRange.End); t = t.Add(timeRange.Step) {
+		metrics, err := s.queryAt(query, t, common.MakeAllLabels)
+		if err != nil {
+			return nil, err
+		}
+		for _, metric := range metrics {
+			key := labelsKey(metric.Labels)
+			series, ok := seriesByLabels[key]
+			if !ok {
+				rawLabels := prommodel.Metric{}
+				for name, value := range metric.Labels {
+					rawLabels[prommodel.LabelName(name)] = prommodel.LabelValue(value)
+				}
+				labels, err := labelFunc(rawLabels)
+				if err != nil {
+					return nil, err
+				}
+				series = &common.MetricSeries{Labels: labels}
+				seriesByLabels[key] = series
+				keys = append(keys, key)
+			}
+			series.Samples = append(series.Samples, common.Sample{Timestamp: t, Value: metric.Value})
+		}
+	}
+	sort.Strings(keys)
+	result := make([]*common.MetricSeries, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, seriesByLabels[key])
+	}
+	return result, nil
+}
+
+func (s *Server) queryAt(query common.MetricQuery, t time.Time, labelFunc common.MakeLabelsFunc) ([]*common.Metric, error) {
+	return common.EvaluateQuery(query, func(selector common.MetricSelector) ([]common.LabeledValue, error) {
+		return s.store.valuesAt(selector, t, s.staleness), nil
+	}, labelFunc)
+}
+
+func validateTimeRange(timeRange common.TimeRange) error {
+	if timeRange.Step <= 0 {
+		return fmt.Errorf("step of time range must be positive, got %v", timeRange.Step)
+	}
+	if timeRange.End.Before(timeRange.Start) {
+		return fmt.Errorf("end of time range %v is before start %v", timeRange.End, timeRange.Start)
+	}
+	if points := int64(timeRange.End.Sub(timeRange.Start) / timeRange.Step); points > maxRangePoints {
+		return fmt.Errorf("time range has %d points, exceeds the limit %d", points, maxRangePoints)
+	}
+	return nil
+}
+
+func labelsKey(labels map[string]string) string {
+	metric := prommodel.Metric{}
+	for name, value := range labels {
+		metric[prommodel.LabelName(name)] = prommodel.LabelValue(value)
+	}
+	return metric.String()
+}
@@ -0,0 +1,268 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingestion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/config"
+)
+
+const (
+	defaultQueueSize = 64
+	// DefaultMaxBatchSamples is the default limit of samples in a batch, larger batches are rejected.
+	DefaultMaxBatchSamples = 10000
+	defaultMaxSeries       = 100000
+	defaultRetention       = time.Hour
+	defaultStaleness       = 5 * time.Minute
+	// maxBatchBytes bounds the size of a request body.
+	maxBatchBytes = 8 << 20
+	// maxDrainBatches is the maximum number of batches appended to the store at once.
+	maxDrainBatches = 16
+	// retryAfterSeconds is the Retry-After header sent to clients when the queue is full.
+	retryAfterSeconds = "1"
+	gcInterval        = time.Minute
+	// maxFutureSkew is how far samples may be ahead of the clock of the server, for the clock skew of nodes.
+	maxFutureSkew   = time.Minute
+	shutdownTimeout = 10 * time.Second
+)
+
+var ingestionLog = ctrl.Log.WithName("ingestion")
+
+// Server receives the per-container samples pushed by koordetector daemons with POST on IngestPath, and serves
+// them as a MetricProvider. Requests are authenticated by the service account tokens of the daemons, and are
+// queued for a single writer, so that clients are asked to retry later with 429 instead of piling up
+// when the store is busy.
+//
+// Server listens on every replica regardless of leader election, so that the Service in front of it always has
+// endpoints. Samples are only evaluated by the controllers of the leader though, so the Service must route to the
+// leader: run the manager in a single replica, which is the default, when the ingestion provider is used.
+type Server struct {
+	bindAddress     string
+	certFile        string
+	keyFile         string
+	maxBatchSamples int
+	staleness       time.Duration
+
+	authenticator Authenticator
+	queue         chan *SampleBatch
+	store         *seriesStore
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// NewIngestionServer constructs a Server authenticating clients with the TokenReview API.
+func NewIngestionServer(cfg config.IngestionProviderConfig) (*Server, error) {
+	if cfg.RestConfig == nil {
+		return nil, fmt.Errorf("rest config is required")
+	}
+	if cfg.BindAddress == "" {
+		return nil, fmt.Errorf("bind address is required")
+	}
+	// clients send their bearer tokens, which must not be sent in plain HTTP
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, fmt.Errorf("cert file and key file are required")
+	}
+	if len(cfg.AllowedUsers) == 0 {
+		return nil, fmt.Errorf("allowed users are required")
+	}
+	audiences := cfg.Audiences
+	if len(audiences) == 0 {
+		audiences = []string{DefaultAudience}
+	}
+	kubeClient, err := kubernetes.NewForConfig(cfg.RestConfig)
+	if err != nil {
+		return nil, err
+	}
+	return newServer(cfg, NewTokenReviewAuthenticator(kubeClient.AuthenticationV1().TokenReviews(),
+		cfg.AllowedUsers, audiences)), nil
+}
+
+func newServer(cfg config.IngestionProviderConfig, authenticator Authenticator) *Server {
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	maxBatchSamples := cfg.MaxBatchSamples
+	if maxBatchSamples <= 0 {
+		maxBatchSamples = DefaultMaxBatchSamples
+	}
+	maxSeries := cfg.MaxSeries
+	if maxSeries <= 0 {
+		maxSeries = defaultMaxSeries
+	}
+	retention := cfg.Retention
+	if retention <= 0 {
+		retention = defaultRetention
+	}
+	staleness := cfg.Staleness
+	if staleness <= 0 {
+		staleness = defaultStaleness
+	}
+	return &Server{
+		bindAddress:     cfg.BindAddress,
+		certFile:        cfg.CertFile,
+		keyFile:         cfg.KeyFile,
+		maxBatchSamples: maxBatchSamples,
+		staleness:       staleness,
+		authenticator:   authenticator,
+		queue:           make(chan *SampleBatch, queueSize),
+		store:           newSeriesStore(retention, maxSeries),
+		now:             time.Now,
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the ingestion endpoint until the context is done.
+func (s *Server) Start(ctx context.Context) error {
+	go s.run(ctx)
+
+	mux := http.NewServeMux()
+	mux.Handle(IngestPath, s)
+	server := &http.Server{
+		Addr:              s.bindAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		ingestionLog.Info("serving ingestion endpoint", "address", s.bindAddress)
+		err := server.ListenAndServeTLS(s.certFile, s.keyFile)
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("ingestion server stopped: %v", err)
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP accepts a SampleBatch in JSON.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user, err := s.authenticator.Authenticate(r.Context(), bearerToken(r))
+	if err != nil {
+		switch {
+		case isUnauthorized(err):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case isUnauthenticated(err):
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		default:
+			// the token can not be reviewed for now, clients retry later
+			w.Header().Set("Retry-After", retryAfterSeconds)
+			http.Error(w, "failed to authenticate", http.StatusServiceUnavailable)
+		}
+		ingestionLog.V(4).Info("rejected samples", "user", user, "reason", err.Error())
+		return
+	}
+
+	batch := &SampleBatch{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBytes)).Decode(batch); err != nil {
+		http.Error(w, fmt.Sprintf("invalid sample batch: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(batch.Samples) > s.maxBatchSamples {
+		http.Error(w, fmt.Sprintf("batch has %d samples, exceeds the limit %d", len(batch.Samples), s.maxBatchSamples),
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !s.store.canAppend(batch) {
+		// series are dropped after the retention
+		w.Header().Set("Retry-After", retryAfterSeconds)
+		http.Error(w, "too many series, retry later", http.StatusTooManyRequests)
+		return
+	}
+	select {
+	case s.queue <- batch:
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.Header().Set("Retry-After", retryAfterSeconds)
+		http.Error(w, "too many samples, retry later", http.StatusTooManyRequests)
+	}
+}
+
+// run appends the queued batches into the store until the context is done.
+func (s *Server) run(ctx context.Context) {
+	gcTicker := time.NewTicker(gcInterval)
+	defer gcTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-gcTicker.C:
+			s.store.gc(s.now())
+		case batch := <-s.queue:
+			s.appendBatches(s.drain(batch))
+		}
+	}
+}
+
+// drain takes the batches queued after @first, so that they are appended with the lock held once.
+func (s *Server) drain(first *SampleBatch) []*SampleBatch {
+	batches := []*SampleBatch{first}
+	for len(batches) < maxDrainBatches {
+		select {
+		case batch := <-s.queue:
+			batches = append(batches, batch)
+		default:
+			return batches
+		}
+	}
+	return batches
+}
+
+func (s *Server) appendBatches(batches []*SampleBatch) {
+	if dropped := s.store.append(batches, s.now()); dropped > 0 {
+		ingestionLog.V(5).Info("dropped out-of-order, expired, future or excess samples", "count", dropped)
+	}
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(auth[len(prefix):])
+}
@@ -0,0 +1,263 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingestion
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	prommodel "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/config"
+)
+
+type fakeAuthenticator map[string]string
+
+func (a fakeAuthenticator) Authenticate(ctx context.Context, token string) (string, error) {
+	user, ok := a[token]
+	if !ok {
+		return "", &errUnauthenticated{reason: "unknown token"}
+	}
+	return user, nil
+}
+
+func newTestClient(t *testing.T, server *httptest.Server, token string) *Client {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte(token+"\n"), 0600))
+	client, err := NewClient(server.URL, tokenFile, server.Client())
+	assert.NoError(t, err)
+	return client
+}
+
+func containerSample(podUID, container string, value float64, ts time.Time) Sample {
+	return Sample{
+		Metric:      common.KoordletContainerCPI,
+		Labels:      map[string]string{common.PodUID: podUID, common.ContainerName: container, "cpi_field": "cycles"},
+		Value:       value,
+		TimestampMs: ts.UnixMilli(),
+	}
+}
+
+func TestServerIngest(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	server := newServer(config.IngestionProviderConfig{QueueSize: 1, Staleness: time.Minute},
+		fakeAuthenticator{"valid": "system:serviceaccount:koordinator-system:koordetector"})
+	server.now = func() time.Time { return now }
+	httpServer := httptest.NewTLSServer(server)
+	defer httpServer.Close()
+
+	batch := &SampleBatch{
+		Node: "node-1",
+		Samples: []Sample{
+			containerSample("uid-1", "app", 100, now.Add(-2*time.Minute)),
+			containerSample("uid-1", "app", 200, now.Add(-30*time.Second)),
+			containerSample("uid-1", "sidecar", 50, now.Add(-30*time.Second)),
+			// out of order
+			containerSample("uid-1", "sidecar", 10, now.Add(-40*time.Second)),
+		},
+	}
+	ctx := context.Background()
+	err := newTestClient(t, httpServer, "invalid").Push(ctx, batch)
+	assert.True(t, errors.Is(err, ErrRejected), "unauthorized batches are rejected")
+	client := newTestClient(t, httpServer, "valid")
+	assert.NoError(t, client.Push(ctx, batch))
+	// the queue is full until the batch is appended
+	assert.Equal(t, ErrBackpressure, client.Push(ctx, batch))
+
+	server.appendBatches(server.drain(<-server.queue))
+	assert.Equal(t, 2, server.store.seriesCount())
+
+	query := common.MetricQuery{
+		MetricSelector: common.MetricSelector{
+			MetricName:   common.KoordletContainerCPI,
+			FilterLabels: map[string]string{"cpi_field": "cycles"},
+		},
+		GroupByLabels: []string{common.PodUID, common.Node},
+	}
+	metrics, err := server.Query(query, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []*common.Metric{
+		{Labels: map[string]string{common.PodUID: "uid-1", common.Node: "node-1"}, Value: 250,
+			Timestamp: time.UnixMilli(now.Add(-30 * time.Second).UnixMilli())},
+	}, metrics)
+
+	// matchers are evaluated on the received series
+	matcherQuery := query
+	matcherQuery.Matchers = []common.LabelMatcher{{Name: common.ContainerName, Type: common.MatchNotRegexp, Value: "side.*"}}
+	metrics, err = server.Query(matcherQuery, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []*common.Metric{
+		{Labels: map[string]string{common.PodUID: "uid-1", common.Node: "node-1"}, Value: 200,
+			Timestamp: time.UnixMilli(now.Add(-30 * time.Second).UnixMilli())},
+	}, metrics)
+
+	series, err := server.QueryRange(query, common.TimeRange{
+		Start: now.Add(-2 * time.Minute),
+		End:   now,
+		Step:  time.Minute,
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []*common.MetricSeries{
+		{
+			Labels: map[string]string{common.PodUID: "uid-1", common.Node: "node-1"},
+			Samples: []common.Sample{
+				{Timestamp: now.Add(-2 * time.Minute), Value: 100},
+				// the sample at -2m is still fresh
+				{Timestamp: now.Add(-time.Minute), Value: 100},
+				{Timestamp: now, Value: 250},
+			},
+		},
+	}, series)
+
+	_, err = server.QueryRange(query, common.TimeRange{Start: now, End: now}, nil)
+	assert.Error(t, err)
+
+	// samples out of the retention are collected
+	server.store.gc(now.Add(2 * time.Hour))
+	assert.Equal(t, 0, server.store.seriesCount())
+}
+
+func TestServerRejectsLargeBatch(t *testing.T) {
+	now := time.Now()
+	server := newServer(config.IngestionProviderConfig{MaxBatchSamples: 1}, fakeAuthenticator{"valid": "user"})
+	httpServer := httptest.NewTLSServer(server)
+	defer httpServer.Close()
+
+	err := newTestClient(t, httpServer, "valid").Push(context.Background(), &SampleBatch{
+		Samples: []Sample{containerSample("uid-1", "app", 1, now), containerSample("uid-1", "app", 2, now)},
+	})
+	assert.True(t, errors.Is(err, ErrRejected), "large batches are rejected")
+	assert.Equal(t, 0, len(server.queue))
+}
+
+func TestServerLimitsSeries(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	server := newServer(config.IngestionProviderConfig{MaxSeries: 2}, fakeAuthenticator{"valid": "user"})
+	server.now = func() time.Time { return now }
+	httpServer := httptest.NewTLSServer(server)
+	defer httpServer.Close()
+	client := newTestClient(t, httpServer, "valid")
+	ctx := context.Background()
+
+	spoofed := containerSample("uid-1", "app", 1, now)
+	spoofed.Labels[common.Node] = "node-2"
+	assert.NoError(t, client.Push(ctx, &SampleBatch{
+		Node: "node-1",
+		Samples: []Sample{
+			spoofed,
+			// too far in the future
+			containerSample("uid-2", "app", 1, now.Add(time.Hour)),
+		},
+	}))
+	server.appendBatches(server.drain(<-server.queue))
+	assert.Equal(t, 1, server.store.seriesCount())
+	values := server.store.valuesAt(common.MetricSelector{MetricName: common.KoordletContainerCPI}, now, time.Minute)
+	if assert.Len(t, values, 1) {
+		// the node of the batch overrides the one of the sample
+		assert.Equal(t, "node-1", string(values[0].Labels[prommodel.LabelName(common.Node)]))
+	}
+
+	// new series beyond the limit are rejected, while existing series are still accepted
+	assert.Equal(t, ErrBackpressure, client.Push(ctx, &SampleBatch{
+		Node:    "node-1",
+		Samples: []Sample{containerSample("uid-3", "app", 1, now), containerSample("uid-4", "app", 1, now)},
+	}))
+	assert.NoError(t, client.Push(ctx, &SampleBatch{
+		Node:    "node-1",
+		Samples: []Sample{containerSample("uid-1", "app", 2, now.Add(time.Second)), containerSample("uid-3", "app", 1, now)},
+	}))
+	server.appendBatches(server.drain(<-server.queue))
+	assert.Equal(t, 2, server.store.seriesCount())
+
+	// series appended concurrently beyond the limit are dropped
+	assert.Equal(t, 1, server.store.append([]*SampleBatch{
+		{Node: "node-1", Samples: []Sample{containerSample("uid-5", "app", 1, now)}},
+	}, now))
+	assert.Equal(t, 2, server.store.seriesCount())
+}
+
+func TestTokenReviewAuthenticator(t *testing.T) {
+	kubeClient := kubefake.NewSimpleClientset()
+	reviews := 0
+	kubeClient.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview).DeepCopy()
+		switch review.Spec.Token {
+		case "koordetector":
+			review.Status.Authenticated = true
+			review.Status.User.Username = "system:serviceaccount:koordinator-system:koordetector"
+			review.Status.Audiences = review.Spec.Audiences
+		case "other":
+			review.Status.Authenticated = true
+			review.Status.User.Username = "system:serviceaccount:default:other"
+			review.Status.Audiences = review.Spec.Audiences
+		case "apiserver":
+			// tokens issued for the API server
+			review.Status.Authenticated = true
+			review.Status.User.Username = "system:serviceaccount:koordinator-system:koordetector"
+			review.Status.Audiences = []string{"https://kubernetes.default.svc"}
+		default:
+			review.Status.Error = "invalid token"
+		}
+		return true, review, nil
+	})
+	authenticator := NewTokenReviewAuthenticator(kubeClient.AuthenticationV1().TokenReviews(),
+		[]string{"system:serviceaccount:koordinator-system:koordetector"}, []string{DefaultAudience})
+	ctx := context.Background()
+
+	user, err := authenticator.Authenticate(ctx, "koordetector")
+	assert.NoError(t, err)
+	assert.Equal(t, "system:serviceaccount:koordinator-system:koordetector", user)
+	_, err = authenticator.Authenticate(ctx, "koordetector")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reviews, "reviews should be cached")
+
+	_, err = authenticator.Authenticate(ctx, "other")
+	assert.True(t, isUnauthorized(err))
+	_, err = authenticator.Authenticate(ctx, "invalid")
+	assert.True(t, isUnauthenticated(err))
+	_, err = authenticator.Authenticate(ctx, "apiserver")
+	assert.True(t, isUnauthenticated(err))
+	_, err = authenticator.Authenticate(ctx, "")
+	assert.True(t, isUnauthenticated(err))
+	assert.Equal(t, 4, reviews)
+
+	// no one is allowed without allowed users
+	authenticator = NewTokenReviewAuthenticator(kubeClient.AuthenticationV1().TokenReviews(), nil, []string{DefaultAudience})
+	_, err = authenticator.Authenticate(ctx, "koordetector")
+	assert.True(t, isUnauthorized(err))
+}
+
+func TestNewClient(t *testing.T) {
+	_, err := NewClient("http://interference-manager:9091", DefaultTokenFile, nil)
+	assert.Error(t, err)
+	_, err = NewClient("https://interference-manager:9091", DefaultTokenFile, nil)
+	assert.NoError(t, err)
+	_, err = NewClient("http://localhost:9091", "", nil)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingestion
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	prommodel "github.com/prometheus/common/model"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+)
+
+// seriesStore keeps the samples received within the retention of every series, up to maxSeries series.
+type seriesStore struct {
+	lock      sync.RWMutex
+	series    map[string]*storedSeries
+	retention time.Duration
+	maxSeries int
+}
+
+type storedSeries struct {
+	metric string
+	labels prommodel.Metric
+	// samples are ordered by timestamp.
+	samples []common.Sample
+}
+
+func newSeriesStore(retention time.Duration, maxSeries int) *seriesStore {
+	return &seriesStore{
+		series:    map[string]*storedSeries{},
+		retention: retention,
+		maxSeries: maxSeries,
+	}
+}
+
+// seriesLabelsOf returns the labels of the series of the sample. The node label is always the node of the batch,
+// which is authenticated, so that a client can not write the series of other nodes.
+func seriesLabelsOf(sample *Sample, node string) prommodel.Metric {
+	seriesLabels := prommodel.Metric{}
+	for name, value := range sample.Labels {
+		seriesLabels[prommodel.LabelName(name)] = prommodel.LabelValue(value)
+	}
+	delete(seriesLabels, prommodel.LabelName(common.Node))
+	if node != "" {
+		seriesLabels[prommodel.LabelName(common.Node)] = prommodel.LabelValue(node)
+	}
+	return seriesLabels
+}
+
+// canAppend returns whether the new series of the batch fit in the store.
+func (s *seriesStore) canAppend(batch *SampleBatch) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	newSeries := map[string]struct{}{}
+	for i := range batch.Samples {
+		sample := &batch.Samples[i]
+		key := sample.Metric + seriesLabelsOf(sample, batch.Node).String()
+		if _, ok := s.series[key]; !ok {
+			newSeries[key] = struct{}{}
+		}
+	}
+	return len(s.series)+len(newSeries) <= s.maxSeries
+}
+
+// append adds the samples of the batches. Samples older than the latest one of their series, out of the retention,
+// more than maxFutureSkew ahead of @now, or of new series beyond maxSeries are dropped. It returns the number of
+// samples dropped.
+func (s *seriesStore) append(batches []*SampleBatch, now time.Time) int {
+	oldest := now.Add(-s.retention)
+	latest := now.Add(maxFutureSkew)
+	dropped := 0
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, batch := range batches {
+		for i := range batch.Samples {
+			sample := &batch.Samples[i]
+			timestamp := time.UnixMilli(sample.TimestampMs)
+			if sample.Metric == "" || timestamp.Before(oldest) || timestamp.After(latest) {
+				dropped++
+				continue
+			}
+			seriesLabels := seriesLabelsOf(sample, batch.Node)
+			key := sample.Metric + seriesLabels.String()
+			series, ok := s.series[key]
+			if !ok {
+				if len(s.series) >= s.maxSeries {
+					dropped++
+					continue
+				}
+				series = &storedSeries{metric: sample.Metric, labels: seriesLabels}
+				s.series[key] = series
+			}
+			if n := len(series.samples); n > 0 && !timestamp.After(series.samples[n-1].Timestamp) {
+				dropped++
+				continue
+			}
+			series.samples = append(series.samples, common.Sample{Timestamp: timestamp, Value: sample.Value})
+		}
+	}
+	return dropped
+}
+
+// gc drops the samples out of the retention, and the series without samples.
+func (s *seriesStore) gc(now time.Time) {
+	oldest := now.Add(-s.retention)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for key, series := range s.series {
+		i := sort.Search(len(series.samples), func(i int) bool {
+			return !series.samples[i].Timestamp.Before(oldest)
+		})
+		if i == len(series.samples) {
+			delete(s.series, key)
+			continue
+		}
+		if i > 0 {
+			series.samples = append([]common.Sample(nil), series.samples[i:]...)
+		}
+	}
+}
+
+// valuesAt gets the value at @t of every series selected, which is the latest sample no later than @t and no
+// older than @staleness.
+func (s *seriesStore) valuesAt(selector common.MetricSelector, t time.Time, staleness time.Duration) []common.LabeledValue {
+	filter := labels.SelectorFromSet(selector.FilterLabels)
+	freshSince := t.Add(-staleness)
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	var result []common.LabeledValue
+	for _, series := range s.series {
+		if series.metric != selector.MetricName || !filter.Matches(labelSet(series.labels)) {
+			continue
+		}
+		// index of the first sample after t
+		i := sort.Search(len(series.samples), func(i int) bool {
+			return series.samples[i].Timestamp.After(t)
+		})
+		if i == 0 || series.samples[i-1].Timestamp.Before(freshSince) {
+			continue
+		}
+		latest := series.samples[i-1]
+		result = append(result, common.LabeledValue{Labels: series.labels, Value: latest.Value, Timestamp: latest.Timestamp})
+	}
+	return result
+}
+
+// seriesCount returns the number of series in the store.
+func (s *seriesStore) seriesCount() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return len(s.series)
+}
+
+func labelSet(metric prommodel.Metric) labels.Set {
+	set := labels.Set{}
+	for name, value := range metric {
+		set[string(name)] = string(value)
+	}
+	return set
+}
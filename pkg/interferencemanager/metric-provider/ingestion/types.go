@@ -0,0 +1,41 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingestion
+
+const (
+	// IngestPath is the path of the endpoint receiving SampleBatches in JSON with POST.
+	IngestPath = "/api/v1/samples"
+	// DefaultAudience is the audience of the tokens clients push samples with, which are expected to be projected
+	// service account tokens issued for it, so that they can not be replayed to the API server.
+	DefaultAudience = "interference-manager"
+)
+
+// SampleBatch is a batch of samples pushed by the koordetector daemon on a node.
+type SampleBatch struct {
+	// Node is the node where the samples are collected, which is added to samples as the node label.
+	Node    string   `json:"node"`
+	Samples []Sample `json:"samples"`
+}
+
+// Sample is a value of a series, e.g. the CPI of a container.
+type Sample struct {
+	Metric string            `json:"metric"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+	// TimestampMs is the unix timestamp in milliseconds when the sample is collected.
+	TimestampMs int64 `json:"timestampMs"`
+}
@@ -17,15 +17,32 @@ limitations under the License.
 package metric_provider
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
 	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/config"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/custommetrics"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/ingestion"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/nodemetric"
 	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/prometheus"
 )
 
 type MetricProvider interface {
 	GetCPI(options common.MetricQueryOptions, labelFunc common.MakeLabelsFunc) ([]*common.Metric, error)
+	// Query returns the current values of any metric described by @query, labels of results are made by
+	// @labelFunc, or kept as is if it is nil.
+	Query(query common.MetricQuery, labelFunc common.MakeLabelsFunc) ([]*common.Metric, error)
+	// GetCPIRange returns the CPI series within options.Range, which is required.
+	GetCPIRange(options common.MetricQueryOptions, labelFunc common.MakeLabelsFunc) ([]*common.MetricSeries, error)
+	// QueryRange returns the series of any metric described by @query within @timeRange.
+	QueryRange(query common.MetricQuery, timeRange common.TimeRange, labelFunc common.MakeLabelsFunc) ([]*common.MetricSeries, error)
+}
+
+// HealthChecker is implemented by providers whose sources may be unreachable, e.g. a remote Prometheus.
+type HealthChecker interface {
+	// CheckHealth returns an error if the source of metrics can not be reached.
+	CheckHealth(ctx context.Context) error
 }
 
 func NewMetricsProvider(config config.MetricProviderConfig) (MetricProvider, error) {
@@ -36,6 +53,25 @@ func NewMetricsProvider(config config.MetricProviderConfig) (MetricProvider, err
 			return nil, err
 		}
 		return provider, nil
+	case common.CustomMetricsProvider:
+		provider, err := custommetrics.NewCustomMetricsProvider(config.CustomMetricsConf)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	case common.NodeMetricProvider:
+		provider, err := nodemetric.NewNodeMetricProvider(config.NodeMetricConf)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	case common.IngestionProvider:
+		// the server serves nothing until it is added to the manager
+		provider, err := ingestion.NewIngestionServer(config.IngestionConf)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
 	}
 	return nil, fmt.Errorf("metric provider does not support type: %v", config.ProviderType)
 }
@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	subsystem = "interference_manager_metric_provider"
+
+	ProviderKey  = "provider"
+	ResultKey    = "result"
+	QueryTypeKey = "query_type"
+	StatusKey    = "status"
+	ReasonKey    = "reason"
+
+	// CacheHit is a query served from the cache.
+	CacheHit = "hit"
+	// CacheMiss is a query sent to the source.
+	CacheMiss = "miss"
+	// CacheShared is a query waiting for an identical query in flight.
+	CacheShared = "shared"
+
+	InstantQuery = "instant"
+	RangeQuery   = "range"
+
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+
+	// NonFiniteSample is a NaN or Inf sample, e.g. a ratio divided by zero.
+	NonFiniteSample = "non_finite"
+)
+
+var (
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "cache_requests_total",
+		Help:      "Number of queries to the metric provider by the result of the cache, which is hit, miss or shared.",
+	}, []string{ProviderKey, ResultKey})
+
+	QueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: subsystem,
+		Name:      "query_duration_seconds",
+		Help:      "Latency of the queries sent to the source of the metric provider.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{ProviderKey, QueryTypeKey, StatusKey})
+
+	DroppedSamples = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "dropped_samples_total",
+		Help:      "Number of samples returned by the source but dropped by the metric provider.",
+	}, []string{ProviderKey, ReasonKey})
+)
+
+func init() {
+	metrics.Registry.MustRegister(CacheRequests, QueryDuration, DroppedSamples)
+}
+
+func RecordCacheRequest(provider, result string) {
+	CacheRequests.WithLabelValues(provider, result).Inc()
+}
+
+func RecordQueryDuration(provider, queryType string, err error, duration time.Duration) {
+	status := StatusSucceeded
+	if err != nil {
+		status = StatusFailed
+	}
+	QueryDuration.WithLabelValues(provider, queryType, status).Observe(duration.Seconds())
+}
+
+func RecordDroppedSamples(provider, reason string, count int) {
+	DroppedSamples.WithLabelValues(provider, reason).Add(float64(count))
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodemetric
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	slov1alpha1 "github.com/koordinator-sh/koordinator/apis/slo/v1alpha1"
+	prommodel "github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/config"
+)
+
+const (
+	defaultMaxStaleness = 5 * time.Minute
+)
+
+type nodeMetricProvider struct {
+	reader       client.Reader
+	maxStaleness time.Duration
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// NewNodeMetricProvider contructs a metric provider that gets the resource usages in NodeMetrics reported by
+// koordlet, so that clusters without Prometheus can run interference detection. NodeMetrics and pods are read by
+// the reader shared with controllers, and the uids of pods are attached to series by their names.
+//
+// NodeMetrics report the usages of pods only, so series are labeled with pods rather than containers, and rules
+// evaluate them per pod. CPI is not reported.
+func NewNodeMetricProvider(cfg config.NodeMetricProviderConfig) (*nodeMetricProvider, error) {
+	if cfg.Reader == nil {
+		return nil, fmt.Errorf("reader is required")
+	}
+	return newNodeMetricProvider(cfg.Reader, cfg.MaxStaleness), nil
+}
+
+func newNodeMetricProvider(reader client.Reader, maxStaleness time.Duration) *nodeMetricProvider {
+	if maxStaleness <= 0 {
+		maxStaleness = defaultMaxStaleness
+	}
+	return &nodeMetricProvider{
+		reader:       reader,
+		maxStaleness: maxStaleness,
+		now:          time.Now,
+	}
+}
+
+func (p *nodeMetricProvider) GetCPI(options common.MetricQueryOptions, labelFunc common.MakeLabelsFunc) ([]*common.Metric, error) {
+	return nil, fmt.Errorf("cpi is not reported in node metrics, use %v or %v instead",
+		common.NodeMetricPodCPUUsage, common.NodeMetricPodMemoryUsage)
+}
+
+func (p *nodeMetricProvider) Query(query common.MetricQuery, labelFunc common.MakeLabelsFunc) ([]*common.Metric, error) {
+	return common.EvaluateQuery(query, p.getMetric, labelFunc)
+}
+
+func (p *nodeMetricProvider) GetCPIRange(options common.MetricQueryOptions, labelFunc common.MakeLabelsFunc) ([]*common.MetricSeries, error) {
+	return nil, fmt.Errorf("cpi is not reported in node metrics, use %v or %v instead",
+		common.NodeMetricPodCPUUsage, common.NodeMetricPodMemoryUsage)
+}
+
+func (p *nodeMetricProvider) QueryRange(query common.MetricQuery, timeRange common.TimeRange, labelFunc common.MakeLabelsFunc) ([]*common.MetricSeries, error) {
+	return nil, fmt.Errorf("range queries are not supported by node metrics, which keep the latest usages only")
+}
+
+// getMetric gets the usages of all pods or nodes in fresh NodeMetrics, pods are labeled with PodNamespace, PodName,
+// PodUID and Node, and nodes are labeled with Node.
+func (p *nodeMetricProvider) getMetric(selector common.MetricSelector) ([]common.LabeledValue, error) {
+	var resourceName corev1.ResourceName
+	podLevel := true
+	switch selector.MetricName {
+	case common.NodeMetricPodCPUUsage:
+		resourceName = corev1.ResourceCPU
+	case common.NodeMetricPodMemoryUsage:
+		resourceName = corev1.ResourceMemory
+	case common.NodeMetricNodeCPUUsage:
+		resourceName, podLevel = corev1.ResourceCPU, false
+	case common.NodeMetricNodeMemoryUsage:
+		resourceName, podLevel = corev1.ResourceMemory, false
+	default:
+		return nil, fmt.Errorf("metric %v is not reported in node metrics", selector.MetricName)
+	}
+
+	ctx := context.Background()
+	nodeMetrics := &slov1alpha1.NodeMetricList{}
+	if err := p.reader.List(ctx, nodeMetrics); err != nil {
+		return nil, err
+	}
+	filter := labels.SelectorFromSet(selector.FilterLabels)
+	freshSince := p.now().Add(-p.maxStaleness)
+	var result []common.LabeledValue
+	for i := range nodeMetrics.Items {
+		nodeMetric := &nodeMetrics.Items[i]
+		if nodeMetric.Status.UpdateTime == nil || nodeMetric.Status.UpdateTime.Time.Before(freshSince) {
+			continue
+		}
+		if !podLevel {
+			if nodeMetric.Status.NodeMetric == nil {
+				continue
+			}
+			result = appendUsage(result, filter, prommodel.Metric{
+				prommodel.LabelName(common.Node): prommodel.LabelValue(nodeMetric.Name),
+			}, &nodeMetric.Status.NodeMetric.NodeUsage, resourceName, nodeMetric.Status.UpdateTime.Time)
+			continue
+		}
+		for _, podMetric := range nodeMetric.Status.PodsMetric {
+			if podMetric == nil {
+				continue
+			}
+			podLabels := prommodel.Metric{
+				prommodel.LabelName(common.Node):         prommodel.LabelValue(nodeMetric.Name),
+				prommodel.LabelName(common.PodNamespace): prommodel.LabelValue(podMetric.Namespace),
+				prommodel.LabelName(common.PodName):      prommodel.LabelValue(podMetric.Name),
+			}
+			// pods filtered out are not read
+			if !matchesKnownLabels(filter, podLabels) {
+				continue
+			}
+			pod := &corev1.Pod{}
+			if err := p.reader.Get(ctx, types.NamespacedName{Namespace: podMetric.Namespace, Name: podMetric.Name}, pod); err == nil {
+				podLabels[prommodel.LabelName(common.PodUID)] = prommodel.LabelValue(pod.UID)
+			}
+			result = appendUsage(result, filter, podLabels, &podMetric.PodUsage, resourceName, nodeMetric.Status.UpdateTime.Time)
+		}
+	}
+	return result, nil
+}
+
+// matchesKnownLabels checks the requirements of @filter on the labels in @metricLabels only.
+func matchesKnownLabels(filter labels.Selector, metricLabels prommodel.Metric) bool {
+	requirements, _ := filter.Requirements()
+	for _, r := range requirements {
+		value, ok := metricLabels[prommodel.LabelName(r.Key())]
+		if ok && !r.Matches(labels.Set{r.Key(): string(value)}) {
+			return false
+		}
+	}
+	return true
+}
+
+// appendUsage appends the usage of the resource if it is reported and the labels match the filter.
+func appendUsage(result []common.LabeledValue, filter labels.Selector, metricLabels prommodel.Metric,
+	usage *slov1alpha1.ResourceMap, resourceName corev1.ResourceName, timestamp time.Time) []common.LabeledValue {
+	quantity, ok := usage.ResourceList[resourceName]
+	if !ok {
+		return result
+	}
+	set := labels.Set{}
+	for name, value := range metricLabels {
+		set[string(name)] = string(value)
+	}
+	if !filter.Matches(set) {
+		return result
+	}
+	return append(result, common.LabeledValue{Labels: metricLabels, Value: quantity.AsApproximateFloat64(), Timestamp: timestamp})
+}
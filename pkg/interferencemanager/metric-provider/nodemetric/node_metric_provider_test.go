@@ -0,0 +1,112 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodemetric
+
+import (
+	"testing"
+	"time"
+
+	slov1alpha1 "github.com/koordinator-sh/koordinator/apis/slo/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+)
+
+func newNodeMetric(name string, updateTime time.Time, pods ...*slov1alpha1.PodMetricInfo) *slov1alpha1.NodeMetric {
+	return &slov1alpha1.NodeMetric{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: slov1alpha1.NodeMetricStatus{
+			UpdateTime: &metav1.Time{Time: updateTime},
+			NodeMetric: &slov1alpha1.NodeMetricInfo{
+				NodeUsage: slov1alpha1.ResourceMap{ResourceList: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("8"),
+					corev1.ResourceMemory: resource.MustParse("16Gi"),
+				}},
+			},
+			PodsMetric: pods,
+		},
+	}
+}
+
+func newPodMetric(namespace, name, cpu string) *slov1alpha1.PodMetricInfo {
+	return &slov1alpha1.PodMetricInfo{
+		Namespace: namespace,
+		Name:      name,
+		PodUsage: slov1alpha1.ResourceMap{ResourceList: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse(cpu),
+		}},
+	}
+}
+
+func TestNodeMetricProvider(t *testing.T) {
+	now := time.Now()
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = slov1alpha1.AddToScheme(scheme)
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newNodeMetric("node-1", now.Add(-time.Minute),
+			newPodMetric("default", "web-1", "500m"),
+			newPodMetric("default", "web-2", "1500m"),
+			newPodMetric("kube-system", "dns", "100m"),
+		),
+		// koordlet on node-2 has been down for a while
+		newNodeMetric("node-2", now.Add(-time.Hour), newPodMetric("default", "web-3", "4")),
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-1", UID: "uid-1"}},
+	).Build()
+	p := newNodeMetricProvider(client, 0)
+
+	metrics, err := p.Query(common.MetricQuery{
+		MetricSelector: common.MetricSelector{
+			MetricName:   common.NodeMetricPodCPUUsage,
+			FilterLabels: map[string]string{common.PodNamespace: "default"},
+		},
+	}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 2)
+	assert.Equal(t, map[string]string{common.Node: "node-1", common.PodNamespace: "default", common.PodName: "web-1",
+		common.PodUID: "uid-1"}, metrics[0].Labels)
+	assert.Equal(t, 0.5, metrics[0].Value)
+	// pods not found have no uid
+	assert.Equal(t, "web-2", metrics[1].Labels[common.PodName])
+	assert.NotContains(t, metrics[1].Labels, common.PodUID)
+
+	metrics, err = p.Query(common.MetricQuery{
+		MetricSelector: common.MetricSelector{MetricName: common.NodeMetricPodCPUUsage},
+		GroupByLabels:  []string{common.Node},
+	}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.InDelta(t, 2.1, metrics[0].Value, 1e-9)
+
+	metrics, err = p.Query(common.MetricQuery{
+		MetricSelector: common.MetricSelector{MetricName: common.NodeMetricNodeMemoryUsage},
+	}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, float64(16<<30), metrics[0].Value)
+
+	_, err = p.GetCPI(common.MetricQueryOptions{MetricName: common.KoordletContainerCPI}, nil)
+	assert.Error(t, err)
+	_, err = p.Query(common.MetricQuery{MetricSelector: common.MetricSelector{MetricName: common.KoordletPodCPI}}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prometheus
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/metrics"
+)
+
+const (
+	defaultCacheTTL = 15 * time.Second
+	// maxCacheEntries bounds the cache, expired entries are swept once it is full.
+	maxCacheEntries = 1024
+
+	providerName = "prometheus"
+)
+
+// queryCache caches the results of queries for ttl, and coalesces the identical queries in flight, so that rules
+// sharing the same metric and selector send one query per poll. Results are shared by callers, who must not
+// modify them.
+type queryCache struct {
+	ttl time.Duration
+
+	lock    sync.Mutex
+	entries map[string]*cacheEntry
+	calls   map[string]*inflightCall
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	value    interface{}
+	expireAt time.Time
+}
+
+type inflightCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// newQueryCache constructs a queryCache, results are not cached but queries are still coalesced if @ttl is not
+// positive.
+func newQueryCache(ttl time.Duration) *queryCache {
+	return &queryCache{
+		ttl:     ttl,
+		entries: map[string]*cacheEntry{},
+		calls:   map[string]*inflightCall{},
+		now:     time.Now,
+	}
+}
+
+// get returns the cached result of @key, or waits for the query of @key in flight, or queries with @fetch.
+// Failed results are not cached, and a panic of @fetch is returned as the error, so that the waiters are released.
+func (c *queryCache) get(key string, fetch func() (interface{}, error)) (interface{}, error) {
+	c.lock.Lock()
+	if entry, ok := c.entries[key]; ok && c.now().Before(entry.expireAt) {
+		c.lock.Unlock()
+		metrics.RecordCacheRequest(providerName, metrics.CacheHit)
+		return entry.value, nil
+	}
+	if call, ok := c.calls[key]; ok {
+		c.lock.Unlock()
+		metrics.RecordCacheRequest(providerName, metrics.CacheShared)
+		<-call.done
+		return call.value, call.err
+	}
+	call := &inflightCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.lock.Unlock()
+	defer close(call.done)
+	metrics.RecordCacheRequest(providerName, metrics.CacheMiss)
+
+	call.do(fetch)
+
+	c.lock.Lock()
+	delete(c.calls, key)
+	if call.err == nil && c.ttl > 0 {
+		now := c.now()
+		if len(c.entries) >= maxCacheEntries {
+			c.sweep(now)
+		}
+		c.entries[key] = &cacheEntry{value: call.value, expireAt: now.Add(c.ttl)}
+	}
+	c.lock.Unlock()
+	return call.value, call.err
+}
+
+// do sets the result of the call by @fetch, or the error if it panics.
+func (call *inflightCall) do(fetch func() (interface{}, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			call.value, call.err = nil, fmt.Errorf("query panicked: %v", r)
+		}
+	}()
+	call.value, call.err = fetch()
+}
+
+// sweep removes the expired entries, and all entries if none is expired. It is called with the lock held.
+func (c *queryCache) sweep(now time.Time) {
+	for key, entry := range c.entries {
+		if !now.Before(entry.expireAt) {
+			delete(c.entries, key)
+		}
+	}
+	if len(c.entries) >= maxCacheEntries {
+		c.entries = map[string]*cacheEntry{}
+	}
+}
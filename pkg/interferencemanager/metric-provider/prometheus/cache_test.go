@@ -0,0 +1,195 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	mp "github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/config"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/metrics"
+)
+
+func TestQueryCache(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	cache := newQueryCache(time.Minute)
+	cache.now = func() time.Time { return now }
+	fetches := 0
+	fetch := func() (interface{}, error) {
+		fetches++
+		return fetches, nil
+	}
+
+	if v, _ := cache.get("a", fetch); v != 1 {
+		t.Errorf("get() = %v, want 1", v)
+	}
+	if v, _ := cache.get("a", fetch); v != 1 {
+		t.Errorf("get() = %v, want the cached 1", v)
+	}
+	if v, _ := cache.get("b", fetch); v != 2 {
+		t.Errorf("get() of another key = %v, want 2", v)
+	}
+	now = now.Add(time.Minute)
+	if v, _ := cache.get("a", fetch); v != 3 {
+		t.Errorf("get() after ttl = %v, want 3", v)
+	}
+
+	// errors are not cached
+	if _, err := cache.get("c", func() (interface{}, error) { return nil, fmt.Errorf("unavailable") }); err == nil {
+		t.Errorf("get() should return the error of fetch")
+	}
+	if v, err := cache.get("c", fetch); err != nil || v != 4 {
+		t.Errorf("get() after error = %v, %v, want 4", v, err)
+	}
+
+	// results are not cached with a non-positive ttl
+	uncached := newQueryCache(-1)
+	uncached.get("a", fetch)
+	if v, _ := uncached.get("a", fetch); v != 6 {
+		t.Errorf("get() without ttl = %v, want 6", v)
+	}
+}
+
+func TestQueryCacheCoalescing(t *testing.T) {
+	cache := newQueryCache(time.Minute)
+	release := make(chan struct{})
+	var fetches int32
+	fetch := func() (interface{}, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return "result", nil
+	}
+	sharedBefore := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues(providerName, metrics.CacheShared))
+
+	const callers = 5
+	var wg sync.WaitGroup
+	results := make([]interface{}, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = cache.get("a", fetch)
+		}(i)
+	}
+	// wait until the others are waiting for the first call
+	for testutil.ToFloat64(metrics.CacheRequests.WithLabelValues(providerName, metrics.CacheShared))-sharedBefore < callers-1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if fetches != 1 {
+		t.Errorf("fetches = %v, want 1", fetches)
+	}
+	for i, result := range results {
+		if result != "result" {
+			t.Errorf("result of caller %d = %v", i, result)
+		}
+	}
+}
+
+func TestQueryCachePanic(t *testing.T) {
+	cache := newQueryCache(time.Minute)
+	release := make(chan struct{})
+	sharedBefore := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues(providerName, metrics.CacheShared))
+	missBefore := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues(providerName, metrics.CacheMiss))
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := cache.get("a", func() (interface{}, error) {
+			<-release
+			panic("broken response")
+		})
+		errCh <- err
+	}()
+	waiterErrCh := make(chan error, 1)
+	go func() {
+		// wait for the first call in flight
+		for testutil.ToFloat64(metrics.CacheRequests.WithLabelValues(providerName, metrics.CacheMiss)) == missBefore {
+			time.Sleep(time.Millisecond)
+		}
+		_, err := cache.get("a", func() (interface{}, error) { return "result", nil })
+		waiterErrCh <- err
+	}()
+	for testutil.ToFloat64(metrics.CacheRequests.WithLabelValues(providerName, metrics.CacheShared)) == sharedBefore {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	// both the caller and the waiter get the error instead of blocking forever
+	for _, ch := range []chan error{errCh, waiterErrCh} {
+		select {
+		case err := <-ch:
+			if err == nil {
+				t.Errorf("get() of a panicked fetch succeeded, want error")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("get() is blocked by a panicked fetch")
+		}
+	}
+	// the failure is not cached
+	result, err := cache.get("a", func() (interface{}, error) { return "result", nil })
+	if err != nil || result != "result" {
+		t.Errorf("get() = %v, %v, want result", result, err)
+	}
+}
+
+func TestPrometheusProviderCache(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[` +
+			`{"metric":{"pod_uid":"uid-1","container_name":"main"},"value":[1600000000,"1.25"]}]}}`))
+	}))
+	defer server.Close()
+
+	p, err := NewPrometheusProvider(config.PrometheusProviderConfig{Address: server.URL, QueryTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("NewPrometheusProvider() = %v", err)
+	}
+	options := mp.MetricQueryOptions{MetricName: mp.KoordletContainerCPI}
+	for i := 0; i < 3; i++ {
+		result, err := p.GetCPI(options, nil)
+		if err != nil {
+			t.Fatalf("GetCPI() = %v", err)
+		}
+		if len(result) != 1 || result[0].Value != 1.25 {
+			t.Errorf("GetCPI() = %v", result)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("requests = %v, want 1 for identical queries", requests)
+	}
+	if testutil.CollectAndCount(metrics.QueryDuration) == 0 {
+		t.Errorf("query latency is not recorded")
+	}
+
+	if _, err := p.GetCPI(mp.MetricQueryOptions{MetricName: mp.KoordletPodCPI}, nil); err != nil {
+		t.Fatalf("GetCPI() = %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("requests = %v, want 2 for different queries", requests)
+	}
+}
@@ -19,71 +19,207 @@ package prometheus
 import (
 	"context"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
 	promapi "github.com/prometheus/client_golang/api"
 	prometheusv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	prommodel "github.com/prometheus/common/model"
+	ctrl "sigs.k8s.io/controller-runtime"
 
 	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
 	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/config"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/metrics"
 )
 
 const (
-	SumBy string = "sum by"
+	healthCheckQuery    = "vector(1)"
+	defaultQueryTimeout = 10 * time.Second
+
+	// maxRangePoints is the maximum number of points per series in a range query allowed by Prometheus.
+	maxRangePoints = 11000
 )
 
+var providerLog = ctrl.Log.WithName("prometheus-provider")
+
 type prometheusProvider struct {
 	prometheusClient prometheusv1.API
 	config           config.PrometheusProviderConfig
 	queryTimeout     time.Duration
+	cache            *queryCache
 }
 
-// NewPrometheusProvider contructs a metric provider that gets data from Prometheus.
+// NewPrometheusProvider contructs a metric provider that gets data from Prometheus, with the auth, TLS and
+// headers in @config.
 func NewPrometheusProvider(config config.PrometheusProviderConfig) (*prometheusProvider, error) {
+	roundTripper, address, err := newRoundTripper(config)
+	if err != nil {
+		return &prometheusProvider{}, err
+	}
 	promClient, err := promapi.NewClient(promapi.Config{
-		Address: config.Address,
+		Address:      address,
+		RoundTripper: roundTripper,
 	})
 	if err != nil {
 		return &prometheusProvider{}, err
 	}
+	queryTimeout := config.QueryTimeout
+	if queryTimeout <= 0 {
+		queryTimeout = defaultQueryTimeout
+	}
+	cacheTTL := config.CacheTTL
+	if cacheTTL == 0 {
+		cacheTTL = defaultCacheTTL
+	}
 	return &prometheusProvider{
 		prometheusClient: prometheusv1.NewAPI(promClient),
 		config:           config,
-		queryTimeout:     config.QueryTimeout,
+		queryTimeout:     queryTimeout,
+		cache:            newQueryCache(cacheTTL),
 	}, nil
 }
 
 func (p *prometheusProvider) GetCPI(options common.MetricQueryOptions, labelFunc common.MakeLabelsFunc) ([]*common.Metric, error) {
-	query, err := MakeQueryCPIString(options)
+	if options.Level == common.WorkloadLevel {
+		return common.QueryWorkloadCPI(options, p.Query, labelFunc)
+	}
+	query, err := common.NewCPIQuery(options)
+	if err != nil {
+		return nil, err
+	}
+	labelFunc, err = common.CPILabelFunc(options, labelFunc)
+	if err != nil {
+		return nil, err
+	}
+	return p.Query(query, labelFunc)
+}
+
+func (p *prometheusProvider) Query(query common.MetricQuery, labelFunc common.MakeLabelsFunc) ([]*common.Metric, error) {
+	queryString, err := makeQueryString(query, p.config.Lookback)
 	if err != nil {
 		return nil, err
 	}
+	if labelFunc == nil {
+		labelFunc = common.MakeAllLabels
+	}
 	var result []*common.Metric
-	promResult, err := p.query(query)
+	promResult, err := p.query(queryString)
 	if err != nil {
 		return nil, err
 	}
+	dropped := 0
 	for _, metric := range promResult {
+		if !isFinite(metric.Value) {
+			dropped++
+			continue
+		}
 		labels, err := labelFunc(metric.Metric)
 		if err != nil {
 			return nil, err
 		}
 		result = append(result, &common.Metric{
-			Labels: labels,
-			Value:  float64(metric.Value),
+			Labels:    labels,
+			Value:     float64(metric.Value),
+			Timestamp: metric.Timestamp.Time(),
 		})
 	}
+	recordNonFinite(queryString, dropped)
 	return result, nil
 }
 
-// query delicate prometheus query api.
+func (p *prometheusProvider) GetCPIRange(options common.MetricQueryOptions, labelFunc common.MakeLabelsFunc) ([]*common.MetricSeries, error) {
+	if options.Range == nil {
+		return nil, fmt.Errorf("time range is required for range queries")
+	}
+	if options.Level == common.WorkloadLevel {
+		return common.QueryWorkloadCPIRange(options, p.QueryRange, labelFunc)
+	}
+	query, err := common.NewCPIQuery(options)
+	if err != nil {
+		return nil, err
+	}
+	labelFunc, err = common.CPILabelFunc(options, labelFunc)
+	if err != nil {
+		return nil, err
+	}
+	return p.QueryRange(query, *options.Range, labelFunc)
+}
+
+func (p *prometheusProvider) QueryRange(query common.MetricQuery, timeRange common.TimeRange, labelFunc common.MakeLabelsFunc) ([]*common.MetricSeries, error) {
+	if err := validateTimeRange(timeRange); err != nil {
+		return nil, err
+	}
+	queryString, err := makeQueryString(query, p.config.Lookback)
+	if err != nil {
+		return nil, err
+	}
+	if labelFunc == nil {
+		labelFunc = common.MakeAllLabels
+	}
+	promResult, err := p.queryRange(queryString, timeRange)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*common.MetricSeries, 0, len(promResult))
+	dropped := 0
+	for _, stream := range promResult {
+		samples := make([]common.Sample, 0, len(stream.Values))
+		for _, pair := range stream.Values {
+			if !isFinite(pair.Value) {
+				dropped++
+				continue
+			}
+			samples = append(samples, common.Sample{
+				Timestamp: pair.Timestamp.Time(),
+				Value:     float64(pair.Value),
+			})
+		}
+		if len(samples) == 0 {
+			continue
+		}
+		labels, err := labelFunc(stream.Metric)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &common.MetricSeries{
+			Labels:  labels,
+			Samples: samples,
+		})
+	}
+	recordNonFinite(queryString, dropped)
+	return result, nil
+}
+
+// CheckHealth evaluates a constant expression, which works on Prometheus compatible APIs like Thanos and Cortex,
+// unlike the status APIs.
+func (p *prometheusProvider) CheckHealth(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, p.queryTimeout)
+	defer cancel()
+	if _, _, err := p.prometheusClient.Query(ctx, healthCheckQuery, time.Now()); err != nil {
+		return fmt.Errorf("prometheus is unreachable: %v", err)
+	}
+	return nil
+}
+
+// query delicate prometheus query api, the results of identical queries are shared within the cache ttl.
 func (p *prometheusProvider) query(query string) (prommodel.Vector, error) {
+	result, err := p.cache.get("query:"+query, func() (interface{}, error) {
+		return p.doQuery(query)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(prommodel.Vector), nil
+}
+
+func (p *prometheusProvider) doQuery(query string) (prommodel.Vector, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), p.queryTimeout)
 	defer cancel()
 
-	result, _, err := p.prometheusClient.Query(ctx, query, time.Now())
+	start := time.Now()
+	result, _, err := p.prometheusClient.Query(ctx, query, start)
+	metrics.RecordQueryDuration(providerName, metrics.InstantQuery, err, time.Since(start))
 	if err != nil {
 		return nil, fmt.Errorf("cannot get metrics for query %v: %v", query, err)
 	}
@@ -96,26 +232,81 @@ func (p *prometheusProvider) query(query string) (prommodel.Vector, error) {
 	return vector, nil
 }
 
+// queryRange delicate prometheus query_range api, the results of identical queries are shared within the cache
+// ttl.
+func (p *prometheusProvider) queryRange(query string, timeRange common.TimeRange) (prommodel.Matrix, error) {
+	key := fmt.Sprintf("query_range:%d:%d:%d:%s", timeRange.Start.UnixNano(), timeRange.End.UnixNano(),
+		timeRange.Step, query)
+	result, err := p.cache.get(key, func() (interface{}, error) {
+		return p.doQueryRange(query, timeRange)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(prommodel.Matrix), nil
+}
+
+func (p *prometheusProvider) doQueryRange(query string, timeRange common.TimeRange) (prommodel.Matrix, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.queryTimeout)
+	defer cancel()
+
+	start := time.Now()
+	result, _, err := p.prometheusClient.QueryRange(ctx, query, prometheusv1.Range{
+		Start: timeRange.Start,
+		End:   timeRange.End,
+		Step:  timeRange.Step,
+	})
+	metrics.RecordQueryDuration(providerName, metrics.RangeQuery, err, time.Since(start))
+	if err != nil {
+		return nil, fmt.Errorf("cannot get metrics for range query %v: %v", query, err)
+	}
+
+	matrix, ok := result.(prommodel.Matrix)
+	if !ok {
+		return nil, fmt.Errorf("expected range query to return a matrix; got result type %T", result)
+	}
+
+	return matrix, nil
+}
+
+// isFinite checks whether the value is usable, e.g. the CPI of an idle container is NaN or +Inf since it has no
+// instructions.
+func isFinite(value prommodel.SampleValue) bool {
+	return !math.IsNaN(float64(value)) && !math.IsInf(float64(value), 0)
+}
+
+func recordNonFinite(query string, dropped int) {
+	if dropped == 0 {
+		return
+	}
+	metrics.RecordDroppedSamples(providerName, metrics.NonFiniteSample, dropped)
+	providerLog.V(4).Info("dropped non-finite samples", "query", query, "count", dropped)
+}
+
+// validateTimeRange checks the time range, Prometheus rejects queries of more than maxRangePoints points per series.
+func validateTimeRange(timeRange common.TimeRange) error {
+	if timeRange.Step <= 0 {
+		return fmt.Errorf("step of time range must be positive, got %v", timeRange.Step)
+	}
+	if timeRange.End.Before(timeRange.Start) {
+		return fmt.Errorf("end of time range %v is before start %v", timeRange.End, timeRange.Start)
+	}
+	if points := int64(timeRange.End.Sub(timeRange.Start) / timeRange.Step); points > maxRangePoints {
+		return fmt.Errorf("time range has %d points, exceeds the limit %d", points, maxRangePoints)
+	}
+	return nil
+}
+
 func NewDefaultCPISumByLabels(metricName string) ([]string, error) {
-	switch metricName {
-	case common.KoordletContainerCPI:
-		return []string{
-			common.ContainerID,
-			common.ContainerName,
-			common.PodUID,
-			common.PodNamespace,
-			common.PodName,
-			common.Node,
-		}, nil
-	case common.KoordletPodCPI:
-		return []string{
-			common.PodUID,
-			common.PodNamespace,
-			common.PodName,
-			common.Node,
-		}, nil
-	}
-	return nil, fmt.Errorf("metric name %v not supported", metricName)
+	level, err := common.CPIQueryLevelOf(common.MetricQueryOptions{MetricName: metricName})
+	if err != nil {
+		return nil, err
+	}
+	spec, err := common.GetCPIQueryLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return spec.GroupByLabels, nil
 }
 
 // MakeQueryCPIString constructs PromQL style query string based on @options.
@@ -127,37 +318,97 @@ func NewDefaultCPISumByLabels(metricName string) ([]string, error) {
 // "(koordlet_container_cpi{cpi_field=\"instructions\"})"
 //
 // where
-// (container_id, container_name, pod_uid, pod_namespace, pod_name, node) is the PromSumByLabels slice
+// (container_id, container_name, pod_uid, pod_namespace, pod_name, node) is the PromSumByLabels slice, or the labels of the level
 // {cpi_field=\"cycles\"} is the FilterLabels map
-// koordlet_container_cpi is options.MetricName
+// koordlet_container_cpi is the metric of the level, which is options.Level or inferred from options.MetricName
 func MakeQueryCPIString(options common.MetricQueryOptions) (string, error) {
-	if options.PromSumByLabels == nil {
-		labels, err := NewDefaultCPISumByLabels(options.MetricName)
+	query, err := common.NewCPIQuery(options)
+	if err != nil {
+		return "", err
+	}
+	return MakeQueryString(query)
+}
+
+// MakeQueryString constructs PromQL style query string based on @query.
+//
+// @return
+// "sum by(pod_uid,container_name)(koordetector_container_cpu_schedule_latency_seconds{node=\"node-1\"})"
+//
+// where
+// sum is query.Aggregation, which is sum by default if query.GroupByLabels is not empty
+// (pod_uid,container_name) is query.GroupByLabels
+// {node=\"node-1\"} is query.FilterLabels and query.Matchers, which are escaped and sorted by labels
+// if query.Denominator is set, it is appended as "/sum by(...)(denominator{...})"
+func MakeQueryString(query common.MetricQuery) (string, error) {
+	return makeQueryString(query, 0)
+}
+
+// makeQueryString constructs the query string like MakeQueryString, series are selected with
+// last_over_time(selector[lookback]) if @lookback is positive, so that series without a sample within lookback
+// are not returned, instead of keeping the last value for the default lookback of Prometheus (5m).
+func makeQueryString(query common.MetricQuery, lookback time.Duration) (string, error) {
+	if query.MetricName == "" {
+		return "", fmt.Errorf("metric name is required")
+	}
+	if query.Denominator != nil && query.Denominator.MetricName == "" {
+		return "", fmt.Errorf("metric name of denominator is required")
+	}
+	aggregation := query.Aggregation
+	if aggregation == "" && len(query.GroupByLabels) > 0 {
+		aggregation = common.AggregationSum
+	}
+	switch aggregation {
+	case "", common.AggregationSum, common.AggregationAvg, common.AggregationMax, common.AggregationMin:
+	default:
+		return "", fmt.Errorf("aggregation %v not supported", aggregation)
+	}
+
+	if err := validateLabelNames(query.GroupByLabels); err != nil {
+		return "", err
+	}
+
+	series, err := makeSeriesString(query.MetricSelector, lookback)
+	if err != nil {
+		return "", err
+	}
+	queryString := makeAggregationString(aggregation, query.GroupByLabels) + series
+	if query.Denominator != nil {
+		denominator, err := makeSeriesString(*query.Denominator, lookback)
 		if err != nil {
 			return "", err
 		}
-		options.PromSumByLabels = labels
+		queryString += "/" + makeAggregationString(aggregation, query.GroupByLabels) + denominator
+	}
+	return queryString, nil
+}
+
+// makeSeriesString selects the series of the metric, with the latest samples within @lookback if it is set.
+//
+// @return
+// "(koordlet_pod_cpi{cpi_field=\"cycles\"})"
+func makeSeriesString(selector common.MetricSelector, lookback time.Duration) (string, error) {
+	selectorString, err := makeSelectorString(selector)
+	if err != nil {
+		return "", err
 	}
-	if options.FilterLabels == nil {
-		options.FilterLabels = map[string]string{}
+	if lookback > 0 {
+		selectorString = fmt.Sprintf("last_over_time(%v[%v])", selectorString, prommodel.Duration(lookback))
 	}
-	options.FilterLabels[common.CPIField] = common.Cycles
-	queryCycles := makeSumByString(options.PromSumByLabels) + makeMetricFilterString(common.KoordletContainerCPI, options.FilterLabels)
-	options.FilterLabels[common.CPIField] = common.Instructions
-	queryInstructions := makeSumByString(options.PromSumByLabels) + makeMetricFilterString(common.KoordletContainerCPI, options.FilterLabels)
-	return queryCycles + "/" + queryInstructions, nil
+	return fmt.Sprintf("(%v)", selectorString), nil
 }
 
-func makeSumByString(labels []string) string {
+func makeAggregationString(aggregation common.AggregationType, labels []string) string {
+	if aggregation == "" {
+		return ""
+	}
 	labelsString := strings.Join(labels, ",")
-	return fmt.Sprintf("%v(%v)", SumBy, labelsString)
+	return fmt.Sprintf("%v by(%v)", aggregation, labelsString)
 }
 
-func makeMetricFilterString(metricName string, filters map[string]string) string {
-	var filterSlice []string
-	for label, value := range filters {
-		filterSlice = append(filterSlice, fmt.Sprintf("%v=\"%v\"", label, value))
-	}
-	filterString := strings.Join(filterSlice, ",")
-	return fmt.Sprintf("(%v{%v})", metricName, filterString)
+// makeSelectorString builds the selector with the FilterLabels and Matchers of @selector.
+func makeSelectorString(selector common.MetricSelector) (string, error) {
+	return newSelectorBuilder(selector.MetricName).
+		Equal(selector.FilterLabels).
+		Match(selector.Matchers...).
+		Build()
 }
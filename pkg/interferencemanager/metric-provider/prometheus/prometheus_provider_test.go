@@ -18,7 +18,17 @@ package prometheus
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/config"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/metrics"
 
 	mp "github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
 )
@@ -38,3 +48,373 @@ func TestMakeQueryCPIString(t *testing.T) {
 	}
 	fmt.Printf("result: %v", query)
 }
+
+func TestMakeQueryString(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   mp.MetricQuery
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "plain metric",
+			query: mp.MetricQuery{
+				MetricSelector: mp.MetricSelector{MetricName: "node_pressure_cpu_waiting_seconds_total"},
+			},
+			want: "(node_pressure_cpu_waiting_seconds_total{})",
+		},
+		{
+			name: "group by with default aggregation",
+			query: mp.MetricQuery{
+				MetricSelector: mp.MetricSelector{
+					MetricName:   "koordetector_container_cpu_schedule_latency_seconds",
+					FilterLabels: map[string]string{mp.Node: "node-1", mp.PodNamespace: "default"},
+				},
+				GroupByLabels: []string{mp.PodUID, mp.ContainerName},
+			},
+			want: "sum by(pod_uid,container_name)(koordetector_container_cpu_schedule_latency_seconds{node=\"node-1\",pod_namespace=\"default\"})",
+		},
+		{
+			name: "ratio",
+			query: mp.MetricQuery{
+				MetricSelector: mp.MetricSelector{
+					MetricName:   mp.KoordletContainerCPI,
+					FilterLabels: map[string]string{mp.CPIField: mp.Cycles},
+				},
+				GroupByLabels: []string{mp.PodUID},
+				Aggregation:   mp.AggregationAvg,
+				Denominator: &mp.MetricSelector{
+					MetricName:   mp.KoordletContainerCPI,
+					FilterLabels: map[string]string{mp.CPIField: mp.Instructions},
+				},
+			},
+			want: "avg by(pod_uid)(koordlet_container_cpi{cpi_field=\"cycles\"})/avg by(pod_uid)(koordlet_container_cpi{cpi_field=\"instructions\"})",
+		},
+		{
+			name:    "missing metric name",
+			query:   mp.MetricQuery{GroupByLabels: []string{mp.PodUID}},
+			wantErr: true,
+		},
+		{
+			name: "unknown aggregation",
+			query: mp.MetricQuery{
+				MetricSelector: mp.MetricSelector{MetricName: "foo"},
+				Aggregation:    "stddev_over_time",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MakeQueryString(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MakeQueryString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MakeQueryString() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryRange(t *testing.T) {
+	var gotQuery, gotStep string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query_range" {
+			t.Errorf("unexpected path %v", r.URL.Path)
+		}
+		_ = r.ParseForm()
+		gotQuery, gotStep = r.Form.Get("query"), r.Form.Get("step")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[` +
+			`{"metric":{"pod_uid":"uid-1","container_name":"main"},"values":[[1600000000,"1.5"],[1600000060,"2.5"]]}]}}`))
+	}))
+	defer server.Close()
+
+	p, err := NewPrometheusProvider(config.PrometheusProviderConfig{Address: server.URL, QueryTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("NewPrometheusProvider() = %v", err)
+	}
+	start := time.Unix(1600000000, 0)
+	series, err := p.QueryRange(mp.MetricQuery{
+		MetricSelector: mp.MetricSelector{MetricName: "koordetector_container_cpu_schedule_latency_seconds"},
+		GroupByLabels:  []string{mp.PodUID, mp.ContainerName},
+	}, mp.TimeRange{Start: start, End: start.Add(time.Minute), Step: time.Minute}, nil)
+	if err != nil {
+		t.Fatalf("QueryRange() = %v", err)
+	}
+	if want := "sum by(pod_uid,container_name)(koordetector_container_cpu_schedule_latency_seconds{})"; gotQuery != want {
+		t.Errorf("query = %v, want %v", gotQuery, want)
+	}
+	if gotStep != "60" {
+		t.Errorf("step = %v, want 60", gotStep)
+	}
+	if len(series) != 1 || len(series[0].Samples) != 2 {
+		t.Fatalf("QueryRange() = %v, want 1 series of 2 samples", series)
+	}
+	if series[0].Labels[mp.PodUID] != "uid-1" || series[0].Labels[mp.ContainerName] != "main" {
+		t.Errorf("labels = %v", series[0].Labels)
+	}
+	if !series[0].Samples[1].Timestamp.Equal(start.Add(time.Minute)) || series[0].Samples[1].Value != 2.5 {
+		t.Errorf("sample = %v", series[0].Samples[1])
+	}
+
+	// invalid time ranges are rejected before querying
+	_, err = p.GetCPIRange(mp.MetricQueryOptions{MetricName: mp.KoordletContainerCPI}, mp.MakeContainerCPILabels)
+	if err == nil {
+		t.Errorf("GetCPIRange() without range should fail")
+	}
+	_, err = p.QueryRange(mp.MetricQuery{MetricSelector: mp.MetricSelector{MetricName: "foo"}},
+		mp.TimeRange{Start: start, End: start.Add(24 * time.Hour), Step: time.Second}, nil)
+	if err == nil {
+		t.Errorf("QueryRange() with too many points should fail")
+	}
+}
+
+func TestMakeQueryCPIStringLevels(t *testing.T) {
+	tests := []struct {
+		name    string
+		options mp.MetricQueryOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "container level",
+			options: mp.MetricQueryOptions{Level: mp.ContainerLevel},
+			want: "sum by(container_id,container_name,pod_uid,pod_namespace,pod_name,node)(koordlet_container_cpi{cpi_field=\"cycles\"})/" +
+				"sum by(container_id,container_name,pod_uid,pod_namespace,pod_name,node)(koordlet_container_cpi{cpi_field=\"instructions\"})",
+		},
+		{
+			name:    "pod level inferred from metric name",
+			options: mp.MetricQueryOptions{MetricName: mp.KoordletPodCPI, FilterLabels: map[string]string{mp.Node: "node-1"}},
+			want: "sum by(pod_uid,pod_namespace,pod_name,node)(koordlet_pod_cpi{cpi_field=\"cycles\",node=\"node-1\"})/" +
+				"sum by(pod_uid,pod_namespace,pod_name,node)(koordlet_pod_cpi{cpi_field=\"instructions\",node=\"node-1\"})",
+		},
+		{
+			name:    "workload level aggregated by owners",
+			options: mp.MetricQueryOptions{Level: mp.WorkloadLevel},
+			wantErr: true,
+		},
+		{
+			name:    "unknown level",
+			options: mp.MetricQueryOptions{Level: "node"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown metric",
+			options: mp.MetricQueryOptions{MetricName: "koordlet_node_cpi"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MakeQueryCPIString(tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MakeQueryCPIString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MakeQueryCPIString() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCPILevels(t *testing.T) {
+	result := map[mp.QueryLevel]string{
+		mp.ContainerLevel: `{"container_id":"c-1","container_name":"main","pod_uid":"uid-1","pod_namespace":"default","pod_name":"web-1","node":"node-1"}`,
+		mp.PodLevel:       `{"pod_uid":"uid-1","pod_namespace":"default","pod_name":"web-1","node":"node-1"}`,
+	}
+	wantLabels := map[mp.QueryLevel]map[string]string{
+		mp.ContainerLevel: {mp.ContainerID: "c-1", mp.ContainerName: "main", mp.PodUID: "uid-1", mp.PodNamespace: "default",
+			mp.PodName: "web-1", mp.Node: "node-1"},
+		mp.PodLevel: {mp.PodUID: "uid-1", mp.PodNamespace: "default", mp.PodName: "web-1", mp.Node: "node-1"},
+	}
+	wantMetric := map[mp.QueryLevel]string{
+		mp.ContainerLevel: mp.KoordletContainerCPI,
+		mp.PodLevel:       mp.KoordletPodCPI,
+	}
+
+	for level := range result {
+		t.Run(string(level), func(t *testing.T) {
+			var gotQuery string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = r.ParseForm()
+				gotQuery = r.Form.Get("query")
+				_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[` +
+					`{"metric":` + result[level] + `,"value":[1600000000,"1.25"]}]}}`))
+			}))
+			defer server.Close()
+
+			p, err := NewPrometheusProvider(config.PrometheusProviderConfig{Address: server.URL, QueryTimeout: time.Second})
+			if err != nil {
+				t.Fatalf("NewPrometheusProvider() = %v", err)
+			}
+			metrics, err := p.GetCPI(mp.MetricQueryOptions{Level: level}, nil)
+			if err != nil {
+				t.Fatalf("GetCPI() = %v", err)
+			}
+			if !strings.Contains(gotQuery, wantMetric[level]+"{") {
+				t.Errorf("query %v does not select %v", gotQuery, wantMetric[level])
+			}
+			if len(metrics) != 1 || metrics[0].Value != 1.25 {
+				t.Fatalf("GetCPI() = %v, want 1 metric of 1.25", metrics)
+			}
+			if !reflect.DeepEqual(metrics[0].Labels, wantLabels[level]) {
+				t.Errorf("labels = %v, want %v", metrics[0].Labels, wantLabels[level])
+			}
+		})
+	}
+}
+
+func TestGetCPIWorkloadLevel(t *testing.T) {
+	// web-1 and web-2 belong to ReplicaSet web, and standalone has no owner
+	values := map[string][][2]string{
+		"cycles":       {{"web-1", "2"}, {"web-2", "4"}, {"standalone", "10"}},
+		"instructions": {{"web-1", "1"}, {"web-2", "1"}, {"standalone", "1"}},
+	}
+	var gotQueries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		query := r.Form.Get("query")
+		gotQueries = append(gotQueries, query)
+		field := "instructions"
+		if strings.Contains(query, `cpi_field="cycles"`) {
+			field = "cycles"
+		}
+		isRange := r.URL.Path == "/api/v1/query_range"
+		var result []string
+		for _, pod := range values[field] {
+			metric := `{"pod_uid":"uid-` + pod[0] + `","pod_namespace":"default","pod_name":"` + pod[0] + `","node":"node-1"}`
+			if isRange {
+				result = append(result, `{"metric":`+metric+`,"values":[[1600000000,"`+pod[1]+`"],[1600000060,"`+pod[1]+`"]]}`)
+			} else {
+				result = append(result, `{"metric":`+metric+`,"value":[1600000000,"`+pod[1]+`"]}`)
+			}
+		}
+		resultType := "vector"
+		if isRange {
+			resultType = "matrix"
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"` + resultType + `","result":[` +
+			strings.Join(result, ",") + `]}}`))
+	}))
+	defer server.Close()
+
+	p, err := NewPrometheusProvider(config.PrometheusProviderConfig{Address: server.URL, QueryTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("NewPrometheusProvider() = %v", err)
+	}
+	resolved := 0
+	owners := func(namespace, name string) (string, string, bool, error) {
+		resolved++
+		if name == "standalone" {
+			return "", "", false, nil
+		}
+		return "ReplicaSet", "web", true, nil
+	}
+	wantLabels := map[string]string{mp.PodNamespace: "default", mp.OwnerKind: "ReplicaSet", mp.OwnerName: "web"}
+
+	if _, err = p.GetCPI(mp.MetricQueryOptions{Level: mp.WorkloadLevel}, nil); err == nil {
+		t.Errorf("GetCPI() without owners succeeded, want error")
+	}
+
+	metrics, err := p.GetCPI(mp.MetricQueryOptions{Level: mp.WorkloadLevel, Owners: owners}, nil)
+	if err != nil {
+		t.Fatalf("GetCPI() = %v", err)
+	}
+	// (2 + 4) / (1 + 1), and standalone is dropped
+	if len(metrics) != 1 || metrics[0].Value != 3 {
+		t.Fatalf("GetCPI() = %v, want 1 metric of 3", metrics)
+	}
+	if !reflect.DeepEqual(metrics[0].Labels, wantLabels) {
+		t.Errorf("labels = %v, want %v", metrics[0].Labels, wantLabels)
+	}
+	if resolved != 3 {
+		t.Errorf("owners resolved %d times, want once per pod", resolved)
+	}
+	for _, query := range gotQueries {
+		if !strings.HasPrefix(query, "sum by(pod_uid,pod_namespace,pod_name,node)(koordlet_pod_cpi{") {
+			t.Errorf("query %v does not select pod series", query)
+		}
+	}
+
+	metrics, err = p.GetCPI(mp.MetricQueryOptions{Level: mp.WorkloadLevel, Owners: owners,
+		FilterLabels: map[string]string{mp.OwnerName: "db"}}, nil)
+	if err != nil || len(metrics) != 0 {
+		t.Errorf("GetCPI() of other workloads = %v, %v, want none", metrics, err)
+	}
+
+	series, err := p.GetCPIRange(mp.MetricQueryOptions{Level: mp.WorkloadLevel, Owners: owners,
+		Range: &mp.TimeRange{Start: time.Unix(1600000000, 0), End: time.Unix(1600000060, 0), Step: time.Minute}}, nil)
+	if err != nil {
+		t.Fatalf("GetCPIRange() = %v", err)
+	}
+	wantSamples := []mp.Sample{
+		{Timestamp: time.Unix(1600000000, 0), Value: 3},
+		{Timestamp: time.Unix(1600000060, 0), Value: 3},
+	}
+	if len(series) != 1 || !reflect.DeepEqual(series[0].Labels, wantLabels) {
+		t.Fatalf("GetCPIRange() = %v, want 1 series of %v", series, wantLabels)
+	}
+	if !reflect.DeepEqual(series[0].Samples, wantSamples) {
+		t.Errorf("samples = %v, want %v", series[0].Samples, wantSamples)
+	}
+}
+
+func TestDropNonFiniteSamples(t *testing.T) {
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		gotQuery = r.Form.Get("query")
+		if r.URL.Path == "/api/v1/query_range" {
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[` +
+				`{"metric":{"pod_uid":"uid-1"},"values":[[1600000000,"NaN"],[1600000060,"1.5"]]},` +
+				`{"metric":{"pod_uid":"uid-2"},"values":[[1600000000,"+Inf"],[1600000060,"NaN"]]}]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[` +
+			`{"metric":{"pod_uid":"uid-1"},"value":[1600000000,"1.25"]},` +
+			`{"metric":{"pod_uid":"uid-2"},"value":[1600000000,"NaN"]},` +
+			`{"metric":{"pod_uid":"uid-3"},"value":[1600000000,"+Inf"]}]}}`))
+	}))
+	defer server.Close()
+
+	p, err := NewPrometheusProvider(config.PrometheusProviderConfig{
+		Address:      server.URL,
+		QueryTimeout: time.Second,
+		Lookback:     2 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewPrometheusProvider() = %v", err)
+	}
+	droppedBefore := testutil.ToFloat64(metrics.DroppedSamples.WithLabelValues(providerName, metrics.NonFiniteSample))
+
+	query := mp.MetricQuery{
+		MetricSelector: mp.MetricSelector{MetricName: "cycles"},
+		GroupByLabels:  []string{mp.PodUID},
+		Denominator:    &mp.MetricSelector{MetricName: "instructions"},
+	}
+	result, err := p.Query(query, nil)
+	if err != nil {
+		t.Fatalf("Query() = %v", err)
+	}
+	if want := "sum by(pod_uid)(last_over_time(cycles{}[2m]))/sum by(pod_uid)(last_over_time(instructions{}[2m]))"; gotQuery != want {
+		t.Errorf("query = %v, want %v", gotQuery, want)
+	}
+	if len(result) != 1 || result[0].Labels[mp.PodUID] != "uid-1" {
+		t.Errorf("Query() = %v, want the finite value of uid-1 only", result)
+	}
+
+	start := time.Unix(1600000000, 0)
+	series, err := p.QueryRange(query, mp.TimeRange{Start: start, End: start.Add(time.Minute), Step: time.Minute}, nil)
+	if err != nil {
+		t.Fatalf("QueryRange() = %v", err)
+	}
+	if len(series) != 1 || len(series[0].Samples) != 1 || series[0].Samples[0].Value != 1.5 {
+		t.Errorf("QueryRange() = %v, want the finite sample of uid-1 only", series)
+	}
+
+	dropped := testutil.ToFloat64(metrics.DroppedSamples.WithLabelValues(providerName, metrics.NonFiniteSample)) - droppedBefore
+	if dropped != 5 {
+		t.Errorf("dropped samples = %v, want 5", dropped)
+	}
+}
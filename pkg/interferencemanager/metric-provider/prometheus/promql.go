@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prometheus
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	prommodel "github.com/prometheus/common/model"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+)
+
+// selectorBuilder builds the PromQL selector of a metric, values are escaped and matchers are sorted, so that
+// the same selector always builds the same string.
+type selectorBuilder struct {
+	metricName string
+	matchers   []common.LabelMatcher
+}
+
+func newSelectorBuilder(metricName string) *selectorBuilder {
+	return &selectorBuilder{metricName: metricName}
+}
+
+// Equal adds the equality matchers of @labels.
+func (b *selectorBuilder) Equal(labels map[string]string) *selectorBuilder {
+	for name, value := range labels {
+		b.matchers = append(b.matchers, common.LabelMatcher{Name: name, Type: common.MatchEqual, Value: value})
+	}
+	return b
+}
+
+func (b *selectorBuilder) Match(matchers ...common.LabelMatcher) *selectorBuilder {
+	b.matchers = append(b.matchers, matchers...)
+	return b
+}
+
+// Build returns the selector like metric{a="1",b!~"x|y"}.
+func (b *selectorBuilder) Build() (string, error) {
+	if !prommodel.IsValidMetricName(prommodel.LabelValue(b.metricName)) {
+		return "", fmt.Errorf("invalid metric name %q", b.metricName)
+	}
+	matchers := make([]common.LabelMatcher, len(b.matchers))
+	copy(matchers, b.matchers)
+	sort.Slice(matchers, func(i, j int) bool {
+		if matchers[i].Name != matchers[j].Name {
+			return matchers[i].Name < matchers[j].Name
+		}
+		if matchers[i].Type != matchers[j].Type {
+			return matchers[i].Type < matchers[j].Type
+		}
+		return matchers[i].Value < matchers[j].Value
+	})
+	matcherStrings := make([]string, 0, len(matchers))
+	for i := range matchers {
+		m := &matchers[i]
+		if err := m.Validate(); err != nil {
+			return "", err
+		}
+		// PromQL strings take the same escapes as Go
+		matcherStrings = append(matcherStrings, m.Name+string(m.Type)+strconv.Quote(m.Value))
+	}
+	return fmt.Sprintf("%v{%v}", b.metricName, strings.Join(matcherStrings, ",")), nil
+}
+
+// validateLabelNames checks the label names used in aggregations and joins.
+func validateLabelNames(names []string) error {
+	for _, name := range names {
+		if !prommodel.LabelName(name).IsValid() {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prometheus
+
+import (
+	"testing"
+
+	mp "github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+)
+
+func TestSelectorBuilder(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector mp.MetricSelector
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "no labels",
+			selector: mp.MetricSelector{MetricName: "up"},
+			want:     `up{}`,
+		},
+		{
+			name: "sorted matchers",
+			selector: mp.MetricSelector{
+				MetricName:   "koordlet_container_cpi",
+				FilterLabels: map[string]string{"node": "node-1", "cpi_field": "cycles"},
+				Matchers: []mp.LabelMatcher{
+					{Name: "pod_namespace", Type: mp.MatchNotRegexp, Value: "kube-.*"},
+					{Name: "container_name", Type: mp.MatchNotEqual, Value: ""},
+					{Name: "pod_namespace", Type: mp.MatchNotEqual, Value: "default"},
+				},
+			},
+			want: `koordlet_container_cpi{container_name!="",cpi_field="cycles",node="node-1",` +
+				`pod_namespace!="default",pod_namespace!~"kube-.*"}`,
+		},
+		{
+			name: "escaped values",
+			selector: mp.MetricSelector{
+				MetricName:   "up",
+				FilterLabels: map[string]string{"job": `a"}) or vector(1) #\`},
+				Matchers:     []mp.LabelMatcher{{Name: "instance", Type: mp.MatchRegexp, Value: `10\.0\..*`}},
+			},
+			want: `up{instance=~"10\\.0\\..*",job="a\"}) or vector(1) #\\"}`,
+		},
+		{
+			name:     "invalid metric name",
+			selector: mp.MetricSelector{MetricName: "up{job=\"x\"}"},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid label name",
+			selector: mp.MetricSelector{MetricName: "up", FilterLabels: map[string]string{"job\"": "x"}},
+			wantErr:  true,
+		},
+		{
+			name: "invalid regexp",
+			selector: mp.MetricSelector{
+				MetricName: "up",
+				Matchers:   []mp.LabelMatcher{{Name: "job", Type: mp.MatchRegexp, Value: "("}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown match type",
+			selector: mp.MetricSelector{
+				MetricName: "up",
+				Matchers:   []mp.LabelMatcher{{Name: "job", Type: "==", Value: "x"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the string must not change between calls
+			for i := 0; i < 10; i++ {
+				got, err := makeSelectorString(tt.selector)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("makeSelectorString() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if got != tt.want {
+					t.Fatalf("makeSelectorString() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMakeQueryStringInvalidGroupBy(t *testing.T) {
+	_, err := MakeQueryString(mp.MetricQuery{
+		MetricSelector: mp.MetricSelector{MetricName: "up"},
+		GroupByLabels:  []string{"pod_uid) or vector(1"},
+	})
+	if err == nil {
+		t.Errorf("MakeQueryString() with invalid label names should fail")
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	promconfig "github.com/prometheus/common/config"
+	"k8s.io/client-go/rest"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/config"
+)
+
+const (
+	// TenantHeader is the header of the tenant in multi-tenant Prometheus like Thanos and Cortex.
+	TenantHeader = "X-Scope-OrgID"
+
+	clientName = "interference-manager"
+)
+
+// newRoundTripper builds the round tripper to access Prometheus with the auth, TLS and headers of @cfg, and
+// returns the address of Prometheus, which is the service proxy path on the apiserver if cfg.ServiceProxy is set.
+func newRoundTripper(cfg config.PrometheusProviderConfig) (http.RoundTripper, string, error) {
+	var rt http.RoundTripper
+	address := cfg.Address
+	if cfg.ServiceProxy != nil {
+		var err error
+		rt, address, err = newServiceProxyRoundTripper(cfg.ServiceProxy)
+		if err != nil {
+			return nil, "", err
+		}
+	} else {
+		httpConfig := promconfig.HTTPClientConfig{
+			BearerTokenFile: cfg.BearerTokenFile,
+			TLSConfig: promconfig.TLSConfig{
+				CAFile:             cfg.TLS.CAFile,
+				CertFile:           cfg.TLS.CertFile,
+				KeyFile:            cfg.TLS.KeyFile,
+				ServerName:         cfg.TLS.ServerName,
+				InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+			},
+			FollowRedirects: true,
+			EnableHTTP2:     true,
+		}
+		if cfg.BasicAuth != nil {
+			httpConfig.BasicAuth = &promconfig.BasicAuth{
+				Username:     cfg.BasicAuth.Username,
+				PasswordFile: cfg.BasicAuth.PasswordFile,
+			}
+		}
+		if err := httpConfig.Validate(); err != nil {
+			return nil, "", fmt.Errorf("invalid prometheus client config: %v", err)
+		}
+		var err error
+		rt, err = promconfig.NewRoundTripperFromConfig(httpConfig, clientName)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	headers := http.Header{}
+	for name, value := range cfg.Headers {
+		headers.Set(name, value)
+	}
+	if cfg.TenantID != "" {
+		headers.Set(TenantHeader, cfg.TenantID)
+	}
+	if len(headers) > 0 {
+		rt = &headerRoundTripper{headers: headers, next: rt}
+	}
+	return rt, address, nil
+}
+
+// newServiceProxyRoundTripper accesses Prometheus by the apiserver with
+// /api/v1/namespaces/{namespace}/services/{scheme}:{name}:{port}/proxy.
+func newServiceProxyRoundTripper(proxy *config.ServiceProxyConfig) (http.RoundTripper, string, error) {
+	if proxy.RestConfig == nil {
+		return nil, "", fmt.Errorf("rest config is required for the service proxy")
+	}
+	if proxy.Namespace == "" || proxy.Name == "" {
+		return nil, "", fmt.Errorf("namespace and name of the service are required for the service proxy")
+	}
+	scheme := proxy.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	if scheme != "http" && scheme != "https" {
+		return nil, "", fmt.Errorf("scheme %v of the service is not supported", scheme)
+	}
+	rt, err := rest.TransportFor(proxy.RestConfig)
+	if err != nil {
+		return nil, "", err
+	}
+	host := proxy.RestConfig.Host
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	service := strings.Join([]string{scheme, proxy.Name, proxy.Port}, ":")
+	address := fmt.Sprintf("%s/api/v1/namespaces/%s/services/%s/proxy", strings.TrimSuffix(host, "/"),
+		url.PathEscape(proxy.Namespace), url.PathEscape(service))
+	return rt, address, nil
+}
+
+// headerRoundTripper sets headers on every request.
+type headerRoundTripper struct {
+	headers http.Header
+	next    http.RoundTripper
+}
+
+func (rt *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// requests must not be modified by round trippers
+	req = req.Clone(req.Context())
+	for name, values := range rt.headers {
+		req.Header[name] = values
+	}
+	return rt.next.RoundTrip(req)
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prometheus
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+
+	mp "github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/common"
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/config"
+)
+
+const emptyVector = `{"status":"success","data":{"resultType":"vector","result":[]}}`
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write %v: %v", name, err)
+	}
+	return path
+}
+
+func TestPrometheusProviderAuth(t *testing.T) {
+	tokenFile := writeFile(t, "token", "prom-token\n")
+	passwordFile := writeFile(t, "password", "secret")
+
+	tests := []struct {
+		name       string
+		config     func(address string) config.PrometheusProviderConfig
+		wantPath   string
+		wantHeader http.Header
+	}{
+		{
+			name: "bearer token and tenant",
+			config: func(address string) config.PrometheusProviderConfig {
+				return config.PrometheusProviderConfig{Address: address, BearerTokenFile: tokenFile, TenantID: "team-a"}
+			},
+			wantPath: "/api/v1/query",
+			wantHeader: http.Header{
+				"Authorization": {"Bearer prom-token"},
+				TenantHeader:    {"team-a"},
+			},
+		},
+		{
+			name: "basic auth and extra headers",
+			config: func(address string) config.PrometheusProviderConfig {
+				return config.PrometheusProviderConfig{
+					Address:   address,
+					BasicAuth: &config.BasicAuthConfig{Username: "admin", PasswordFile: passwordFile},
+					Headers:   map[string]string{"X-Custom": "value"},
+				}
+			},
+			wantPath: "/api/v1/query",
+			wantHeader: http.Header{
+				// base64 of admin:secret
+				"Authorization": {"Basic YWRtaW46c2VjcmV0"},
+				"X-Custom":      {"value"},
+			},
+		},
+		{
+			name: "service proxy",
+			config: func(address string) config.PrometheusProviderConfig {
+				return config.PrometheusProviderConfig{
+					Address: "http://ignored:9090",
+					ServiceProxy: &config.ServiceProxyConfig{
+						RestConfig: &rest.Config{Host: address, BearerToken: "apiserver-token"},
+						Namespace:  "monitoring",
+						Name:       "prometheus",
+						Port:       "9090",
+					},
+					TenantID: "team-b",
+				}
+			},
+			wantPath: "/api/v1/namespaces/monitoring/services/http:prometheus:9090/proxy/api/v1/query",
+			wantHeader: http.Header{
+				"Authorization": {"Bearer apiserver-token"},
+				TenantHeader:    {"team-b"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			var gotHeader http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath, gotHeader = r.URL.Path, r.Header
+				_, _ = w.Write([]byte(emptyVector))
+			}))
+			defer server.Close()
+
+			cfg := tt.config(server.URL)
+			cfg.QueryTimeout = time.Second
+			p, err := NewPrometheusProvider(cfg)
+			if err != nil {
+				t.Fatalf("NewPrometheusProvider() = %v", err)
+			}
+			if _, err := p.Query(mp.MetricQuery{MetricSelector: mp.MetricSelector{MetricName: "up"}}, nil); err != nil {
+				t.Fatalf("Query() = %v", err)
+			}
+			if gotPath != tt.wantPath {
+				t.Errorf("path = %v, want %v", gotPath, tt.wantPath)
+			}
+			for name, want := range tt.wantHeader {
+				if got := gotHeader.Values(name); len(got) != 1 || got[0] != want[0] {
+					t.Errorf("header %v = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPrometheusProviderTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(emptyVector))
+	}))
+	defer server.Close()
+	query := mp.MetricQuery{MetricSelector: mp.MetricSelector{MetricName: "up"}}
+
+	// the certificate of the test server is not trusted by the system
+	p, err := NewPrometheusProvider(config.PrometheusProviderConfig{Address: server.URL, QueryTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("NewPrometheusProvider() = %v", err)
+	}
+	if _, err := p.Query(query, nil); err == nil {
+		t.Errorf("Query() with an untrusted certificate should fail")
+	}
+
+	caFile := writeFile(t, "ca.crt", string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	})))
+	p, err = NewPrometheusProvider(config.PrometheusProviderConfig{
+		Address:      server.URL,
+		QueryTimeout: time.Second,
+		TLS:          config.TLSConfig{CAFile: caFile},
+	})
+	if err != nil {
+		t.Fatalf("NewPrometheusProvider() = %v", err)
+	}
+	if _, err := p.Query(query, nil); err != nil {
+		t.Errorf("Query() = %v", err)
+	}
+}
+
+func TestNewPrometheusProviderInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.PrometheusProviderConfig
+	}{
+		{
+			name: "bearer token with basic auth",
+			config: config.PrometheusProviderConfig{
+				Address:         "http://prometheus:9090",
+				BearerTokenFile: "/token",
+				BasicAuth:       &config.BasicAuthConfig{Username: "admin"},
+			},
+		},
+		{
+			name: "service proxy without rest config",
+			config: config.PrometheusProviderConfig{
+				ServiceProxy: &config.ServiceProxyConfig{Namespace: "monitoring", Name: "prometheus"},
+			},
+		},
+		{
+			name: "service proxy with unknown scheme",
+			config: config.PrometheusProviderConfig{
+				ServiceProxy: &config.ServiceProxyConfig{
+					RestConfig: &rest.Config{Host: "https://apiserver"},
+					Namespace:  "monitoring",
+					Name:       "prometheus",
+					Scheme:     "ftp",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPrometheusProvider(tt.config); err == nil {
+				t.Errorf("NewPrometheusProvider() should fail")
+			}
+		})
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	healthy := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(emptyVector))
+	}))
+	defer server.Close()
+
+	p, err := NewPrometheusProvider(config.PrometheusProviderConfig{Address: server.URL, QueryTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("NewPrometheusProvider() = %v", err)
+	}
+	if err := p.CheckHealth(context.Background()); err != nil {
+		t.Errorf("CheckHealth() = %v", err)
+	}
+	healthy = false
+	if err := p.CheckHealth(context.Background()); err == nil {
+		t.Errorf("CheckHealth() of unavailable prometheus should fail")
+	}
+}
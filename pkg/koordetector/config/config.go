@@ -25,20 +25,29 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 
 	"github.com/koordinator-sh/koordetector/pkg/features"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/metricsadvisor/framework"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/samplepusher"
 )
 
 type Configuration struct {
-	KubeRestConf *rest.Config
-	FeatureGates map[string]bool
+	KubeRestConf  *rest.Config
+	CollectorConf *framework.Config
+	PusherConf    *samplepusher.Config
+	FeatureGates  map[string]bool
 }
 
 func NewConfiguration() *Configuration {
-	return &Configuration{}
+	return &Configuration{
+		CollectorConf: framework.NewDefaultConfig(),
+		PusherConf:    samplepusher.NewDefaultConfig(),
+	}
 }
 
 func (c *Configuration) InitFlags(fs *flag.FlagSet) {
 	fs.Var(cliflag.NewMapStringBool(&c.FeatureGates), "feature-gates", "A set of key=value pairs that describe feature gates for alpha/experimental features. "+
 		"Options are:\n"+strings.Join(features.DefaultKoordetectorFeatureGate.KnownFeatures(), "\n"))
+	c.CollectorConf.InitFlags(fs)
+	c.PusherConf.InitFlags(fs)
 }
 
 func (c *Configuration) InitClient() error {
@@ -18,17 +18,20 @@ package koordetector
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 	"time"
 
-	"github.com/koordinator-sh/koordinator/pkg/koordlet/metricsadvisor"
 	apiruntime "k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientset "k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 
 	"github.com/koordinator-sh/koordetector/pkg/koordetector/config"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/metricsadvisor"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/samplepusher"
 	"github.com/koordinator-sh/koordetector/pkg/koordetector/statesinformer"
 )
 
@@ -42,11 +45,15 @@ func init() {
 
 type Daemon interface {
 	Run(stopCh <-chan struct{})
+	HealthzHandler() http.HandlerFunc
+	ReadyzHandler() http.HandlerFunc
 }
 
 type daemon struct {
 	collector      metricsadvisor.MetricAdvisor
 	statesInformer statesinformer.StatesInformer
+	// pusher is nil if samples are not pushed to the interference manager.
+	pusher samplepusher.SamplePusher
 }
 
 func NewDaemon(config *config.Configuration) (Daemon, error) {
@@ -63,13 +70,23 @@ func NewDaemon(config *config.Configuration) (Daemon, error) {
 	// only sync pod info
 	statesInformerConf := statesinformer.NewDefaultConfig()
 
-	statesInformer := statesinformer.NewStatesInformer(statesInformerConf, kubeClient, nodeName)
+	statesInformer, err := statesinformer.NewStatesInformer(statesInformerConf, kubeClient, nodeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to new states informer: %v", err)
+	}
 
-	// add metric collector
+	collector := metricsadvisor.NewMetricAdvisor(config.CollectorConf, statesInformer)
 
 	d := &daemon{
+		collector:      collector,
 		statesInformer: statesInformer,
 	}
+	if config.PusherConf.Address != "" {
+		d.pusher, err = samplepusher.NewSamplePusher(config.PusherConf, nodeName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to new sample pusher: %v", err)
+		}
+	}
 	return d, nil
 }
 
@@ -77,7 +94,53 @@ func (d *daemon) Run(stopCh <-chan struct{}) {
 	defer utilruntime.HandleCrash()
 	klog.Infof("Starting daemon")
 
+	go func() {
+		if err := d.statesInformer.Run(stopCh); err != nil {
+			klog.Fatal("Unable to run the states informer: ", err)
+		}
+	}()
+	// wait for states informer synced
+	if !cache.WaitForCacheSync(stopCh, d.statesInformer.HasSynced) {
+		klog.Error("time out waiting for states informer to sync")
+		return
+	}
+
+	// collectors run independently, the failures of which are reported by metrics
+	go func() {
+		if err := d.collector.Run(stopCh); err != nil {
+			klog.Fatal("Unable to run the metric advisor: ", err)
+		}
+	}()
+
+	if d.pusher != nil {
+		go d.pusher.Run(stopCh)
+	}
+
 	klog.Info("Start daemon successfully")
 	<-stopCh
 	klog.Info("Shutting down daemon")
 }
+
+// HealthzHandler reports the daemon as healthy as long as it serves. The failures of collectors are reported by
+// ReadyzHandler and metrics instead, since restarting the daemon does not help with them, e.g. on nodes without eBPF.
+func (d *daemon) HealthzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}
+}
+
+// ReadyzHandler reports the daemon as not ready until the states informer has synced, or while any enabled
+// collector fails to set up or to collect. Collectors not supported by the node should be disabled by feature gates.
+func (d *daemon) ReadyzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !d.statesInformer.HasSynced() {
+			http.Error(w, "states informer has not synced", http.StatusServiceUnavailable)
+			return
+		}
+		if err := d.collector.Health(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package koordetector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/metricsadvisor"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/statesinformer"
+)
+
+type testStatesInformer struct {
+	statesinformer.StatesInformer
+	running  *atomic.Bool
+	syncable bool
+	synced   *atomic.Bool
+}
+
+func newTestStatesInformer(syncable bool) *testStatesInformer {
+	return &testStatesInformer{
+		running:  atomic.NewBool(false),
+		syncable: syncable,
+		synced:   atomic.NewBool(false),
+	}
+}
+
+func (s *testStatesInformer) Run(stopCh <-chan struct{}) error {
+	s.running.Store(true)
+	s.synced.Store(s.syncable)
+	<-stopCh
+	s.running.Store(false)
+	return nil
+}
+
+func (s *testStatesInformer) HasSynced() bool {
+	return s.synced.Load()
+}
+
+type testMetricAdvisor struct {
+	metricsadvisor.MetricAdvisor
+	running *atomic.Bool
+	health  error
+}
+
+func newTestMetricAdvisor() *testMetricAdvisor {
+	return &testMetricAdvisor{running: atomic.NewBool(false)}
+}
+
+func (m *testMetricAdvisor) Run(stopCh <-chan struct{}) error {
+	m.running.Store(true)
+	<-stopCh
+	m.running.Store(false)
+	return nil
+}
+
+func (m *testMetricAdvisor) Health() error {
+	return m.health
+}
+
+func TestDaemonRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		syncable bool
+	}{
+		{name: "states informer synced", syncable: true},
+		{name: "stopped before states informer synced", syncable: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			informer := newTestStatesInformer(tt.syncable)
+			collector := newTestMetricAdvisor()
+			d := &daemon{statesInformer: informer, collector: collector}
+			stopCh := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				d.Run(stopCh)
+				close(done)
+			}()
+			assert.Eventually(t, informer.running.Load, 5*time.Second, 10*time.Millisecond)
+			if tt.syncable {
+				// collectors run once the states informer has synced
+				assert.Eventually(t, collector.running.Load, 5*time.Second, 10*time.Millisecond)
+			} else {
+				assert.Never(t, collector.running.Load, 100*time.Millisecond, 10*time.Millisecond)
+			}
+			close(stopCh)
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("daemon is not stopped")
+			}
+		})
+	}
+}
+
+func TestDaemonProbes(t *testing.T) {
+	informer := newTestStatesInformer(true)
+	collector := newTestMetricAdvisor()
+	collector.health = fmt.Errorf("collector test is unhealthy")
+	d := &daemon{statesInformer: informer, collector: collector}
+	healthz, readyz := d.HealthzHandler(), d.ReadyzHandler()
+
+	// the daemon is alive whether the states informer has synced or collectors fail, but not ready
+	w := httptest.NewRecorder()
+	healthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	readyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "states informer has not synced")
+
+	informer.synced.Store(true)
+	w = httptest.NewRecorder()
+	readyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "collector test is unhealthy")
+
+	collector.health = nil
+	w = httptest.NewRecorder()
+	readyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	healthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	CollectorKey = "collector"
+	StatusKey    = "status"
+
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+var (
+	// CollectorStatus counts the results of collectors, so that collectors failing to set up, e.g. on kernels
+	// without eBPF, or to collect are reported without failing the health check of the daemon.
+	CollectorStatus = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: KoordetectorSubsystem,
+		Name:      "collector_status_total",
+		Help:      "Number of set-ups and collections of collectors by status",
+	}, []string{CollectorKey, StatusKey})
+
+	CollectorCollectors = []prometheus.Collector{
+		CollectorStatus,
+	}
+)
+
+// RecordCollectorStatus records the result of setting up the collector or of a collection.
+func RecordCollectorStatus(collector string, err error) {
+	status := StatusSucceeded
+	if err != nil {
+		status = StatusFailed
+	}
+	CollectorStatus.WithLabelValues(collector, status).Inc()
+}
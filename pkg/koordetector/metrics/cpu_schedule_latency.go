@@ -0,0 +1,133 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	ContainerCPUScheduleLatency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: KoordetectorSubsystem,
+		Name:      "container_cpu_schedule_latency_seconds",
+		Help:      "Average time the tasks of the container wait in the run queue before running in the last collect interval, collected by eBPF",
+	}, []string{NodeKey, ContainerID, ContainerName, PodUID, PodName, PodNamespace})
+
+	PodCPUScheduleLatency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: KoordetectorSubsystem,
+		Name:      "pod_cpu_schedule_latency_seconds",
+		Help:      "Average time the tasks of the pod wait in the run queue before running in the last collect interval, collected by eBPF",
+	}, []string{NodeKey, PodUID, PodName, PodNamespace})
+
+	CPUScheduleLatencyCollectors = []prometheus.Collector{
+		ContainerCPUScheduleLatency,
+		PodCPUScheduleLatency,
+	}
+
+	containerCPUScheduleLatencySeries = newGaugeSeries(ContainerCPUScheduleLatency)
+	podCPUScheduleLatencySeries       = newGaugeSeries(PodCPUScheduleLatency)
+)
+
+func RecordContainerCPUScheduleLatency(nodeName string, status *corev1.ContainerStatus, pod *corev1.Pod, seconds float64) {
+	containerCPUScheduleLatencySeries.set(prometheus.Labels{
+		NodeKey:       nodeName,
+		ContainerID:   status.ContainerID,
+		ContainerName: status.Name,
+		PodUID:        string(pod.UID),
+		PodName:       pod.Name,
+		PodNamespace:  pod.Namespace,
+	}, seconds)
+}
+
+func RecordPodCPUScheduleLatency(nodeName string, pod *corev1.Pod, seconds float64) {
+	podCPUScheduleLatencySeries.set(prometheus.Labels{
+		NodeKey:      nodeName,
+		PodUID:       string(pod.UID),
+		PodName:      pod.Name,
+		PodNamespace: pod.Namespace,
+	}, seconds)
+}
+
+// FlushCPUScheduleLatency deletes the series of the containers and pods not recorded since the last flush, e.g.
+// the ones no longer running. Unlike resetting the gauges before recording, scrapes never see the series of
+// running containers disappear in the middle of a collection.
+func FlushCPUScheduleLatency() {
+	containerCPUScheduleLatencySeries.flush()
+	podCPUScheduleLatencySeries.flush()
+}
+
+// ResetCPUScheduleLatency deletes all series, e.g. when the latency can not be read, so that stale values are not
+// exported as the latency of the last interval.
+func ResetCPUScheduleLatency() {
+	containerCPUScheduleLatencySeries.reset()
+	podCPUScheduleLatencySeries.reset()
+}
+
+// gaugeSeries tracks the series set on a gauge vec between flushes.
+type gaugeSeries struct {
+	lock     sync.Mutex
+	vec      *prometheus.GaugeVec
+	flushed  map[string]prometheus.Labels
+	recorded map[string]prometheus.Labels
+}
+
+func newGaugeSeries(vec *prometheus.GaugeVec) *gaugeSeries {
+	return &gaugeSeries{
+		vec:      vec,
+		flushed:  map[string]prometheus.Labels{},
+		recorded: map[string]prometheus.Labels{},
+	}
+}
+
+func (s *gaugeSeries) set(labels prometheus.Labels, value float64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.vec.With(labels).Set(value)
+	s.recorded[seriesKey(labels)] = labels
+}
+
+func (s *gaugeSeries) flush() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for key, labels := range s.flushed {
+		if _, ok := s.recorded[key]; !ok {
+			s.vec.Delete(labels)
+		}
+	}
+	s.flushed, s.recorded = s.recorded, map[string]prometheus.Labels{}
+}
+
+func (s *gaugeSeries) reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.vec.Reset()
+	s.flushed, s.recorded = map[string]prometheus.Labels{}, map[string]prometheus.Labels{}
+}
+
+// seriesKey identifies a series of the cpu schedule latency gauges by joining its label values.
+func seriesKey(labels prometheus.Labels) string {
+	var b strings.Builder
+	for _, name := range []string{NodeKey, ContainerID, ContainerName, PodUID, PodName, PodNamespace} {
+		b.WriteString(labels[name])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	prometheus.MustRegister(CPUScheduleLatencyCollectors...)
+	prometheus.MustRegister(CollectorCollectors...)
+}
+
+const (
+	KoordetectorSubsystem = "koordetector"
+
+	// labels are the same as those of koordlet, so that the interference manager queries metrics of both alike
+
+	NodeKey = "node"
+
+	ContainerID   = "container_id"
+	ContainerName = "container_name"
+
+	PodUID       = "pod_uid"
+	PodName      = "pod_name"
+	PodNamespace = "pod_namespace"
+)
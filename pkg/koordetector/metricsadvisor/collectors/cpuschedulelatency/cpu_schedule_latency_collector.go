@@ -0,0 +1,173 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpuschedulelatency
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	koordletutil "github.com/koordinator-sh/koordinator/pkg/koordlet/util"
+	"github.com/koordinator-sh/koordinator/pkg/koordlet/util/system"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"github.com/koordinator-sh/koordetector/pkg/features"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/metrics"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/metricsadvisor/framework"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/statesinformer"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/util/cpu_schedule_latency"
+)
+
+const (
+	CollectorName = "CPUScheduleLatencyCollector"
+)
+
+// latencyReader reads the schedule latency of cgroups, which is implemented by the eBPF program.
+type latencyReader interface {
+	GetCgroupScheduleLatency(cgroupIDs []uint64) (map[uint64]cpu_schedule_latency.CgroupScheduleLatency, error)
+	DestroyEBPFProg() error
+}
+
+var newLatencyReader = func() (latencyReader, error) {
+	return cpu_schedule_latency.NewCSLeBPFProg()
+}
+
+type collector struct {
+	framework.CollectorStatus
+	interval       time.Duration
+	statesInformer statesinformer.StatesInformer
+	reader         latencyReader
+}
+
+func New(opt *framework.Options) framework.Collector {
+	return &collector{
+		interval:       opt.Config.IntervalOf(CollectorName),
+		statesInformer: opt.StatesInformer,
+	}
+}
+
+// Enabled returns false on kernels earlier than cpu_schedule_latency.MinKernelVersion, e.g. the 4.19 and 5.4 LTS,
+// where the eBPF program can not be loaded or its cgroup IDs can not be matched.
+func (c *collector) Enabled() bool {
+	if !features.DefaultKoordetectorFeatureGate.Enabled(features.CPUScheduleLatencyCollector) {
+		return false
+	}
+	if err := cpu_schedule_latency.CheckKernelVersion(); err != nil {
+		klog.Warningf("collector %s is disabled, %v", CollectorName, err)
+		return false
+	}
+	return true
+}
+
+// Setup loads the eBPF program and attaches it to the scheduler tracepoints.
+func (c *collector) Setup(ctx *framework.Context) error {
+	reader, err := newLatencyReader()
+	if err != nil {
+		return err
+	}
+	c.reader = reader
+	return nil
+}
+
+func (c *collector) Run(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		err := c.collect()
+		if err != nil {
+			klog.Warningf("failed to collect cpu schedule latency, err: %v", err)
+		}
+		c.Record(err)
+		metrics.RecordCollectorStatus(CollectorName, err)
+	}, c.interval, stopCh)
+}
+
+func (c *collector) Shutdown() {
+	if c.reader == nil {
+		return
+	}
+	if err := c.reader.DestroyEBPFProg(); err != nil {
+		klog.Errorf("failed to destroy cpu schedule latency eBPF program, err: %v", err)
+	}
+}
+
+type containerMeta struct {
+	pod    *corev1.Pod
+	status *corev1.ContainerStatus
+}
+
+// collect gets the schedule latency of running containers in the last interval, whose cgroups are matched by the
+// IDs of their cpu cgroup directories, and the latency of their pods summed from the containers.
+func (c *collector) collect() error {
+	node := c.statesInformer.GetNode()
+	if node == nil {
+		return fmt.Errorf("node has not synced")
+	}
+	cgroupRootDir := koordletutil.GetRootCgroupSubfsDir(system.CgroupCPUDir)
+	containers := map[uint64]containerMeta{}
+	var cgroupIDs []uint64
+	for _, podMeta := range c.statesInformer.GetAllPods() {
+		pod := podMeta.Pod
+		for i := range pod.Status.ContainerStatuses {
+			status := &pod.Status.ContainerStatuses[i]
+			if status.ContainerID == "" || status.State.Running == nil {
+				continue
+			}
+			containerDir, err := koordletutil.GetContainerCgroupPathWithKubeByID(podMeta.CgroupDir, status.ContainerID)
+			if err != nil {
+				klog.V(4).Infof("failed to get cgroup dir of container %s/%s/%s, err: %v",
+					pod.Namespace, pod.Name, status.Name, err)
+				continue
+			}
+			// the cgroup may have been removed since the container exits
+			cgroupID, err := cpu_schedule_latency.GetCgroupID(filepath.Join(cgroupRootDir, containerDir))
+			if err != nil {
+				klog.V(4).Infof("failed to get cgroup id of container %s/%s/%s, err: %v",
+					pod.Namespace, pod.Name, status.Name, err)
+				continue
+			}
+			containers[cgroupID] = containerMeta{pod: pod, status: status}
+			cgroupIDs = append(cgroupIDs, cgroupID)
+		}
+	}
+
+	latencies, err := c.reader.GetCgroupScheduleLatency(cgroupIDs)
+	if err != nil {
+		// the maps are deleted in a failed read, the last values would be exported as stale
+		metrics.ResetCPUScheduleLatency()
+		return fmt.Errorf("failed to get cgroup schedule latency: %v", err)
+	}
+	pods := map[*corev1.Pod]cpu_schedule_latency.CgroupScheduleLatency{}
+	for cgroupID, latency := range latencies {
+		meta, ok := containers[cgroupID]
+		if !ok {
+			continue
+		}
+		metrics.RecordContainerCPUScheduleLatency(node.Name, meta.status, meta.pod, latency.Avg()/float64(time.Second))
+		podLatency := pods[meta.pod]
+		podLatency.Delay += latency.Delay
+		podLatency.Counter += latency.Counter
+		pods[meta.pod] = podLatency
+	}
+	for pod, latency := range pods {
+		metrics.RecordPodCPUScheduleLatency(node.Name, pod, latency.Avg()/float64(time.Second))
+	}
+	// containers and pods no longer running are removed
+	metrics.FlushCPUScheduleLatency()
+	klog.V(5).Infof("collected cpu schedule latency of %d containers of %d pods", len(latencies), len(pods))
+	return nil
+}
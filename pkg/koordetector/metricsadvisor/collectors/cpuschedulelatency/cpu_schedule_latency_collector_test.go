@@ -0,0 +1,164 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpuschedulelatency
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	koordletutil "github.com/koordinator-sh/koordinator/pkg/koordlet/util"
+	"github.com/koordinator-sh/koordinator/pkg/koordlet/util/system"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/metrics"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/metricsadvisor/framework"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/statesinformer"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/util/cpu_schedule_latency"
+)
+
+type testStatesInformer struct {
+	statesinformer.StatesInformer
+	node *corev1.Node
+	pods []*statesinformer.PodMeta
+}
+
+func (s *testStatesInformer) GetNode() *corev1.Node {
+	return s.node
+}
+
+func (s *testStatesInformer) GetAllPods() []*statesinformer.PodMeta {
+	return s.pods
+}
+
+type testLatencyReader struct {
+	latencies map[uint64]cpu_schedule_latency.CgroupScheduleLatency
+	err       error
+	cgroupIDs []uint64
+	destroyed bool
+}
+
+func (r *testLatencyReader) GetCgroupScheduleLatency(cgroupIDs []uint64) (map[uint64]cpu_schedule_latency.CgroupScheduleLatency, error) {
+	r.cgroupIDs = cgroupIDs
+	return r.latencies, r.err
+}
+
+func (r *testLatencyReader) DestroyEBPFProg() error {
+	r.destroyed = true
+	return nil
+}
+
+func newTestPodMeta(name string, running bool, containerIDs ...string) *statesinformer.PodMeta {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID("uid-" + name)},
+	}
+	for i, containerID := range containerIDs {
+		status := corev1.ContainerStatus{Name: fmt.Sprintf("main-%d", i), ContainerID: containerID}
+		if running {
+			status.State.Running = &corev1.ContainerStateRunning{}
+		}
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, status)
+	}
+	return &statesinformer.PodMeta{
+		Pod:       pod,
+		CgroupDir: "kubepods.slice/kubepods-pod" + name + ".slice",
+	}
+}
+
+// createContainerCgroup creates the cpu cgroup directory of the i-th container and returns its ID.
+func createContainerCgroup(t *testing.T, podMeta *statesinformer.PodMeta, i int) uint64 {
+	dir, err := koordletutil.GetContainerCgroupPathWithKubeByID(podMeta.CgroupDir, podMeta.Pod.Status.ContainerStatuses[i].ContainerID)
+	assert.NoError(t, err)
+	dir = filepath.Join(koordletutil.GetRootCgroupSubfsDir(system.CgroupCPUDir), dir)
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	id, err := cpu_schedule_latency.GetCgroupID(dir)
+	assert.NoError(t, err)
+	return id
+}
+
+func TestCollect(t *testing.T) {
+	oldCgroupRootDir := system.Conf.CgroupRootDir
+	system.Conf.CgroupRootDir = t.TempDir()
+	defer func() {
+		system.Conf.CgroupRootDir = oldCgroupRootDir
+	}()
+
+	running := newTestPodMeta("running", true, "containerd://aaa", "containerd://eee")
+	stopped := newTestPodMeta("stopped", false, "containerd://bbb")
+	removed := newTestPodMeta("removed", true, "containerd://ccc")
+	gone := newTestPodMeta("gone", true, "containerd://ddd")
+	runningIDs := []uint64{createContainerCgroup(t, running, 0), createContainerCgroup(t, running, 1)}
+	goneID := createContainerCgroup(t, gone, 0)
+	reader := &testLatencyReader{
+		latencies: map[uint64]cpu_schedule_latency.CgroupScheduleLatency{
+			runningIDs[0]: {Delay: uint64(4 * time.Millisecond), Counter: 2},
+			runningIDs[1]: {},
+			goneID:        {Delay: uint64(time.Millisecond), Counter: 1},
+			goneID + 1000: {Delay: uint64(time.Second), Counter: 1},
+		},
+	}
+	informer := &testStatesInformer{
+		node: &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}},
+		pods: []*statesinformer.PodMeta{running, stopped, removed, gone},
+	}
+	c := New(&framework.Options{
+		Config:         framework.NewDefaultConfig(),
+		StatesInformer: informer,
+	}).(*collector)
+	c.reader = reader
+
+	assert.NoError(t, c.collect())
+	assert.Equal(t, append(runningIDs, goneID), reader.cgroupIDs)
+	assert.Equal(t, 3, testutil.CollectAndCount(metrics.ContainerCPUScheduleLatency))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.PodCPUScheduleLatency))
+
+	// series of containers and pods no longer running are removed, while the others are kept
+	informer.pods = []*statesinformer.PodMeta{running, stopped, removed}
+	assert.NoError(t, c.collect())
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.ContainerCPUScheduleLatency))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.PodCPUScheduleLatency))
+	gauge := metrics.ContainerCPUScheduleLatency.WithLabelValues("test-node", "containerd://aaa", "main-0",
+		"uid-running", "running", "default")
+	assert.InDelta(t, 0.002, testutil.ToFloat64(gauge), 1e-9)
+	// the pod latency is averaged over the switches of all containers
+	podGauge := metrics.PodCPUScheduleLatency.WithLabelValues("test-node", "uid-running", "running", "default")
+	assert.InDelta(t, 0.002, testutil.ToFloat64(podGauge), 1e-9)
+
+	// stale values are not exported if the latency can not be read
+	reader.err = fmt.Errorf("map not found")
+	assert.Error(t, c.collect())
+	assert.Equal(t, 0, testutil.CollectAndCount(metrics.ContainerCPUScheduleLatency))
+	assert.Equal(t, 0, testutil.CollectAndCount(metrics.PodCPUScheduleLatency))
+
+	c.Shutdown()
+	assert.True(t, reader.destroyed)
+}
+
+func TestCollectNodeNotSynced(t *testing.T) {
+	c := New(&framework.Options{
+		Config:         framework.NewDefaultConfig(),
+		StatesInformer: &testStatesInformer{},
+	}).(*collector)
+	c.reader = &testLatencyReader{}
+	assert.Error(t, c.collect())
+}
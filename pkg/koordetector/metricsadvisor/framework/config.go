@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	// CollectInterval is the default interval of collectors, collectors are disabled if it is not positive.
+	CollectInterval time.Duration
+	// CollectorIntervals are the intervals of collectors by names, which override CollectInterval.
+	CollectorIntervals map[string]time.Duration
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		CollectInterval:    10 * time.Second,
+		CollectorIntervals: map[string]time.Duration{},
+	}
+}
+
+func (c *Config) InitFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&c.CollectInterval, "collect-interval", c.CollectInterval, "The default interval at which collectors collect metrics, all collectors are disabled if it is not positive. Non-zero values should contain a corresponding time unit (e.g. 1s, 2m, 3h).")
+	fs.Var((*intervalsValue)(&c.CollectorIntervals), "collector-intervals", "The intervals of collectors by names which override the default interval, e.g. CPUScheduleLatencyCollector=30s.")
+}
+
+// IntervalOf returns the interval of the collector, which is CollectInterval unless it is overridden.
+func (c *Config) IntervalOf(collector string) time.Duration {
+	if interval, ok := c.CollectorIntervals[collector]; ok {
+		return interval
+	}
+	return c.CollectInterval
+}
+
+// intervalsValue parses the intervals of collectors in the form of name=duration separated by commas.
+type intervalsValue map[string]time.Duration
+
+func (v *intervalsValue) String() string {
+	if v == nil || *v == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(*v))
+	for name, interval := range *v {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", name, interval))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v *intervalsValue) Set(value string) error {
+	intervals := map[string]time.Duration{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("invalid collector interval %q, expected name=duration", pair)
+		}
+		interval, err := time.ParseDuration(kv[1])
+		if err != nil {
+			return fmt.Errorf("invalid interval of collector %v: %v", kv[0], err)
+		}
+		if interval <= 0 {
+			return fmt.Errorf("interval of collector %v must be positive, got %v", kv[0], interval)
+		}
+		intervals[kv[0]] = interval
+	}
+	*v = intervals
+	return nil
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigCollectorIntervals(t *testing.T) {
+	c := NewDefaultConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c.InitFlags(fs)
+	assert.NoError(t, fs.Parse([]string{"--collect-interval=20s", "--collector-intervals=Slow=1m, Fast=1s"}))
+	assert.Equal(t, time.Minute, c.IntervalOf("Slow"))
+	assert.Equal(t, time.Second, c.IntervalOf("Fast"))
+	assert.Equal(t, 20*time.Second, c.IntervalOf("Other"))
+	assert.Equal(t, "Fast=1s,Slow=1m0s", fs.Lookup("collector-intervals").Value.String())
+
+	for _, invalid := range []string{"Slow", "=1s", "Slow=fast", "Slow=0s"} {
+		assert.Error(t, fs.Set("collector-intervals", invalid), invalid)
+	}
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+// CollectorFactory creates a collector plugin, which is registered in the metric advisor by name.
+type CollectorFactory = func(opt *Options) Collector
+
+// Collector is a plugin collecting a kind of metrics, e.g. eBPF schedule latency, PSI or perf counters. The
+// metric advisor sets up and runs each enabled collector independently.
+type Collector interface {
+	// Enabled returns whether the collector is enabled, collectors are usually guarded by feature gates.
+	Enabled() bool
+	// Setup prepares the collector before it runs, e.g. loading eBPF programs. Collectors failing to set up
+	// are not run.
+	Setup(ctx *Context) error
+	// Run collects metrics every interval until the stop channel is closed.
+	Run(stopCh <-chan struct{})
+	// Shutdown releases the resources acquired in Setup after Run returns.
+	Shutdown()
+	// Health returns the error of the latest collection, or nil if it succeeded.
+	Health() error
+}
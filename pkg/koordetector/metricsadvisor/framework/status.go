@@ -0,0 +1,40 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"sync"
+)
+
+// CollectorStatus records the results of collections, collectors embed it to implement Health.
+type CollectorStatus struct {
+	lock      sync.RWMutex
+	lastError error
+}
+
+// Record records the result of a collection.
+func (s *CollectorStatus) Record(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lastError = err
+}
+
+func (s *CollectorStatus) Health() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.lastError
+}
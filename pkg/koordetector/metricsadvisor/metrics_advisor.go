@@ -0,0 +1,164 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsadvisor
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/klog/v2"
+
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/metrics"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/metricsadvisor/collectors/cpuschedulelatency"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/metricsadvisor/framework"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/statesinformer"
+)
+
+// MetricAdvisor manages the collector plugins of koordetector.
+type MetricAdvisor interface {
+	Run(stopCh <-chan struct{}) error
+	// Health returns the errors of collectors failing to set up or in their latest collections, which are also
+	// counted in the metrics of collectors.
+	Health() error
+}
+
+// collectorPlugins are the collectors of koordetector, new collectors are registered here.
+var collectorPlugins = map[string]framework.CollectorFactory{
+	cpuschedulelatency.CollectorName: cpuschedulelatency.New,
+}
+
+type metricAdvisor struct {
+	options *framework.Options
+	context *framework.Context
+
+	lock sync.RWMutex
+	// running are the collectors set up successfully.
+	running     map[string]framework.Collector
+	setupErrors map[string]error
+}
+
+func NewMetricAdvisor(cfg *framework.Config, statesInformer statesinformer.StatesInformer) MetricAdvisor {
+	return newMetricAdvisor(cfg, statesInformer, collectorPlugins)
+}
+
+func newMetricAdvisor(cfg *framework.Config, statesInformer statesinformer.StatesInformer,
+	plugins map[string]framework.CollectorFactory) *metricAdvisor {
+	opt := &framework.Options{
+		Config:         cfg,
+		StatesInformer: statesInformer,
+	}
+	ctx := &framework.Context{
+		Collectors: make(map[string]framework.Collector, len(plugins)),
+	}
+	for name, factory := range plugins {
+		collector := factory(opt)
+		if !collector.Enabled() {
+			klog.V(4).Infof("collector %v is not enabled, skip running", name)
+			continue
+		}
+		ctx.Collectors[name] = collector
+	}
+	return &metricAdvisor{
+		options:     opt,
+		context:     ctx,
+		running:     map[string]framework.Collector{},
+		setupErrors: map[string]error{},
+	}
+}
+
+func (m *metricAdvisor) Run(stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+	if m.options.Config.CollectInterval <= 0 {
+		klog.Infof("CollectInterval is %v, metric collectors are disabled", m.options.Config.CollectInterval)
+		return nil
+	}
+
+	m.setup()
+	defer m.shutdown()
+	defer klog.Info("shutting down metric advisor")
+
+	var wg sync.WaitGroup
+	m.lock.RLock()
+	for name, collector := range m.running {
+		wg.Add(1)
+		go func(name string, collector framework.Collector) {
+			defer wg.Done()
+			defer utilruntime.HandleCrash()
+			collector.Run(stopCh)
+			klog.V(4).Infof("collector %v stopped", name)
+		}(name, collector)
+		klog.V(4).Infof("collector %v start", name)
+	}
+	m.lock.RUnlock()
+
+	klog.Info("Starting metric advisor successfully")
+	<-stopCh
+	wg.Wait()
+	return nil
+}
+
+// setup sets up the enabled collectors in the order of names, collectors failing to set up are not run.
+func (m *metricAdvisor) setup() {
+	names := make([]string, 0, len(m.context.Collectors))
+	for name := range m.context.Collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, name := range names {
+		collector := m.context.Collectors[name]
+		if err := collector.Setup(m.context); err != nil {
+			klog.Errorf("failed to setup collector %v, skip running, err: %v", name, err)
+			metrics.RecordCollectorStatus(name, err)
+			m.setupErrors[name] = err
+			continue
+		}
+		m.running[name] = collector
+	}
+}
+
+func (m *metricAdvisor) shutdown() {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for name, collector := range m.running {
+		collector.Shutdown()
+		klog.V(4).Infof("collector %v shut down", name)
+	}
+}
+
+func (m *metricAdvisor) Health() error {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	var errs []error
+	for name, err := range m.setupErrors {
+		errs = append(errs, fmt.Errorf("collector %v failed to set up: %v", name, err))
+	}
+	for name, collector := range m.running {
+		if err := collector.Health(); err != nil {
+			errs = append(errs, fmt.Errorf("collector %v is unhealthy: %v", name, err))
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsadvisor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/metrics"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/metricsadvisor/framework"
+)
+
+type testCollector struct {
+	framework.CollectorStatus
+	enabled  bool
+	setupErr error
+	collects []error
+
+	setupCtx *framework.Context
+	running  *atomic.Bool
+	shutdown *atomic.Bool
+}
+
+func newTestCollector(enabled bool, setupErr error, collects ...error) *testCollector {
+	return &testCollector{
+		enabled:  enabled,
+		setupErr: setupErr,
+		collects: collects,
+		running:  atomic.NewBool(false),
+		shutdown: atomic.NewBool(false),
+	}
+}
+
+func (c *testCollector) Enabled() bool {
+	return c.enabled
+}
+
+func (c *testCollector) Setup(ctx *framework.Context) error {
+	c.setupCtx = ctx
+	return c.setupErr
+}
+
+func (c *testCollector) Run(stopCh <-chan struct{}) {
+	for _, err := range c.collects {
+		c.Record(err)
+	}
+	c.running.Store(true)
+	<-stopCh
+	c.running.Store(false)
+}
+
+func (c *testCollector) Shutdown() {
+	c.shutdown.Store(true)
+}
+
+func TestMetricAdvisorRun(t *testing.T) {
+	healthy := newTestCollector(true, nil, nil)
+	unhealthy := newTestCollector(true, nil, nil, fmt.Errorf("read map failed"))
+	setupFailed := newTestCollector(true, fmt.Errorf("kernel not supported"))
+	disabled := newTestCollector(false, nil)
+	m := newMetricAdvisor(framework.NewDefaultConfig(), nil, map[string]framework.CollectorFactory{
+		"healthy":      func(opt *framework.Options) framework.Collector { return healthy },
+		"unhealthy":    func(opt *framework.Options) framework.Collector { return unhealthy },
+		"setup-failed": func(opt *framework.Options) framework.Collector { return setupFailed },
+		"disabled":     func(opt *framework.Options) framework.Collector { return disabled },
+	})
+	assert.Len(t, m.context.Collectors, 3)
+	assert.NotContains(t, m.context.Collectors, "disabled")
+
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		assert.NoError(t, m.Run(stopCh))
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		return healthy.running.Load() && unhealthy.running.Load()
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.False(t, setupFailed.running.Load())
+	assert.False(t, disabled.running.Load())
+	assert.Same(t, m.context, healthy.setupCtx)
+
+	err := m.Health()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "collector setup-failed failed to set up: kernel not supported")
+	assert.Contains(t, err.Error(), "collector unhealthy is unhealthy: read map failed")
+	assert.NotContains(t, err.Error(), "collector healthy ")
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.CollectorStatus.WithLabelValues("setup-failed", metrics.StatusFailed)))
+
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("metric advisor is not stopped")
+	}
+	assert.True(t, healthy.shutdown.Load())
+	assert.True(t, unhealthy.shutdown.Load())
+	assert.False(t, setupFailed.shutdown.Load())
+}
+
+func TestMetricAdvisorDisabled(t *testing.T) {
+	collector := newTestCollector(true, nil)
+	m := newMetricAdvisor(&framework.Config{CollectInterval: 0}, nil, map[string]framework.CollectorFactory{
+		"test": func(opt *framework.Options) framework.Collector { return collector },
+	})
+	assert.NoError(t, m.Run(make(chan struct{})))
+	assert.Nil(t, collector.setupCtx)
+	assert.NoError(t, m.Health())
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package samplepusher
+
+import (
+	"flag"
+	"time"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/ingestion"
+)
+
+type Config struct {
+	// Address is the ingestion endpoint of the interference manager, e.g. https://interference-manager:9091.
+	// Samples are not pushed if it is empty.
+	Address string
+	// TokenFile is the service account token issued for ingestion.DefaultAudience.
+	TokenFile string
+	// CAFile verifies the ingestion endpoint, the system roots are used if it is empty.
+	CAFile       string
+	PushInterval time.Duration
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		TokenFile:    ingestion.DefaultTokenFile,
+		PushInterval: 30 * time.Second,
+	}
+}
+
+func (c *Config) InitFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Address, "ingestion-address", c.Address, "The https address of the ingestion endpoint of the interference manager, e.g. https://interference-manager:9091. Samples are not pushed if it is empty.")
+	fs.StringVar(&c.TokenFile, "ingestion-token-file", c.TokenFile, "The service account token to push samples with, which is projected for the audience of the interference manager.")
+	fs.StringVar(&c.CAFile, "ingestion-ca-file", c.CAFile, "The CA file to verify the ingestion endpoint, the system roots are used if it is empty.")
+	fs.DurationVar(&c.PushInterval, "ingestion-push-interval", c.PushInterval, "The interval at which samples are pushed to the interference manager. Non-zero values should contain a corresponding time unit (e.g. 1s, 2m, 3h).")
+}
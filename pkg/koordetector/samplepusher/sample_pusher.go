@@ -0,0 +1,195 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package samplepusher
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/ingestion"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/metrics"
+)
+
+const (
+	// maxBatchSamples is the maximum number of samples pushed in a batch, which is well below the limit of the
+	// server ingestion.DefaultMaxBatchSamples.
+	maxBatchSamples = ingestion.DefaultMaxBatchSamples / 5
+	// maxPendingSamples bounds the samples kept for the next push when the server is busy or unreachable, the
+	// oldest ones are dropped first. They are pushed in multiple batches.
+	maxPendingSamples = 10000
+	pushTimeout       = 10 * time.Second
+)
+
+// SamplePusher pushes the container metrics collected by koordetector to the ingestion endpoint of the interference
+// manager, so that clusters without Prometheus can run interference detection.
+type SamplePusher interface {
+	Run(stopCh <-chan struct{})
+}
+
+// batchPusher is implemented by ingestion.Client.
+type batchPusher interface {
+	Push(ctx context.Context, batch *ingestion.SampleBatch) error
+}
+
+type samplePusher struct {
+	nodeName string
+	interval time.Duration
+	gatherer prometheus.Gatherer
+	client   batchPusher
+	// pending are the samples failed to push, which are pushed with the next batch.
+	pending []ingestion.Sample
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// NewSamplePusher constructs a SamplePusher pushing the metrics registered in the default registry.
+func NewSamplePusher(cfg *Config, nodeName string) (SamplePusher, error) {
+	if cfg.PushInterval <= 0 {
+		return nil, fmt.Errorf("push interval must be positive")
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca file: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in ca file %v", cfg.CAFile)
+		}
+	}
+	httpClient := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		Timeout:   pushTimeout,
+	}
+	client, err := ingestion.NewClient(cfg.Address, cfg.TokenFile, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return newSamplePusher(nodeName, cfg.PushInterval, prometheus.DefaultGatherer, client), nil
+}
+
+func newSamplePusher(nodeName string, interval time.Duration, gatherer prometheus.Gatherer, client batchPusher) *samplePusher {
+	return &samplePusher{
+		nodeName: nodeName,
+		interval: interval,
+		gatherer: gatherer,
+		client:   client,
+		now:      time.Now,
+	}
+}
+
+func (p *samplePusher) Run(stopCh <-chan struct{}) {
+	klog.Infof("start pushing samples every %v", p.interval)
+	wait.Until(p.push, p.interval, stopCh)
+}
+
+// push sends the samples gathered with the pending ones in batches of maxBatchSamples. Once a batch fails, it and
+// the following ones are kept for the next push, except the batches rejected by the server, which are dropped.
+func (p *samplePusher) push() {
+	samples, err := p.gather()
+	if err != nil {
+		klog.Warningf("failed to gather samples, error: %v", err)
+	}
+	samples = append(p.pending, samples...)
+	p.pending = nil
+	for len(samples) > 0 {
+		n := len(samples)
+		if n > maxBatchSamples {
+			n = maxBatchSamples
+		}
+		err = p.pushBatch(samples[:n])
+		if err == nil {
+			klog.V(5).Infof("pushed %d samples", n)
+			samples = samples[n:]
+			continue
+		}
+		if errors.Is(err, ingestion.ErrRejected) {
+			klog.Warningf("dropped %d samples rejected by the ingestion server, error: %v", n, err)
+			samples = samples[n:]
+			continue
+		}
+		break
+	}
+	if len(samples) == 0 {
+		return
+	}
+
+	if dropped := len(samples) - maxPendingSamples; dropped > 0 {
+		samples = samples[dropped:]
+		klog.Warningf("dropped %d samples failed to push", dropped)
+	}
+	p.pending = samples
+	if err == ingestion.ErrBackpressure {
+		klog.V(4).Infof("ingestion server is busy, keep %d samples for the next push", len(samples))
+		return
+	}
+	klog.Warningf("failed to push samples, error: %v", err)
+}
+
+func (p *samplePusher) pushBatch(samples []ingestion.Sample) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+	return p.client.Push(ctx, &ingestion.SampleBatch{Node: p.nodeName, Samples: samples})
+}
+
+// gather converts the gauges of koordetector into samples, the node label is carried by the batch instead.
+func (p *samplePusher) gather() ([]ingestion.Sample, error) {
+	families, err := p.gatherer.Gather()
+	if err != nil && len(families) == 0 {
+		return nil, err
+	}
+	timestampMs := p.now().UnixMilli()
+	var samples []ingestion.Sample
+	for _, family := range families {
+		if family.GetType() != dto.MetricType_GAUGE || !strings.HasPrefix(family.GetName(), metrics.KoordetectorSubsystem+"_") {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string, len(m.GetLabel()))
+			for _, label := range m.GetLabel() {
+				if label.GetName() == metrics.NodeKey {
+					continue
+				}
+				labels[label.GetName()] = label.GetValue()
+			}
+			sample := ingestion.Sample{
+				Metric:      family.GetName(),
+				Labels:      labels,
+				Value:       m.GetGauge().GetValue(),
+				TimestampMs: timestampMs,
+			}
+			if m.TimestampMs != nil {
+				sample.TimestampMs = m.GetTimestampMs()
+			}
+			samples = append(samples, sample)
+		}
+	}
+	return samples, err
+}
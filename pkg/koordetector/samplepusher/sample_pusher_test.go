@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package samplepusher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koordinator-sh/koordetector/pkg/interferencemanager/metric-provider/ingestion"
+	"github.com/koordinator-sh/koordetector/pkg/koordetector/metrics"
+)
+
+type fakeClient struct {
+	batches []*ingestion.SampleBatch
+	err     error
+	// errs are returned by the pushes in order before err.
+	errs []error
+}
+
+func (f *fakeClient) Push(ctx context.Context, batch *ingestion.SampleBatch) error {
+	f.batches = append(f.batches, batch)
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return err
+	}
+	return f.err
+}
+
+func TestSamplePusherPush(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	latency := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: metrics.KoordetectorSubsystem,
+		Name:      "container_cpu_schedule_latency_seconds",
+	}, []string{metrics.NodeKey, metrics.PodUID, metrics.ContainerName})
+	// metrics of other components are not pushed
+	other := prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_goroutines_test"})
+	registry.MustRegister(latency, other)
+	latency.WithLabelValues("node-1", "uid-1", "main").Set(0.01)
+	other.Set(1)
+
+	now := time.Unix(1700000000, 0)
+	client := &fakeClient{err: ingestion.ErrBackpressure}
+	p := newSamplePusher("node-1", time.Second, registry, client)
+	p.now = func() time.Time { return now }
+
+	expected := ingestion.Sample{
+		Metric:      "koordetector_container_cpu_schedule_latency_seconds",
+		Labels:      map[string]string{metrics.PodUID: "uid-1", metrics.ContainerName: "main"},
+		Value:       0.01,
+		TimestampMs: now.UnixMilli(),
+	}
+	p.push()
+	assert.Len(t, client.batches, 1)
+	assert.Equal(t, &ingestion.SampleBatch{Node: "node-1", Samples: []ingestion.Sample{expected}}, client.batches[0])
+	// samples are kept when the server is busy
+	assert.Equal(t, []ingestion.Sample{expected}, p.pending)
+
+	client.err = nil
+	p.push()
+	assert.Len(t, client.batches, 2)
+	assert.Len(t, client.batches[1].Samples, 2)
+	assert.Empty(t, p.pending)
+}
+
+func TestSamplePusherBatches(t *testing.T) {
+	newSamples := func(n int) []ingestion.Sample {
+		samples := make([]ingestion.Sample, n)
+		for i := range samples {
+			samples[i] = ingestion.Sample{Metric: "koordetector_test", Value: float64(i)}
+		}
+		return samples
+	}
+	client := &fakeClient{}
+	p := newSamplePusher("node-1", time.Second, prometheus.NewRegistry(), client)
+
+	// pending samples are pushed in batches below the limit of the server
+	p.pending = newSamples(maxPendingSamples)
+	client.errs = []error{nil, fmt.Errorf("%w, status 413", ingestion.ErrRejected)}
+	p.push()
+	assert.Len(t, client.batches, maxPendingSamples/maxBatchSamples)
+	for _, batch := range client.batches {
+		assert.Len(t, batch.Samples, maxBatchSamples)
+		assert.Less(t, len(batch.Samples), ingestion.DefaultMaxBatchSamples)
+	}
+	// the rejected batch is dropped instead of being retried
+	assert.Empty(t, p.pending)
+
+	// the failed batch and the following ones are kept
+	client.batches = nil
+	p.pending = newSamples(2*maxBatchSamples + 1)
+	client.errs = []error{nil, ingestion.ErrBackpressure}
+	p.push()
+	assert.Len(t, client.batches, 2)
+	assert.Len(t, p.pending, maxBatchSamples+1)
+	assert.Equal(t, float64(maxBatchSamples), p.pending[0].Value)
+}
+
+func TestNewSamplePusher(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Address = "http://interference-manager:9091"
+	_, err := NewSamplePusher(cfg, "node-1")
+	// tokens are not sent in plain HTTP
+	assert.Error(t, err)
+
+	cfg.Address = "https://interference-manager:9091"
+	_, err = NewSamplePusher(cfg, "node-1")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statesinformer
+
+import (
+	"fmt"
+)
+
+// pluginRegistration declares an informer plugin and the plugins it depends on. Dependencies are set up before
+// the plugin, so that the plugin can get them from the pluginState in Setup, and the plugin is started after they
+// have synced.
+type pluginRegistration struct {
+	name         pluginName
+	dependencies []pluginName
+	newPlugin    func() informerPlugin
+}
+
+// defaultPluginRegistry is the informer plugins of koordetector, new plugins are registered here.
+var defaultPluginRegistry = []pluginRegistration{
+	{
+		name:      nodeInformerName,
+		newPlugin: func() informerPlugin { return NewNodeInformer() },
+	},
+	{
+		name:         podsInformerName,
+		dependencies: []pluginName{nodeInformerName},
+		newPlugin:    func() informerPlugin { return NewPodsInformer() },
+	},
+}
+
+// sortPlugins returns the names of the plugins ordered by dependencies, plugins are kept in the order of
+// registration if they do not depend on each other.
+func sortPlugins(registry []pluginRegistration) ([]pluginName, error) {
+	registrations := make(map[pluginName]*pluginRegistration, len(registry))
+	for i := range registry {
+		r := &registry[i]
+		if _, ok := registrations[r.name]; ok {
+			return nil, fmt.Errorf("informer plugin %v is registered more than once", r.name)
+		}
+		registrations[r.name] = r
+	}
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+	states := make(map[pluginName]int, len(registry))
+	sorted := make([]pluginName, 0, len(registry))
+	var visit func(name pluginName, path []pluginName) error
+	visit = func(name pluginName, path []pluginName) error {
+		switch states[name] {
+		case visiting:
+			return fmt.Errorf("informer plugins have circular dependencies %v", append(path, name))
+		case visited:
+			return nil
+		}
+		states[name] = visiting
+		for _, dep := range registrations[name].dependencies {
+			if _, ok := registrations[dep]; !ok {
+				return fmt.Errorf("informer plugin %v depends on %v which is not registered", name, dep)
+			}
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		states[name] = visited
+		sorted = append(sorted, name)
+		return nil
+	}
+	for i := range registry {
+		if err := visit(registry[i].name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statesinformer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortPlugins(t *testing.T) {
+	newPlugin := func() informerPlugin { return nil }
+	tests := []struct {
+		name     string
+		registry []pluginRegistration
+		want     []pluginName
+		wantErr  bool
+	}{
+		{
+			name:     "default registry",
+			registry: defaultPluginRegistry,
+			want:     []pluginName{nodeInformerName, podsInformerName},
+		},
+		{
+			name: "dependencies go first",
+			registry: []pluginRegistration{
+				{name: "c", dependencies: []pluginName{"b"}, newPlugin: newPlugin},
+				{name: "a", newPlugin: newPlugin},
+				{name: "b", dependencies: []pluginName{"a"}, newPlugin: newPlugin},
+				{name: "d", newPlugin: newPlugin},
+			},
+			want: []pluginName{"a", "b", "c", "d"},
+		},
+		{
+			name: "dependency not registered",
+			registry: []pluginRegistration{
+				{name: "a", dependencies: []pluginName{"b"}, newPlugin: newPlugin},
+			},
+			wantErr: true,
+		},
+		{
+			name: "circular dependencies",
+			registry: []pluginRegistration{
+				{name: "a", dependencies: []pluginName{"b"}, newPlugin: newPlugin},
+				{name: "b", dependencies: []pluginName{"a"}, newPlugin: newPlugin},
+			},
+			wantErr: true,
+		},
+		{
+			name: "registered twice",
+			registry: []pluginRegistration{
+				{name: "a", newPlugin: newPlugin},
+				{name: "a", newPlugin: newPlugin},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sortPlugins(tt.registry)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -47,7 +47,8 @@ type podsInformer struct {
 	podUpdatedTime time.Time
 	podHasSynced   *atomic.Bool
 
-	// use pleg to accelerate the efficiency of Pod meta update
+	// use pleg to accelerate the efficiency of Pod meta update, which is created on start since it watches the
+	// cgroups of the host
 	pleg       pleg.Pleg
 	podCreated chan string
 
@@ -58,15 +59,9 @@ type podsInformer struct {
 }
 
 func NewPodsInformer() *podsInformer {
-	p, err := pleg.NewPLEG(system.Conf.CgroupRootDir)
-	if err != nil {
-		klog.Fatalf("failed to create PLEG, %v", err)
-	}
-
 	podsInformer := &podsInformer{
 		podMap:       map[string]*PodMeta{},
 		podHasSynced: atomic.NewBool(false),
-		pleg:         p,
 		podCreated:   make(chan string, 1),
 	}
 	return podsInformer
@@ -93,11 +88,20 @@ func (s *podsInformer) Start(stopCh <-chan struct{}) {
 	if s.config.KubeletSyncInterval <= 0 {
 		return
 	}
-	stub, err := newKubeletStubFromConfig(s.nodeInformer.GetNode(), s.config)
-	if err != nil {
-		klog.Fatalf("create kubelet stub, %v", err)
+	if s.kubelet == nil {
+		stub, err := newKubeletStubFromConfig(s.nodeInformer.GetNode(), s.config)
+		if err != nil {
+			klog.Fatalf("create kubelet stub, %v", err)
+		}
+		s.kubelet = stub
+	}
+	if s.pleg == nil {
+		p, err := pleg.NewPLEG(system.Conf.CgroupRootDir)
+		if err != nil {
+			klog.Fatalf("failed to create PLEG, %v", err)
+		}
+		s.pleg = p
 	}
-	s.kubelet = stub
 	hdlID := s.pleg.AddHandler(pleg.PodLifeCycleHandlerFuncs{
 		PodAddedFunc: func(podID string) {
 			// There is no need to notify to update the data when the channel is not empty
@@ -115,7 +119,7 @@ func (s *podsInformer) Start(stopCh <-chan struct{}) {
 	go s.syncKubeletLoop(s.config.KubeletSyncInterval, stopCh)
 	go func() {
 		if err := s.pleg.Run(stopCh); err != nil {
-			klog.Fatalf("Unable to run the pleg: ", err)
+			klog.Fatalf("Unable to run the pleg: %v", err)
 		}
 	}()
 
@@ -159,10 +163,12 @@ func (s *podsInformer) syncPods() error {
 		// record pod container metrics
 		recordPodResourceMetrics(podMeta)
 	}
+	s.podRWMutex.Lock()
 	s.podMap = newPodMap
-	s.podHasSynced.Store(true)
 	s.podUpdatedTime = time.Now()
-	klog.Infof("get pods success, len %d, time %s", len(s.podMap), s.podUpdatedTime.String())
+	s.podRWMutex.Unlock()
+	s.podHasSynced.Store(true)
+	klog.Infof("get pods success, len %d, time %s", len(newPodMap), s.podUpdatedTime.String())
 	s.callbackRunner.SendCallback(RegisterTypeAllPods)
 	return nil
 }
@@ -70,9 +70,13 @@ type statesInformer struct {
 	// todo: use tsdb
 	//metricsCache metriccache.MetricCache
 
-	option  *pluginOption
-	states  *pluginState
-	started *atomic.Bool
+	option *pluginOption
+	states *pluginState
+	// pluginOrder is the names of informer plugins ordered by dependencies.
+	pluginOrder []pluginName
+	// pluginDependencies is the plugins each plugin depends on.
+	pluginDependencies map[pluginName][]pluginName
+	started            *atomic.Bool
 }
 
 type informerPlugin interface {
@@ -81,7 +85,12 @@ type informerPlugin interface {
 	HasSynced() bool
 }
 
-func NewStatesInformer(config *Config, kubeClient clientset.Interface, nodeName string) StatesInformer {
+func NewStatesInformer(config *Config, kubeClient clientset.Interface, nodeName string) (StatesInformer, error) {
+	return newStatesInformer(config, kubeClient, nodeName, defaultPluginRegistry)
+}
+
+func newStatesInformer(config *Config, kubeClient clientset.Interface, nodeName string,
+	registry []pluginRegistration) (*statesInformer, error) {
 	opt := &pluginOption{
 		config:     config,
 		KubeClient: kubeClient,
@@ -89,6 +98,7 @@ func NewStatesInformer(config *Config, kubeClient clientset.Interface, nodeName
 	}
 	stat := &pluginState{
 		informerPlugins: map[pluginName]informerPlugin{},
+		callbackRunner:  NewCallbackRunner(),
 	}
 	s := &statesInformer{
 		config: config,
@@ -97,12 +107,29 @@ func NewStatesInformer(config *Config, kubeClient clientset.Interface, nodeName
 		states:  stat,
 		started: atomic.NewBool(false),
 	}
-	return s
+	if err := s.initInformerPlugins(registry); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *statesInformer) initInformerPlugins(registry []pluginRegistration) error {
+	order, err := sortPlugins(registry)
+	if err != nil {
+		return err
+	}
+	s.pluginDependencies = make(map[pluginName][]pluginName, len(registry))
+	for i := range registry {
+		s.states.informerPlugins[registry[i].name] = registry[i].newPlugin()
+		s.pluginDependencies[registry[i].name] = registry[i].dependencies
+	}
+	s.pluginOrder = order
+	return nil
 }
 
 func (s *statesInformer) setupPlugins() {
-	for name, plugin := range s.states.informerPlugins {
-		plugin.Setup(s.option, s.states)
+	for _, name := range s.pluginOrder {
+		s.states.informerPlugins[name].Setup(s.option, s.states)
 		klog.V(2).Infof("plugin %v has been setup", name)
 	}
 }
@@ -112,10 +139,14 @@ func (s *statesInformer) Run(stopCh <-chan struct{}) error {
 	klog.V(2).Infof("setup statesInformer")
 
 	klog.V(2).Infof("starting callback runner")
+	s.states.callbackRunner.Setup(s)
+	go s.states.callbackRunner.Start(stopCh)
 
 	klog.V(2).Infof("starting informer plugins")
 	s.setupPlugins()
-	s.startPlugins(stopCh)
+	if err := s.startPlugins(stopCh); err != nil {
+		return err
+	}
 
 	// waiting for node synced.
 	klog.V(2).Infof("waiting for informer syncing")
@@ -139,20 +170,28 @@ func (s *statesInformer) waitForSyncFunc() []cache.InformerSynced {
 	return waitInformersSynced
 }
 
-func (s *statesInformer) startPlugins(stopCh <-chan struct{}) {
-	for name, p := range s.states.informerPlugins {
+// startPlugins starts the plugins in the order of dependencies, a plugin is started after its dependencies have
+// synced.
+func (s *statesInformer) startPlugins(stopCh <-chan struct{}) error {
+	for _, name := range s.pluginOrder {
+		dependencies := s.pluginDependencies[name]
+		dependenciesSynced := make([]cache.InformerSynced, 0, len(dependencies))
+		for _, dep := range dependencies {
+			dependenciesSynced = append(dependenciesSynced, s.states.informerPlugins[dep].HasSynced)
+		}
+		if !cache.WaitForCacheSync(stopCh, dependenciesSynced...) {
+			return fmt.Errorf("timed out waiting for the dependencies of informer plugin %v to sync", name)
+		}
 		klog.V(4).Infof("starting informer plugin %v", name)
-		go p.Start(stopCh)
+		go s.states.informerPlugins[name].Start(stopCh)
 	}
+	return nil
 }
 
+// HasSynced returns whether all plugins have synced. Plugins are set up in Run, so it is tracked by Run rather
+// than asking the plugins, which may not be set up yet.
 func (s *statesInformer) HasSynced() bool {
-	for _, p := range s.states.informerPlugins {
-		if !p.HasSynced() {
-			return false
-		}
-	}
-	return true
+	return s.started.Load()
 }
 
 func (s *statesInformer) GetNode() *corev1.Node {
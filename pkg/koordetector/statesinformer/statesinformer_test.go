@@ -0,0 +1,170 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statesinformer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/koordinator-sh/koordinator/pkg/koordlet/pleg"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	kubeletconfiginternal "k8s.io/kubernetes/pkg/kubelet/apis/config"
+)
+
+type testKubeletStub struct {
+	pods corev1.PodList
+}
+
+func (t *testKubeletStub) GetAllPods() (corev1.PodList, error) {
+	return t.pods, nil
+}
+
+func (t *testKubeletStub) GetKubeletConfiguration() (*kubeletconfiginternal.KubeletConfiguration, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+// testPleg never reports pod events, pods are synced by the sync loop only.
+type testPleg struct{}
+
+func (p *testPleg) Run(stopCh <-chan struct{}) error {
+	<-stopCh
+	return nil
+}
+
+func (p *testPleg) AddHandler(handler pleg.PodLifeCycleHandler) pleg.HandlerID {
+	return 0
+}
+
+func (p *testPleg) RemoverHandler(id pleg.HandlerID) pleg.PodLifeCycleHandler {
+	return nil
+}
+
+func TestStatesInformerRun(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node"},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "127.0.0.1"}},
+		},
+	}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-pod", UID: "test-pod-uid"},
+		Spec:       corev1.PodSpec{NodeName: "test-node"},
+		Status:     corev1.PodStatus{QOSClass: corev1.PodQOSBurstable},
+	}
+	config := NewDefaultConfig()
+	config.KubeletSyncInterval = time.Minute
+	si, err := newStatesInformer(config, fakeclientset.NewSimpleClientset(node), node.Name, defaultPluginRegistry)
+	assert.NoError(t, err)
+	podsInformer := si.states.informerPlugins[podsInformerName].(*podsInformer)
+	podsInformer.kubelet = &testKubeletStub{pods: corev1.PodList{Items: []corev1.Pod{pod}}}
+	podsInformer.pleg = &testPleg{}
+
+	callbackPods := make(chan []*PodMeta, 1)
+	si.RegisterCallbacks(RegisterTypeAllPods, "test-callback", "receive all pods", func(t RegisterType, obj interface{}, pods []*PodMeta) {
+		select {
+		case callbackPods <- pods:
+		default:
+		}
+	})
+	assert.False(t, si.HasSynced())
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- si.Run(stopCh)
+	}()
+	assert.Eventually(t, si.HasSynced, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, node.Name, si.GetNode().Name)
+	pods := si.GetAllPods()
+	assert.Len(t, pods, 1)
+	assert.Equal(t, pod.UID, pods[0].Pod.UID)
+	assert.NotEmpty(t, pods[0].CgroupDir)
+
+	select {
+	case pods := <-callbackPods:
+		assert.Len(t, pods, 1)
+	case err := <-runErr:
+		t.Fatalf("states informer exited unexpectedly: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the callback of all pods")
+	}
+}
+
+func TestNewStatesInformerInvalidRegistry(t *testing.T) {
+	_, err := newStatesInformer(NewDefaultConfig(), fakeclientset.NewSimpleClientset(), "test-node",
+		[]pluginRegistration{
+			{name: podsInformerName, dependencies: []pluginName{nodeInformerName}, newPlugin: func() informerPlugin { return NewPodsInformer() }},
+		})
+	assert.Error(t, err)
+}
+
+// testPlugin syncs once synced is closed, and records the plugins started before it.
+type testPlugin struct {
+	name    pluginName
+	synced  chan struct{}
+	started chan pluginName
+}
+
+func (p *testPlugin) Setup(ctx *pluginOption, state *pluginState) {}
+
+func (p *testPlugin) Start(stopCh <-chan struct{}) {
+	p.started <- p.name
+}
+
+func (p *testPlugin) HasSynced() bool {
+	select {
+	case <-p.synced:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestStatesInformerStartPluginsByDependencies(t *testing.T) {
+	started := make(chan pluginName, 2)
+	base := &testPlugin{name: "base", synced: make(chan struct{}), started: started}
+	dependent := &testPlugin{name: "dependent", synced: make(chan struct{}), started: started}
+	close(dependent.synced)
+	si, err := newStatesInformer(NewDefaultConfig(), fakeclientset.NewSimpleClientset(), "test-node",
+		[]pluginRegistration{
+			{name: "dependent", dependencies: []pluginName{"base"}, newPlugin: func() informerPlugin { return dependent }},
+			{name: "base", newPlugin: func() informerPlugin { return base }},
+		})
+	assert.NoError(t, err)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	startErr := make(chan error, 1)
+	go func() {
+		startErr <- si.startPlugins(stopCh)
+	}()
+	assert.Equal(t, pluginName("base"), <-started)
+	// the dependent is not started until the base has synced
+	select {
+	case name := <-started:
+		t.Fatalf("plugin %v is started before its dependencies synced", name)
+	case <-time.After(300 * time.Millisecond):
+	}
+	close(base.synced)
+	assert.Equal(t, pluginName("dependent"), <-started)
+	assert.NoError(t, <-startErr)
+}
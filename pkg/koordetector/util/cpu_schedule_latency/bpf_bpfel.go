@@ -80,9 +80,10 @@ type bpfProgramSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type bpfMapSpecs struct {
-	OutputCgroupCounter *ebpf.MapSpec `ebpf:"output_cgroup_counter"`
-	OutputCgroupDelay   *ebpf.MapSpec `ebpf:"output_cgroup_delay"`
-	PidStartTime        *ebpf.MapSpec `ebpf:"pid_start_time"`
+	OutputCgroupLatency0 *ebpf.MapSpec `ebpf:"output_cgroup_latency_0"`
+	OutputCgroupLatency1 *ebpf.MapSpec `ebpf:"output_cgroup_latency_1"`
+	OutputGeneration     *ebpf.MapSpec `ebpf:"output_generation"`
+	PidStartTime         *ebpf.MapSpec `ebpf:"pid_start_time"`
 }
 
 // bpfObjects contains all objects after they have been loaded into the kernel.
@@ -104,15 +105,17 @@ func (o *bpfObjects) Close() error {
 //
 // It can be passed to loadBpfObjects or ebpf.CollectionSpec.LoadAndAssign.
 type bpfMaps struct {
-	OutputCgroupCounter *ebpf.Map `ebpf:"output_cgroup_counter"`
-	OutputCgroupDelay   *ebpf.Map `ebpf:"output_cgroup_delay"`
-	PidStartTime        *ebpf.Map `ebpf:"pid_start_time"`
+	OutputCgroupLatency0 *ebpf.Map `ebpf:"output_cgroup_latency_0"`
+	OutputCgroupLatency1 *ebpf.Map `ebpf:"output_cgroup_latency_1"`
+	OutputGeneration     *ebpf.Map `ebpf:"output_generation"`
+	PidStartTime         *ebpf.Map `ebpf:"pid_start_time"`
 }
 
 func (m *bpfMaps) Close() error {
 	return _BpfClose(
-		m.OutputCgroupCounter,
-		m.OutputCgroupDelay,
+		m.OutputCgroupLatency0,
+		m.OutputCgroupLatency1,
+		m.OutputGeneration,
 		m.PidStartTime,
 	)
 }
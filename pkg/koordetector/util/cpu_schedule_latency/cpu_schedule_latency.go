@@ -17,20 +17,35 @@ limitations under the License.
 package cpu_schedule_latency
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 
+	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/rlimit"
 	"go.uber.org/multierr"
 	"golang.org/x/sys/unix"
 )
 
-// $BPF_CLANG and $BPF_CFLAGS are set by the Makefile.
+// $BPF_CLANG and $BPF_CFLAGS are set by the Makefile, run `make generate-ebpf` after changing the C sources.
 //go:generate go run github.com/cilium/ebpf/cmd/bpf2go -cc $BPF_CLANG -cflags $BPF_CFLAGS bpf ../ebpf/cpu_schedule_latency/csl.bpf.c -- -I../ebpf/headers
 
+// cgroupMaps are the maps keyed by cgroup IDs.
+var cgroupMaps = []string{"output_cgroup_latency_0", "output_cgroup_latency_1"}
+
+// MinKernelVersion is the earliest kernel whose kernfs node IDs are the inode numbers of cgroup directories. The
+// ID of earlier kernels is a union of the inode number and its generation, which GetCgroupID can not match, and
+// they lack bpf_probe_read_kernel() the eBPF program reads tasks with.
+var MinKernelVersion = [2]int{5, 5}
+
 type ProgObjects struct {
 	Objs        *bpfObjects
 	tracepoints []*link.Link
+	// lock serializes the readers of the output maps, which flip the generation
+	lock sync.Mutex
 }
 
 func NewCSLeBPFProg() (*ProgObjects, error) {
@@ -38,22 +53,33 @@ func NewCSLeBPFProg() (*ProgObjects, error) {
 	if err := rlimit.RemoveMemlock(); err != nil {
 		return nil, fmt.Errorf("lock memory error: %v", err)
 	}
+	if err := CheckKernelVersion(); err != nil {
+		return nil, err
+	}
+	spec, err := loadBpf()
+	if err != nil {
+		return nil, fmt.Errorf("load bpf spec error: %v", err)
+	}
+	if err := checkCgroupMaps(spec); err != nil {
+		return nil, err
+	}
 	// Load pre-compiled programs and maps into the kernel.
 	objs := bpfObjects{}
-	if err := loadBpfObjects(&objs, nil); err != nil {
+	if err := spec.LoadAndAssign(&objs, nil); err != nil {
 		return nil, fmt.Errorf("load bpf objects error: %v", err)
 	}
-	tpWakeup, err := link.Tracepoint("sched", "sched_wakeup", objs.HandleSchedWakeup)
+	// raw tracepoints pass the woken and switched tasks, while the current task is the waker or the previous one
+	tpWakeup, err := link.AttachRawTracepoint(link.RawTracepointOptions{Name: "sched_wakeup", Program: objs.HandleSchedWakeup})
 	if err != nil {
-		return nil, fmt.Errorf("link tracepoint sched_wakeup error: %v", err)
+		return nil, fmt.Errorf("link raw tracepoint sched_wakeup error: %v", err)
 	}
-	tpWakeupNew, err := link.Tracepoint("sched", "sched_wakeup_new", objs.HandleSchedWakeupNew)
+	tpWakeupNew, err := link.AttachRawTracepoint(link.RawTracepointOptions{Name: "sched_wakeup_new", Program: objs.HandleSchedWakeupNew})
 	if err != nil {
-		return nil, fmt.Errorf("link tracepoint sched_wakeup_new error: %v", err)
+		return nil, fmt.Errorf("link raw tracepoint sched_wakeup_new error: %v", err)
 	}
-	tpSwitch, err := link.Tracepoint("sched", "sched_switch", objs.HandleSwitch)
+	tpSwitch, err := link.AttachRawTracepoint(link.RawTracepointOptions{Name: "sched_switch", Program: objs.HandleSwitch})
 	if err != nil {
-		return nil, fmt.Errorf("link tracepoint sched_switch error: %v", err)
+		return nil, fmt.Errorf("link raw tracepoint sched_switch error: %v", err)
 	}
 
 	return &ProgObjects{
@@ -66,6 +92,61 @@ func NewCSLeBPFProg() (*ProgObjects, error) {
 	}, nil
 }
 
+// checkCgroupMaps makes sure the pre-compiled maps are keyed by cgroup IDs, since objects compiled from stale
+// sources can be loaded but fail every read.
+func checkCgroupMaps(spec *ebpf.CollectionSpec) error {
+	for _, name := range cgroupMaps {
+		m, ok := spec.Maps[name]
+		if !ok {
+			return fmt.Errorf("map %s not found, bpf objects are stale", name)
+		}
+		if m.KeySize != 8 {
+			return fmt.Errorf("map %s is keyed by %d bytes rather than cgroup IDs, bpf objects are stale", name, m.KeySize)
+		}
+	}
+	return nil
+}
+
+// CheckKernelVersion returns an error if the running kernel is earlier than MinKernelVersion.
+func CheckKernelVersion() error {
+	var uname unix.Utsname
+	if err := unix.Uname(&uname); err != nil {
+		return fmt.Errorf("get kernel version error: %v", err)
+	}
+	release := unix.ByteSliceToString(uname.Release[:])
+	version, err := parseKernelVersion(release)
+	if err != nil {
+		return err
+	}
+	if version[0] < MinKernelVersion[0] || version[0] == MinKernelVersion[0] && version[1] < MinKernelVersion[1] {
+		return fmt.Errorf("kernel %s is not supported, kernel %d.%d or later is required",
+			release, MinKernelVersion[0], MinKernelVersion[1])
+	}
+	return nil
+}
+
+// parseKernelVersion parses the major and minor version of a kernel release, e.g. 5.10.134-13.an8.x86_64.
+func parseKernelVersion(release string) ([2]int, error) {
+	var version [2]int
+	parts := strings.SplitN(release, ".", 3)
+	if len(parts) < 2 {
+		return version, fmt.Errorf("invalid kernel release %s", release)
+	}
+	for i := range version {
+		// the minor version may be followed by a suffix, e.g. 5.15-rc1
+		digits := parts[i]
+		if end := strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }); end >= 0 {
+			digits = digits[:end]
+		}
+		v, err := strconv.Atoi(digits)
+		if err != nil {
+			return version, fmt.Errorf("invalid kernel release %s", release)
+		}
+		version[i] = v
+	}
+	return version, nil
+}
+
 func (p *ProgObjects) DestroyEBPFProg() (err error) {
 	for _, tracepoint := range p.tracepoints {
 		newErr := (*tracepoint).Close()
@@ -76,44 +157,100 @@ func (p *ProgObjects) DestroyEBPFProg() (err error) {
 	return
 }
 
-// GetCgroupScheduleLatencyAvg get cgroup delay and counter with filtering from cgroupNames.
+// CgroupScheduleLatency is the schedule latency of the tasks within a cgroup in a time window.
+type CgroupScheduleLatency struct {
+	// Delay is the total delay in nanosecond the tasks wait in the run queue.
+	Delay uint64
+	// Counter is the number of switches to the tasks.
+	Counter uint64
+}
+
+// Avg returns the average latency in nanosecond of a switch, or zero if there is no switch.
+func (l CgroupScheduleLatency) Avg() float64 {
+	if l.Counter == 0 {
+		return 0
+	}
+	return float64(l.Delay) / float64(l.Counter)
+}
+
+// GetCgroupScheduleLatency get cgroup delay and counter with filtering from cgroupIDs.
 // @delay is total delay in nanosecond for all pids within this cgroup in the last time window.
 // @counter is total number of finish_task_switch() is called for all pids within this cgroup in tha last time window.
-// @return each cgroup's average CPU schedule latency in the last time window as the result of @delay / @counter.
-func (p *ProgObjects) GetCgroupScheduleLatencyAvg(cgroupNames []string) (map[string]float64, error) {
-	cgroupLatencyAvg := map[string]float64{}
-	for _, name := range cgroupNames {
-		cgroupLatencyAvg[name] = float64(0)
-	}
-	var delay, counter uint64
-	var cgroupNameArray []byte
-	cgroupComputationMap := map[string][2]uint64{}
-	// Use MapIterator.Next() instead of MapIterator.Lookup() due to problems of marshaling and searching bpf map key
-	// of type char array.
-	delayIterator := p.Objs.OutputCgroupDelay.Iterate()
-	for delayIterator.Next(&cgroupNameArray, &delay) {
-		nameFromKernel := unix.ByteSliceToString(cgroupNameArray)
-		// filter cgroup name from eBPF by input cgroupNames
-		if _, ok := cgroupLatencyAvg[nameFromKernel]; ok {
-			cgroupComputationMap[nameFromKernel] = [2]uint64{delay, 0}
-		}
+// @return the delay and counter of each cgroup in cgroupIDs, cgroups without switches are zero.
+//
+// Cgroups are identified by the IDs of their cpu cgroups, which can be resolved by GetCgroupID. The delays are
+// accounted to the cgroups the tasks belong to, i.e. the leaf cgroups rather than their ancestors, so the latency
+// of a pod is the sum of its containers.
+//
+// The time window is the interval between two calls, or since the program was loaded for the first call: the eBPF
+// program accounts the switches in the output maps of the current generation, and each call flips the generation
+// and takes the entries of the last one, including the ones of cgroups not in cgroupIDs. So there should be only one
+// caller polling the maps, otherwise callers share the windows with each other.
+func (p *ProgObjects) GetCgroupScheduleLatency(cgroupIDs []uint64) (map[uint64]CgroupScheduleLatency, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	generation, err := p.flipGeneration()
+	if err != nil {
+		return nil, err
 	}
-	err := delayIterator.Err()
-	counterIterator := p.Objs.OutputCgroupDelay.Iterate()
-	for counterIterator.Next(&cgroupNameArray, &counter) {
-		nameFromKernel := unix.ByteSliceToString(cgroupNameArray)
-		// filter cgroup name directly from cgroupComputationMap
-		// iterator delay and counter may have different cgroup names, but technically not, this special case simply
-		// make array[1] which is counter zero
-		if array, ok := cgroupComputationMap[nameFromKernel]; ok {
-			array[1] = counter
-		}
+	outputs := []*ebpf.Map{p.Objs.OutputCgroupLatency0, p.Objs.OutputCgroupLatency1}
+	values, err := snapshotAndDelete(outputs[generation])
+	latencies := make(map[uint64]CgroupScheduleLatency, len(cgroupIDs))
+	for _, id := range cgroupIDs {
+		latencies[id] = values[id]
 	}
-	for name, array := range cgroupComputationMap {
-		if array[1] != 0 {
-			cgroupLatencyAvg[name] = float64(array[0]) / float64(array[1])
+	return latencies, err
+}
+
+// flipGeneration makes the eBPF program account in the output maps of the other generation, and returns the last
+// generation whose maps are taken.
+func (p *ProgObjects) flipGeneration() (uint32, error) {
+	var key, generation uint32
+	if err := p.Objs.OutputGeneration.Lookup(key, &generation); err != nil {
+		return 0, fmt.Errorf("lookup output generation error: %v", err)
+	}
+	if generation != 0 {
+		generation = 1
+	}
+	if err := p.Objs.OutputGeneration.Update(key, 1-generation, ebpf.UpdateExist); err != nil {
+		return 0, fmt.Errorf("update output generation error: %v", err)
+	}
+	return generation, nil
+}
+
+// snapshotAndDelete reads all entries of an output map of the last generation, then deletes them so that the map
+// accumulates from zero when its generation comes again. The map is no longer updated except by the switches which
+// have looked up the generation before it was flipped, whose increments are lost if they land after the entry is
+// read, which is rare since they only race with the first entries read.
+func snapshotAndDelete(m *ebpf.Map) (map[uint64]CgroupScheduleLatency, error) {
+	values := map[uint64]CgroupScheduleLatency{}
+	var id uint64
+	var value CgroupScheduleLatency
+	iterator := m.Iterate()
+	for iterator.Next(&id, &value) {
+		values[id] = value
+	}
+	err := iterator.Err()
+	// delete after iterating since deleting entries while iterating a hash map makes the iteration restart
+	for id = range values {
+		if deleteErr := m.Delete(id); deleteErr != nil && !errors.Is(deleteErr, ebpf.ErrKeyNotExist) {
+			err = multierr.Append(err, deleteErr)
 		}
 	}
-	err = multierr.Append(err, counterIterator.Err())
-	return cgroupLatencyAvg, err
+	return values, err
+}
+
+// GetCgroupID gets the ID of a cgroup by its absolute directory, e.g. /sys/fs/cgroup/cpu/kubepods.slice on cgroup
+// v1 or /sys/fs/cgroup/kubepods.slice on cgroup v2.
+// The ID is the kernfs node id of the cgroup, which is the inode number of its directory on both cgroup v1 and v2
+// since kernel 5.5.
+func GetCgroupID(cgroupDir string) (uint64, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(cgroupDir, &stat); err != nil {
+		return 0, fmt.Errorf("stat cgroup dir %s error: %v", cgroupDir, err)
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFDIR {
+		return 0, fmt.Errorf("cgroup dir %s is not a directory", cgroupDir)
+	}
+	return stat.Ino, nil
 }
@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The Koordinator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu_schedule_latency
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCgroupMaps(t *testing.T) {
+	newSpec := func(keySize uint32) *ebpf.CollectionSpec {
+		spec := &ebpf.CollectionSpec{Maps: map[string]*ebpf.MapSpec{}}
+		for _, name := range cgroupMaps {
+			spec.Maps[name] = &ebpf.MapSpec{Name: name, KeySize: keySize, ValueSize: 16}
+		}
+		return spec
+	}
+	assert.NoError(t, checkCgroupMaps(newSpec(8)))
+	err := checkCgroupMaps(newSpec(128))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "bpf objects are stale")
+	err = checkCgroupMaps(&ebpf.CollectionSpec{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "bpf objects are stale")
+}
+
+func TestEmbeddedBpfObjects(t *testing.T) {
+	// objects compiled from stale sources are refused, run `make generate-ebpf` after changing the C sources
+	spec, err := loadBpf()
+	assert.NoError(t, err)
+	assert.NoError(t, checkCgroupMaps(spec))
+	for _, name := range cgroupMaps {
+		if m, ok := spec.Maps[name]; ok {
+			assert.Equal(t, uint32(8), m.KeySize, "map %s should be keyed by cgroup IDs", name)
+		}
+	}
+	for _, name := range []string{"handle__sched_wakeup", "handle__sched_wakeup_new", "handle_switch"} {
+		if prog, ok := spec.Programs[name]; assert.True(t, ok, "program %s not found", name) {
+			assert.Equal(t, ebpf.RawTracepoint, prog.Type, "program %s should attach to a raw tracepoint", name)
+		}
+	}
+}
+
+func TestParseKernelVersion(t *testing.T) {
+	tests := []struct {
+		release string
+		want    [2]int
+		wantErr bool
+	}{
+		{release: "5.10.134-13.an8.x86_64", want: [2]int{5, 10}},
+		{release: "4.19.91-26.al7.x86_64", want: [2]int{4, 19}},
+		{release: "5.15-rc1", want: [2]int{5, 15}},
+		{release: "6.1.0", want: [2]int{6, 1}},
+		{release: "5", wantErr: true},
+		{release: "x.y.z", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.release, func(t *testing.T) {
+			got, err := parseKernelVersion(tt.release)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -3,140 +3,159 @@
 #include "bpf_core_read.h"
 
 #define TASK_RUNNING 0
-#define MAX_CGROUP_NAME_SIZE 128
+
+/* task_struct->state was renamed to __state in kernel 5.14 */
+struct task_struct___old {
+	long state;
+} __attribute__((preserve_access_index));
 
 struct {
 	__uint(type, BPF_MAP_TYPE_HASH);
 	__uint(max_entries, 10240);
 	__type(key, u32);
 	__type(value, u64);
-} pid_start_time SEC(".maps");
+} pid_start_time SEC(".maps");  /* enqueue timestamps keyed by the pids of tasks, i.e. thread ids */
+
+/* latency of the tasks within a cgroup in a time window */
+struct cgroup_latency {
+	u64 delay;
+	u64 counter;
+};
+
+/* the generation of the output maps switches are accounted in, userspace flips it at the end of each time window
+ * and reads the maps of the last generation, which are no longer updated, so the delay and counter of a window are
+ * read together without racing with the accounting */
+struct {
+	__uint(type, BPF_MAP_TYPE_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, u32);
+	__type(value, u32);
+} output_generation SEC(".maps");
 
 struct {
 	__uint(type, BPF_MAP_TYPE_HASH);
 	__uint(max_entries, 1024);
-	__type(key, char[MAX_CGROUP_NAME_SIZE]);
-	__type(value, u64);
-} output_cgroup_delay SEC(".maps");
+	__type(key, u64);
+	__type(value, struct cgroup_latency);
+} output_cgroup_latency_0 SEC(".maps");
 
 struct {
 	__uint(type, BPF_MAP_TYPE_HASH);
 	__uint(max_entries, 1024);
-	__type(key, char[MAX_CGROUP_NAME_SIZE]);
-	__type(value, u64);
-} output_cgroup_counter SEC(".maps");
-
-struct sched_wakeup_tp_args {
-	struct trace_entry ent;
-	char comm[16];
-	pid_t pid;
-	int prio;
-	int success;
-	int target_cpu;
-	char __data[0];
-};
+	__type(key, u64);
+	__type(value, struct cgroup_latency);
+} output_cgroup_latency_1 SEC(".maps");
 
-struct sched_switch_tp_args {
-	struct trace_entry ent;
-	char prev_comm[16];
-	pid_t prev_pid;
-	int prev_prio;
-	long int prev_state;
-	char next_comm[16];
-	pid_t next_pid;
-	int next_prio;
-	char __data[0];
-};
+static __always_inline
+long get_task_state(struct task_struct *task)
+{
+	if (bpf_core_field_exists(task->__state))
+		return BPF_CORE_READ(task, __state);
+	return BPF_CORE_READ((struct task_struct___old *)task, state);
+}
+
+/* the kernfs id of the cpu cgroup of the task, which is the inode number of the cgroup directory since kernel 5.5,
+ * earlier kernels are refused by userspace since the id also carries the inode generation. Unlike
+ * bpf_get_current_cgroup_id() which only returns the id in the cgroup v2 hierarchy of the current task, it works on
+ * both cgroup v1 and v2 since all subsystems point to the unified cgroup on v2. */
+static __always_inline
+u64 get_task_cgroup_id(struct task_struct *task)
+{
+	struct cgroup_subsys_state **subsys, *css;
+	struct css_set *cgroups;
+	struct kernfs_node *kn;
+	u64 cgroup_id = 0;
+	int cpu_cgrp = bpf_core_enum_value(enum cgroup_subsys_id, cpu_cgrp_id);
+
+	cgroups = BPF_CORE_READ(task, cgroups);
+	/* the subsystem ids depend on the kernel config, so index the array at runtime rather than by CO-RE */
+	subsys = (void *)__builtin_preserve_access_index(&cgroups->subsys);
+	bpf_probe_read_kernel(&css, sizeof(css), subsys + cpu_cgrp);
+	if (!css)
+		return 0;
+	kn = BPF_CORE_READ(css, cgroup, kn);
+	if (!kn)
+		return 0;
+	bpf_core_read(&cgroup_id, sizeof(cgroup_id), &kn->id);
+	return cgroup_id;
+}
 
 /* record enqueue timestamp */
 static __always_inline
 int trace_enqueue(struct task_struct *task)
 {
-    u64 ts;
-	u32 pid;
-
-	ts = bpf_ktime_get_ns();
-	// task->tgid is thread group id which is userspace pid
-	bpf_core_read(&pid, sizeof(pid), &task->tgid);
+	u64 ts = bpf_ktime_get_ns();
+	u32 pid = BPF_CORE_READ(task, pid);
 
+	if (!pid)
+		return 0;
 	bpf_map_update_elem(&pid_start_time, &pid, &ts, 0);
 	return 0;
 }
 
-SEC("tp/sched/sched_wakeup")
-int handle__sched_wakeup(struct sched_wakeup_tp_args *ctx)
+/* sum deltas and count switches of the cgroup in the output map, switches on other cpus may account concurrently */
+static __always_inline
+void account_latency(void *output, u64 cgroup_id, u64 delta)
 {
-    struct task_struct *task = (void *)bpf_get_current_task();
-
-	return trace_enqueue(task);
+	struct cgroup_latency init = {}, *latency;
+
+	latency = bpf_map_lookup_elem(output, &cgroup_id);
+	if (!latency) {
+		bpf_map_update_elem(output, &cgroup_id, &init, BPF_NOEXIST);
+		latency = bpf_map_lookup_elem(output, &cgroup_id);
+		if (!latency)
+			return;
+	}
+	__sync_fetch_and_add(&latency->delay, delta);
+	__sync_fetch_and_add(&latency->counter, 1);
 }
 
-SEC("tp/sched/sched_wakeup_new")
-int handle__sched_wakeup_new(struct sched_wakeup_tp_args *ctx)
+/* raw tracepoints pass the woken task rather than running in its context, TP_PROTO(struct task_struct *p) */
+SEC("raw_tp/sched_wakeup")
+int handle__sched_wakeup(struct bpf_raw_tracepoint_args *ctx)
 {
-    struct task_struct *task = (void *)bpf_get_current_task();
+	return trace_enqueue((struct task_struct *)ctx->args[0]);
+}
 
-    return trace_enqueue(task);
+SEC("raw_tp/sched_wakeup_new")
+int handle__sched_wakeup_new(struct bpf_raw_tracepoint_args *ctx)
+{
+	return trace_enqueue((struct task_struct *)ctx->args[0]);
 }
 
-SEC("tp/sched/sched_switch")
-int handle_switch(struct sched_switch_tp_args *ctx)
+/* TP_PROTO(bool preempt, struct task_struct *prev, struct task_struct *next) */
+SEC("raw_tp/sched_switch")
+int handle_switch(struct bpf_raw_tracepoint_args *ctx)
 {
-    struct task_struct *task = (void *)bpf_get_current_task();
-    struct css_set *cgroups;
-    struct cgroup_subsys_state *subsys[14];
-    struct cgroup *cg;
-    struct kernfs_node *kn;
-    char *cgroup_name;
-	char cgroup_name_array[MAX_CGROUP_NAME_SIZE];
-	long name_len;
-
-    bpf_core_read(&cgroups, sizeof(cgroups), &task->cgroups);
-    bpf_core_read(&subsys, sizeof(subsys), &cgroups->subsys);
-    bpf_core_read(&cg, sizeof(cg), &subsys[1]->cgroup);
-    bpf_core_read(&kn, sizeof(kn), &cg->kn);
-    bpf_core_read(&cgroup_name,sizeof(cgroup_name),&kn->name);
-    if (!cgroup_name)
-        return 0;
-    name_len = bpf_core_read_str(&cgroup_name_array, MAX_CGROUP_NAME_SIZE, cgroup_name);
-    if (name_len < 0)
-        return 0;
-
-	u32 pid = ctx->next_pid;
-	long int prev_state;
+	struct task_struct *prev = (struct task_struct *)ctx->args[1];
+	struct task_struct *next = (struct task_struct *)ctx->args[2];
+	u64 *tsp, delta, cgroup_id;
+	u32 pid, zero = 0, *generation;
+
 	/* ivcsw: treat like an enqueue event and store timestamp */
-	prev_state = ctx->prev_state;
-	if (prev_state == TASK_RUNNING) {
-	    struct task_struct *prev = (void *)bpf_get_current_task();
-    	trace_enqueue(prev);
-	}
+	if (get_task_state(prev) == TASK_RUNNING)
+		trace_enqueue(prev);
 
-    u64 *tsp, delta, now;
-    u64 *last, *lastcounter;
-    u64 init_delay = 0;
-    u64 init_counter = 0;
 	/* fetch timestamp and calculate delta */
+	pid = BPF_CORE_READ(next, pid);
 	tsp = bpf_map_lookup_elem(&pid_start_time, &pid);
 	if (!tsp)
 		return 0;   /* missed enqueue */
-	now = bpf_ktime_get_ns();
-	delta = (now - *tsp);
-
-    /* sum deltas and count switch times in this collect period*/
-    lastcounter = bpf_map_lookup_elem(&output_cgroup_counter, &cgroup_name_array);
-    if (!lastcounter)
-        lastcounter = &init_counter;
-    last = bpf_map_lookup_elem(&output_cgroup_delay, &cgroup_name_array);
-    if (!last)
-        last = &init_delay;
-    *lastcounter = *lastcounter + 1;
-    delta = (delta + *last);
-
-    /* update maps*/
-    bpf_map_update_elem(&output_cgroup_counter, &cgroup_name_array, lastcounter, BPF_ANY);
-    bpf_map_update_elem(&output_cgroup_delay, &cgroup_name_array, &delta, BPF_ANY);
+	delta = bpf_ktime_get_ns() - *tsp;
 	bpf_map_delete_elem(&pid_start_time, &pid);
 
+	/* the delay is of the next task, which is switched in */
+	cgroup_id = get_task_cgroup_id(next);
+	if (!cgroup_id)
+		return 0;
+
+	/* entries of the last generation are deleted by userspace per period */
+	generation = bpf_map_lookup_elem(&output_generation, &zero);
+	if (generation && *generation)
+		account_latency(&output_cgroup_latency_1, cgroup_id, delta);
+	else
+		account_latency(&output_cgroup_latency_0, cgroup_id, delta);
+
 	return 0;
 }
 